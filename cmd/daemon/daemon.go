@@ -6,11 +6,16 @@ import (
 
 	"github.com/go-co-op/gocron/v2"
 	"github.com/spf13/cobra"
+	"github.com/wnarutou/gitrieve/internal/actions"
 	"github.com/wnarutou/gitrieve/internal/config"
 	"github.com/wnarutou/gitrieve/internal/discussion"
 	"github.com/wnarutou/gitrieve/internal/issue"
+	"github.com/wnarutou/gitrieve/internal/metadata"
+	"github.com/wnarutou/gitrieve/internal/project"
+	"github.com/wnarutou/gitrieve/internal/pull"
 	"github.com/wnarutou/gitrieve/internal/release"
 	"github.com/wnarutou/gitrieve/internal/repository"
+	"github.com/wnarutou/gitrieve/internal/security"
 	"github.com/wnarutou/gitrieve/internal/typedef"
 	"github.com/wnarutou/gitrieve/internal/ui"
 	"github.com/wnarutou/gitrieve/internal/wiki"
@@ -72,6 +77,15 @@ func runDaemon(cmd *cobra.Command, args []string) {
 				ui.Errorf("Error scheduling download issues of %s, %s", repo.Name, err)
 			}
 		}
+		if repo.DownloadPullRequests {
+			_, err = s.NewJob(
+				gocron.CronJob(repo.Cron, false),
+				gocron.NewTask(pull.Sync, context.Background(), repo, storages),
+			)
+			if err != nil {
+				ui.Errorf("Error scheduling download pull requests of %s, %s", repo.Name, err)
+			}
+		}
 		if repo.DownloadWiki {
 			_, err = s.NewJob(
 				gocron.CronJob(repo.Cron, false),
@@ -90,6 +104,42 @@ func runDaemon(cmd *cobra.Command, args []string) {
 				ui.Errorf("Error scheduling download discussion of %s, %s", repo.Name, err)
 			}
 		}
+		if repo.DownloadActions {
+			_, err = s.NewJob(
+				gocron.CronJob(repo.Cron, false),
+				gocron.NewTask(actions.Sync, context.Background(), repo, storages),
+			)
+			if err != nil {
+				ui.Errorf("Error scheduling download actions of %s, %s", repo.Name, err)
+			}
+		}
+		if repo.DownloadMetadata {
+			_, err = s.NewJob(
+				gocron.CronJob(repo.Cron, false),
+				gocron.NewTask(metadata.Sync, context.Background(), repo, storages),
+			)
+			if err != nil {
+				ui.Errorf("Error scheduling download metadata of %s, %s", repo.Name, err)
+			}
+		}
+		if repo.DownloadProjects {
+			_, err = s.NewJob(
+				gocron.CronJob(repo.Cron, false),
+				gocron.NewTask(project.Sync, context.Background(), repo, storages),
+			)
+			if err != nil {
+				ui.Errorf("Error scheduling download projects of %s, %s", repo.Name, err)
+			}
+		}
+		if repo.DownloadSecurity {
+			_, err = s.NewJob(
+				gocron.CronJob(repo.Cron, false),
+				gocron.NewTask(security.Sync, context.Background(), repo, storages),
+			)
+			if err != nil {
+				ui.Errorf("Error scheduling download security of %s, %s", repo.Name, err)
+			}
+		}
 		ui.Printf("Scheduled %s, cron: %s", repo.Name, repo.Cron)
 	}
 	ui.Printf("Starting daemon")
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"errors"
 
 	"github.com/spf13/cobra"
 	"github.com/wnarutou/gitrieve/internal/config"
@@ -32,7 +33,7 @@ func runRepository(cmd *cobra.Command, args []string) {
 			}
 		}
 		ui.Printf("Running %s", repo.Name)
-		if err := repository.Sync(context.Background(), repo, false, storages); err != nil {
+		if err := repository.Sync(context.Background(), repo, false, storages); err != nil && !errors.Is(err, repository.ErrUnchanged) {
 			ui.Errorf("Error running %s, %s", repo.Name, err)
 			// move on to next repo
 		}
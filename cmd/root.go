@@ -4,12 +4,17 @@ import (
 	"fmt"
 
 	"github.com/spf13/cobra"
+	"github.com/wnarutou/gitrieve/cmd/actions"
 	"github.com/wnarutou/gitrieve/cmd/daemon"
 	"github.com/wnarutou/gitrieve/cmd/discussion"
 	"github.com/wnarutou/gitrieve/cmd/issue"
+	"github.com/wnarutou/gitrieve/cmd/metadata"
+	"github.com/wnarutou/gitrieve/cmd/project"
+	"github.com/wnarutou/gitrieve/cmd/pull"
 	"github.com/wnarutou/gitrieve/cmd/release"
 	"github.com/wnarutou/gitrieve/cmd/repository"
 	"github.com/wnarutou/gitrieve/cmd/run"
+	"github.com/wnarutou/gitrieve/cmd/security"
 	"github.com/wnarutou/gitrieve/cmd/server"
 	"github.com/wnarutou/gitrieve/cmd/wiki"
 	"github.com/wnarutou/gitrieve/internal/config"
@@ -35,8 +40,13 @@ func init() {
 	rootCmd.AddCommand(daemon.Cmd)
 	rootCmd.AddCommand(release.Cmd)
 	rootCmd.AddCommand(issue.Cmd)
+	rootCmd.AddCommand(pull.Cmd)
 	rootCmd.AddCommand(wiki.Cmd)
 	rootCmd.AddCommand(discussion.Cmd)
+	rootCmd.AddCommand(actions.Cmd)
+	rootCmd.AddCommand(metadata.Cmd)
+	rootCmd.AddCommand(project.Cmd)
+	rootCmd.AddCommand(security.Cmd)
 	rootCmd.AddCommand(server.Cmd)
 	// flags
 	rootCmd.PersistentFlags().StringVarP(&config.Path, "config", "c", "config.yaml", "config file path")
@@ -2,6 +2,7 @@ package run
 
 import (
 	"context"
+	"errors"
 
 	"github.com/spf13/cobra"
 	"github.com/wnarutou/gitrieve/internal/config"
@@ -30,7 +31,7 @@ func runRun(cmd *cobra.Command, args []string) {
 			}
 		}
 		ui.Printf("Running %s", repo.Name)
-		if err := repository.Sync(context.Background(), repo, false, storages); err != nil {
+		if err := repository.Sync(context.Background(), repo, false, storages); err != nil && !errors.Is(err, repository.ErrUnchanged) {
 			ui.Errorf("Error running %s, %s", repo.Name, err)
 			// move on to next repo
 		}
@@ -107,6 +107,7 @@ func (s *Server) setupRoutes(cfg *config.Config) {
 	apiGroup.PUT("/api/storage/:id", api.UpdateStorage)
 	apiGroup.DELETE("/api/storage/:id", api.DeleteStorage)
 	apiGroup.GET("/api/metrics", monitor.GetMetrics)
+	apiGroup.GET("/api/github/quota", api.GetGitHubQuota)
 }
 
 func (s *Server) setupTestRoutes(db *db.DB) {
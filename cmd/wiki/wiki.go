@@ -2,6 +2,7 @@ package wiki
 
 import (
 	"context"
+	"errors"
 	"os"
 	"os/signal"
 
@@ -49,7 +50,7 @@ func runWiki(cmd *cobra.Command, args []string) {
 			break
 		}
 		ui.Printf("Running %s", repo.Name)
-		if err := wiki.Sync(ctx, repo, storages); err != nil {
+		if err := wiki.Sync(ctx, repo, storages); err != nil && !errors.Is(err, repository.ErrNoWiki) {
 			if ctx.Err() != nil {
 				ui.Printf("Download cancelled")
 				break
@@ -0,0 +1,239 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	gh "github.com/google/go-github/v56/github"
+	"github.com/wnarutou/gitrieve/internal/lock"
+	"github.com/wnarutou/gitrieve/internal/scm"
+	"github.com/wnarutou/gitrieve/internal/scm/github"
+	"github.com/wnarutou/gitrieve/internal/storage"
+	"github.com/wnarutou/gitrieve/internal/typedef"
+	"github.com/wnarutou/gitrieve/internal/ui"
+)
+
+// Sync archives a repository's GitHub Actions history under actions/:
+// workflows.json and the workflow definitions in workflows/, and per archived
+// run a directory runs/<run id>/ holding jobs.json, logs.zip, artifacts.json,
+// the unexpired artifacts in artifacts/ and run.json. Which runs of each
+// workflow are archived is set by the repository's actions policy. Runs are
+// immutable once completed, so a run already stored is not fetched again.
+func Sync(ctx context.Context, repo typedef.Repository, storages []typedef.MultiStorage) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	r, err := scm.NewRepository(repo.URL)
+	if err != nil {
+		return err
+	}
+
+	// Serialize concurrent syncs of the same repo's actions: they write the
+	// same storage actions/ paths.
+	unlock, err := lock.Acquire(ctx, r, "actions")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	c, err := github.New(r.Host)
+	if err != nil {
+		return err
+	}
+
+	workflows, err := c.GetWorkflows(ctx, r.Owner, r.Name)
+	if err != nil {
+		return err
+	}
+	if err := putJSON(storages, r, "workflows.json", workflows); err != nil {
+		return err
+	}
+	since := repo.Actions.Since(time.Now())
+	limit := repo.Actions.GetRunLimit()
+	for _, workflow := range workflows {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// Workflows GitHub adds itself (e.g. dynamic/pages/...) have no file
+		// in the repository.
+		definition, err := c.GetWorkflowFile(ctx, r.Owner, r.Name, workflow.GetPath())
+		switch {
+		case github.NotFound(err):
+		case err != nil:
+			return err
+		default:
+			if err := put(storages, r, "workflows/"+path.Base(workflow.GetPath()), definition); err != nil {
+				return err
+			}
+		}
+
+		runs, err := c.GetWorkflowRuns(ctx, r.Owner, r.Name, workflow.GetID(), since, limit)
+		if err != nil {
+			return err
+		}
+		for _, run := range runs {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			dir := fmt.Sprintf("runs/%d", run.GetID())
+			missing, err := missingFrom(storages, r, dir+"/run.json")
+			if err != nil {
+				return err
+			}
+			if len(missing) == 0 {
+				continue
+			}
+			ui.Printf("Archiving %s run %d", workflow.GetName(), run.GetID())
+			if err := syncRun(ctx, c, r, run, dir, missing); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// syncRun stores one run in dir of storages. run.json is written last: it
+// marks the run as archived, so a run that failed halfway is retried on the
+// next sync.
+func syncRun(ctx context.Context, c *github.Client, r *scm.Repository, run *gh.WorkflowRun, dir string, storages []typedef.MultiStorage) error {
+	jobs, err := c.GetWorkflowJobs(ctx, r.Owner, r.Name, run.GetID())
+	if err != nil {
+		return err
+	}
+	if err := putJSON(storages, r, dir+"/jobs.json", jobs); err != nil {
+		return err
+	}
+
+	err = putDownload(storages, r, dir+"/logs.zip", func(file string) (int64, error) {
+		return c.DownloadRunLogs(ctx, r.Owner, r.Name, run.GetID(), file)
+	})
+	switch {
+	case github.NotFound(err):
+		ui.Printf("Logs of run %d have expired, skipping them", run.GetID())
+	case err != nil:
+		return err
+	}
+
+	artifacts, err := c.GetRunArtifacts(ctx, r.Owner, r.Name, run.GetID())
+	if err != nil {
+		return err
+	}
+	if err := putJSON(storages, r, dir+"/artifacts.json", artifacts); err != nil {
+		return err
+	}
+	for _, artifact := range artifacts {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if artifact.GetExpired() {
+			continue
+		}
+		err := putDownload(storages, r, fmt.Sprintf("%s/artifacts/%s.zip", dir, artifact.GetName()), func(file string) (int64, error) {
+			return c.DownloadArtifact(ctx, r.Owner, r.Name, artifact.GetID(), file)
+		})
+		if github.NotFound(err) {
+			ui.Printf("Artifact %s of run %d has expired, skipping it", artifact.GetName(), run.GetID())
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return putJSON(storages, r, dir+"/run.json", run)
+}
+
+// actionsPath returns where name, relative to the repository's actions
+// directory, is stored in s.
+func actionsPath(s typedef.MultiStorage, r *scm.Repository, name string) (string, error) {
+	return storage.ObjectPath(s, r.Host, r.Owner, r.Name, "actions", name)
+}
+
+// missingFrom returns the storages that lack filename.
+func missingFrom(storages []typedef.MultiStorage, r *scm.Repository, filename string) ([]typedef.MultiStorage, error) {
+	var missing []typedef.MultiStorage
+	for _, s := range storages {
+		backend, err := storage.GetStorage(s)
+		if err != nil {
+			return nil, err
+		}
+		p, err := actionsPath(s, r, filename)
+		if err != nil {
+			return nil, err
+		}
+		objectMetaInfo, err := backend.ListObjectMetaInfo(p)
+		if err != nil || len(objectMetaInfo) == 0 {
+			missing = append(missing, s)
+		}
+	}
+	return missing, nil
+}
+
+// put stores data as filename in every storage of storages.
+func put(storages []typedef.MultiStorage, r *scm.Repository, filename string, data []byte) error {
+	for _, s := range storages {
+		backend, err := storage.GetStorage(s)
+		if err != nil {
+			return err
+		}
+		p, err := actionsPath(s, r, filename)
+		if err != nil {
+			return err
+		}
+		if err := backend.PutObject(p, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putDownload stores what download writes into a temporary file as filename
+// in every storage of storages, streaming it rather than holding it in memory:
+// logs and artifacts can be large. The error of download is returned as is.
+func putDownload(storages []typedef.MultiStorage, r *scm.Repository, filename string, download func(file string) (int64, error)) error {
+	tmp, err := os.CreateTemp("", "gitrieve-actions-*.zip")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	size, err := download(tmp.Name())
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(tmp.Name())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	for _, s := range storages {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		backend, err := storage.GetStorage(s)
+		if err != nil {
+			return err
+		}
+		p, err := actionsPath(s, r, filename)
+		if err != nil {
+			return err
+		}
+		if err := backend.PutObjectFrom(p, f, size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putJSON stores v, indented, as filename in every storage of storages.
+func putJSON(storages []typedef.MultiStorage, r *scm.Repository, filename string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", filename, err)
+	}
+	return put(storages, r, filename, data)
+}
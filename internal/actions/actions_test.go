@@ -0,0 +1,96 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wnarutou/gitrieve/internal/scm/github/githubtest"
+	"github.com/wnarutou/gitrieve/internal/storage"
+	"github.com/wnarutou/gitrieve/internal/typedef"
+)
+
+func TestSyncCancelledContextReturnsImmediately(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := Sync(ctx, typedef.Repository{URL: "github.com/test/repo"}, nil)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestSyncArchivesLatestRunsIncrementally(t *testing.T) {
+	var base string
+	var runs = `{"id":12,"status":"completed"},{"id":11,"status":"completed"},{"id":10,"status":"completed"}`
+	var fetched []string
+	host := githubtest.Serve(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/repos/owner/repo/actions/workflows":
+			fmt.Fprint(w, `{"total_count":2,"workflows":[{"id":1,"name":"CI","path":".github/workflows/ci.yml"},
+				{"id":2,"name":"pages-build-deployment","path":"dynamic/pages/pages-build-deployment"}]}`)
+		case "/repos/owner/repo/contents/.github/workflows/ci.yml":
+			fmt.Fprint(w, "on: push\n")
+		case "/repos/owner/repo/actions/workflows/1/runs":
+			require.Equal(t, "completed", r.URL.Query().Get("status"))
+			fmt.Fprintf(w, `{"total_count":3,"workflow_runs":[%s]}`, runs)
+		case "/repos/owner/repo/actions/workflows/2/runs":
+			fmt.Fprint(w, `{"total_count":0,"workflow_runs":[]}`)
+		case "/repos/owner/repo/actions/runs/13/jobs", "/repos/owner/repo/actions/runs/12/jobs", "/repos/owner/repo/actions/runs/11/jobs":
+			fetched = append(fetched, path.Base(path.Dir(r.URL.Path)))
+			fmt.Fprint(w, `{"total_count":1,"jobs":[{"id":100,"name":"build"}]}`)
+		case "/repos/owner/repo/actions/runs/13/logs", "/repos/owner/repo/actions/runs/12/logs":
+			http.Redirect(w, r, base+"/storage/logs", http.StatusFound)
+		case "/repos/owner/repo/actions/runs/11/logs":
+			w.WriteHeader(http.StatusGone)
+			fmt.Fprint(w, `{"message":"Gone"}`)
+		case "/repos/owner/repo/actions/runs/12/artifacts":
+			fmt.Fprint(w, `{"total_count":2,"artifacts":[{"id":5,"name":"dist","expired":false},{"id":6,"name":"coverage","expired":true}]}`)
+		case "/repos/owner/repo/actions/runs/13/artifacts", "/repos/owner/repo/actions/runs/11/artifacts":
+			fmt.Fprint(w, `{"total_count":0,"artifacts":[]}`)
+		case "/repos/owner/repo/actions/artifacts/5/zip":
+			http.Redirect(w, r, base+"/storage/dist", http.StatusFound)
+		case "/storage/logs", "/storage/dist":
+			w.Header().Set("Content-Type", "application/zip")
+			fmt.Fprint(w, "zip of "+path.Base(r.URL.Path))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	base = "http://" + host
+	dir := t.TempDir()
+	storages := []typedef.MultiStorage{{Storage: typedef.Storage{Name: "local", Type: storage.FileStorage, Path: dir}}}
+	repo := typedef.Repository{URL: host + "/owner/repo", Actions: typedef.ActionsPolicy{RunLimit: 2}}
+	t.Cleanup(func() { os.RemoveAll(".gitrieve") })
+
+	require.NoError(t, Sync(context.Background(), repo, storages))
+	actions := path.Join(dir, host, "owner", "repo", "actions")
+	data, err := os.ReadFile(path.Join(actions, "workflows", "ci.yml"))
+	require.NoError(t, err)
+	require.Equal(t, "on: push\n", string(data))
+	require.NoFileExists(t, path.Join(actions, "workflows", "pages-build-deployment"))
+	data, err = os.ReadFile(path.Join(actions, "workflows.json"))
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"name": "pages-build-deployment"`)
+
+	data, err = os.ReadFile(path.Join(actions, "runs", "12", "logs.zip"))
+	require.NoError(t, err)
+	require.Equal(t, "zip of logs", string(data))
+	data, err = os.ReadFile(path.Join(actions, "runs", "12", "artifacts", "dist.zip"))
+	require.NoError(t, err)
+	require.Equal(t, "zip of dist", string(data))
+	require.NoFileExists(t, path.Join(actions, "runs", "12", "artifacts", "coverage.zip"), "expired artifacts are skipped")
+	require.FileExists(t, path.Join(actions, "runs", "12", "jobs.json"))
+	require.FileExists(t, path.Join(actions, "runs", "12", "run.json"))
+	require.NoFileExists(t, path.Join(actions, "runs", "11", "logs.zip"), "expired logs are skipped")
+	require.FileExists(t, path.Join(actions, "runs", "11", "run.json"))
+	require.NoDirExists(t, path.Join(actions, "runs", "10"), "only the latest runLimit runs are archived")
+
+	runs = `{"id":13,"status":"completed"},` + runs
+	require.NoError(t, Sync(context.Background(), repo, storages))
+	require.Equal(t, []string{"12", "11", "13"}, fetched, "stored runs are not fetched again")
+	require.FileExists(t, path.Join(actions, "runs", "13", "run.json"))
+}
@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/mholt/archives"
 )
@@ -46,3 +49,48 @@ func Create(ctx context.Context, sourceDir, targetName string) (*bytes.Buffer, e
 	}
 	return buf, nil
 }
+
+// Extract unpacks a gzip tarball made by Create into the absolute path destDir:
+// the entries under targetName/ land directly in destDir, like the sourceDir
+// they were packed from. Entries outside targetName, and any that would escape
+// destDir, are skipped.
+func Extract(ctx context.Context, data []byte, targetName, destDir string) error {
+	if !filepath.IsAbs(destDir) {
+		return fmt.Errorf("archive: destDir must be an absolute path, got %q", destDir)
+	}
+	format := archives.CompressedArchive{
+		Compression: archives.Gz{},
+		Extraction:  archives.Tar{},
+	}
+	return format.Extract(ctx, bytes.NewReader(data), func(ctx context.Context, f archives.FileInfo) error {
+		name := filepath.ToSlash(filepath.Clean(f.NameInArchive))
+		rel, ok := strings.CutPrefix(name, targetName+"/")
+		if !ok || rel == "" || !filepath.IsLocal(rel) {
+			return nil
+		}
+		dest := filepath.Join(destDir, filepath.FromSlash(rel))
+		if f.IsDir() {
+			return os.MkdirAll(dest, 0755)
+		}
+		if !f.Mode().IsRegular() {
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		src, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		out, err := os.Create(dest)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, src); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
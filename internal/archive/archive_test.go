@@ -110,3 +110,45 @@ func TestCreateRejectsRelativeSourceDir(t *testing.T) {
 	_, err := Create(context.Background(), "relative/path", "target")
 	require.Error(t, err)
 }
+
+func TestExtractRestoresCreatedTree(t *testing.T) {
+	src := path.Join(t.TempDir(), "tree")
+	require.NoError(t, os.MkdirAll(path.Join(src, "sub"), 0o755))
+	require.NoError(t, os.WriteFile(path.Join(src, "#1.md"), []byte("top"), 0o644))
+	require.NoError(t, os.WriteFile(path.Join(src, "sub", "inner.txt"), []byte("inner"), 0o644))
+	buf, err := Create(context.Background(), src, "issues")
+	require.NoError(t, err)
+
+	dest := t.TempDir()
+	require.NoError(t, Extract(context.Background(), buf.Bytes(), "issues", dest))
+	data, err := os.ReadFile(path.Join(dest, "#1.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "top", string(data))
+	data, err = os.ReadFile(path.Join(dest, "sub", "inner.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "inner", string(data))
+}
+
+func TestExtractSkipsEntriesOutsideTarget(t *testing.T) {
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	for _, name := range []string{"issues/../../evil.txt", "other/x.txt", "issues/ok.txt"} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: 2, Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte("hi"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gzw.Close())
+
+	root := t.TempDir()
+	dest := path.Join(root, "a", "b")
+	require.NoError(t, os.MkdirAll(dest, 0o755))
+	require.NoError(t, Extract(context.Background(), buf.Bytes(), "issues", dest))
+	entries, err := os.ReadDir(dest)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "ok.txt", entries[0].Name())
+	_, err = os.Stat(path.Join(root, "evil.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,251 @@
+// Package attachment downloads the images and files that issue and discussion
+// bodies link to, so an archive stays readable after the links rot or when the
+// repository is private.
+package attachment
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/wnarutou/gitrieve/internal/config"
+	"github.com/wnarutou/gitrieve/internal/scm/github"
+	"github.com/wnarutou/gitrieve/internal/storage"
+	"github.com/wnarutou/gitrieve/internal/ui"
+)
+
+// Dir is the directory, next to the markdown files, attachments are stored in.
+const Dir = "attachments"
+
+// downloadTimeout bounds one attachment download, so a stalled link cannot
+// hang the sync.
+const downloadTimeout = 10 * time.Minute
+
+// indexFile maps each downloaded URL to its file in Dir, so an attachment is
+// fetched once no matter how many syncs or bodies reference it.
+const indexFile = "index.json"
+
+// urlChars are the characters an attachment URL may contain in markdown or
+// HTML: it ends at whitespace, a closing paren/bracket or a quote.
+const urlChars = `[^\s()<>\[\]"']+`
+
+// extPattern is a plausible file extension taken from an attachment URL.
+var extPattern = regexp.MustCompile(`^\.[A-Za-z0-9]{1,5}$`)
+
+// commonExts picks the usual extension for media types that mime lists
+// several for (image/jpeg has .jfif, .jpe, .jpeg and .jpg).
+var commonExts = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"image/svg+xml":   ".svg",
+	"video/mp4":       ".mp4",
+	"video/quicktime": ".mov",
+	"application/pdf": ".pdf",
+	"application/zip": ".zip",
+	"text/plain":      ".txt",
+}
+
+// Store is the attachments directory of one component's working dir.
+type Store struct {
+	dir     string
+	host    string
+	pattern *regexp.Regexp
+	client  *http.Client
+	token   string
+	limit   int64 // max size in bytes; not positive means no limit
+	index   map[string]string
+}
+
+// Open opens the attachments directory under workDir for a repository on host,
+// creating it if needed. Requests to host carry its configured credential;
+// user content CDNs are fetched anonymously.
+func Open(workDir, host, owner, name string) (*Store, error) {
+	dir := path.Join(workDir, Dir)
+	if err := storage.CreateDirIfNotExist(dir); err != nil {
+		return nil, err
+	}
+	token, err := github.GitToken(host)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{
+		dir:     dir,
+		host:    host,
+		pattern: Pattern(host, owner, name),
+		client:  &http.Client{Timeout: downloadTimeout},
+		token:   token,
+		index:   map[string]string{},
+	}
+	if config.GetIns() != nil {
+		s.limit = config.GetAttachmentSizeLimit()
+	}
+	data, err := os.ReadFile(path.Join(dir, indexFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &s.index); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", indexFile, err)
+		}
+	}
+	return s, nil
+}
+
+// Pattern matches the attachment URLs GitHub generates for uploads to a
+// repository on host: the user content CDNs, the repository's assets and
+// files paths, and user-attachments.
+func Pattern(host, owner, name string) *regexp.Regexp {
+	h := regexp.QuoteMeta(host)
+	repo := regexp.QuoteMeta(owner + "/" + name)
+	return regexp.MustCompile(`https?://(?:` +
+		`(?:private-)?user-images\.githubusercontent\.com/` +
+		`|` + h + `/` + repo + `/(?:assets|files)/` +
+		`|` + h + `/user-attachments/(?:assets|files)/` +
+		`)` + urlChars)
+}
+
+// Find returns the attachment URLs in bodies, each once, in order of first
+// appearance.
+func (s *Store) Find(bodies ...string) []string {
+	seen := map[string]bool{}
+	var urls []string
+	for _, body := range bodies {
+		for _, u := range s.pattern.FindAllString(body, -1) {
+			if !seen[u] {
+				seen[u] = true
+				urls = append(urls, u)
+			}
+		}
+	}
+	return urls
+}
+
+// Fetch downloads every attachment bodies link to that is not stored yet. A
+// link that fails to download is logged and skipped: it is tried again the
+// next time its issue or discussion changes, and its markdown keeps the
+// original URL meanwhile.
+func (s *Store) Fetch(ctx context.Context, bodies ...string) error {
+	for _, u := range s.Find(bodies...) {
+		if _, ok := s.index[u]; ok {
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		name, err := s.download(ctx, u)
+		if err != nil {
+			ui.Printf("Skipping attachment %s, %s", u, err)
+			continue
+		}
+		s.index[u] = name
+	}
+	return s.save()
+}
+
+// download stores u under the hash of its content and returns the file name.
+// Identical files uploaded twice share one copy.
+func (s *Store) download(ctx context.Context, u string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+	// Only the repository's own host gets the token; net/http drops the
+	// header again if it redirects to another host, e.g. a signed CDN URL.
+	if s.token != "" && req.URL.Host == s.host {
+		req.Header.Set("Authorization", "token "+s.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if s.limit > 0 && resp.ContentLength > s.limit {
+		return "", fmt.Errorf("%d bytes exceed attachmentSizeLimit", resp.ContentLength)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".download-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	body := io.Reader(resp.Body)
+	if s.limit > 0 {
+		// One byte more than allowed tells an oversized body from one that
+		// did not announce its length.
+		body = io.LimitReader(resp.Body, s.limit+1)
+	}
+	n, err := io.Copy(io.MultiWriter(tmp, h), body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	if s.limit > 0 && n > s.limit {
+		return "", fmt.Errorf("larger than attachmentSizeLimit of %d bytes", s.limit)
+	}
+
+	name := hex.EncodeToString(h.Sum(nil)) + extension(req.URL.Path, resp.Header.Get("Content-Type"))
+	if _, err := os.Stat(path.Join(s.dir, name)); err == nil {
+		return name, nil
+	}
+	if err := os.Rename(tmp.Name(), path.Join(s.dir, name)); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// extension keeps the URL's file extension, or derives one from the content
+// type for extensionless URLs such as /assets/<uuid>.
+func extension(urlPath, contentType string) string {
+	if ext := path.Ext(urlPath); extPattern.MatchString(ext) {
+		return strings.ToLower(ext)
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	if ext, ok := commonExts[mediaType]; ok {
+		return ext
+	}
+	exts, err := mime.ExtensionsByType(mediaType)
+	if err != nil || len(exts) == 0 {
+		return ""
+	}
+	return exts[0]
+}
+
+// Rewrite replaces every downloaded attachment URL in body with its path
+// relative to the working dir, e.g. attachments/<sha256>.png.
+func (s *Store) Rewrite(body string) string {
+	return s.pattern.ReplaceAllStringFunc(body, func(u string) string {
+		if name, ok := s.index[u]; ok {
+			return Dir + "/" + name
+		}
+		return u
+	})
+}
+
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path.Join(s.dir, indexFile), data, 0644)
+}
@@ -0,0 +1,138 @@
+package attachment
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindMatchesGitHubAttachmentURLs(t *testing.T) {
+	s := &Store{pattern: Pattern("github.com", "owner", "repo")}
+	body := `![img](https://user-images.githubusercontent.com/1/abc.png)
+<img src="https://github.com/owner/repo/assets/1/2f9c-uuid" width="300">
+[log](https://github.com/owner/repo/files/123/log.txt) and
+https://github.com/user-attachments/assets/0d3c-uuid
+https://private-user-images.githubusercontent.com/1/x.png?jwt=abc
+https://github.com/other/repo/assets/1/not-ours
+https://example.com/img.png
+![img](https://user-images.githubusercontent.com/1/abc.png)`
+
+	assert.Equal(t, []string{
+		"https://user-images.githubusercontent.com/1/abc.png",
+		"https://github.com/owner/repo/assets/1/2f9c-uuid",
+		"https://github.com/owner/repo/files/123/log.txt",
+		"https://github.com/user-attachments/assets/0d3c-uuid",
+		"https://private-user-images.githubusercontent.com/1/x.png?jwt=abc",
+	}, s.Find(body))
+}
+
+func TestFetchDeduplicatesByContentAndRewrites(t *testing.T) {
+	var requests []string
+	var auth []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		auth = append(auth, r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/owner/repo/assets/1/a", "/owner/repo/assets/1/b":
+			w.Header().Set("Content-Type", "image/png")
+			fmt.Fprint(w, "same bytes")
+		case "/owner/repo/files/2/notes.TXT":
+			fmt.Fprint(w, "notes")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	dir := t.TempDir()
+	s, err := Open(dir, host, "owner", "repo")
+	require.NoError(t, err)
+	s.token = "secret"
+
+	a := srv.URL + "/owner/repo/assets/1/a"
+	b := srv.URL + "/owner/repo/assets/1/b"
+	notes := srv.URL + "/owner/repo/files/2/notes.TXT"
+	gone := srv.URL + "/owner/repo/assets/1/gone"
+	body := fmt.Sprintf("![a](%s) ![b](%s) [notes](%s) ![gone](%s)", a, b, notes, gone)
+	require.NoError(t, s.Fetch(context.Background(), body))
+
+	files, err := os.ReadDir(path.Join(dir, Dir))
+	require.NoError(t, err)
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	assert.Len(t, names, 3, "two identical images share one file, next to notes and the index: %v", names)
+	assert.Equal(t, []string{"secret", "secret", "secret", "secret"}, prefixed(auth, "token "))
+
+	rewritten := s.Rewrite(body)
+	assert.NotContains(t, rewritten, a)
+	assert.Contains(t, rewritten, gone, "a link that failed to download is kept")
+	assert.Equal(t, 2, strings.Count(rewritten, "](attachments/"+s.index[a]+")"))
+	assert.True(t, strings.HasSuffix(s.index[a], ".png"))
+	assert.True(t, strings.HasSuffix(s.index[notes], ".txt"))
+
+	// A later sync reuses the index and only retries what failed.
+	requests = nil
+	s, err = Open(dir, host, "owner", "repo")
+	require.NoError(t, err)
+	require.NoError(t, s.Fetch(context.Background(), body))
+	assert.Equal(t, []string{"/owner/repo/assets/1/gone"}, requests)
+}
+
+func TestTokenIsOnlySentToRepositoryHost(t *testing.T) {
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		fmt.Fprint(w, "x")
+	}))
+	defer srv.Close()
+
+	// The store belongs to another host; the test server is only reachable
+	// through the CDN pattern, which must stay anonymous.
+	s := &Store{dir: t.TempDir(), host: "github.com", client: srv.Client(), token: "secret", index: map[string]string{}}
+	_, err := s.download(context.Background(), srv.URL+"/1/x.png")
+	require.NoError(t, err)
+	assert.Empty(t, auth)
+}
+
+func TestDownloadRejectsAttachmentsOverSizeLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chunked" {
+			// No Content-Length: the limit is only noticed while copying.
+			w.(http.Flusher).Flush()
+		}
+		fmt.Fprint(w, "0123456789")
+	}))
+	defer srv.Close()
+
+	s := &Store{dir: t.TempDir(), client: srv.Client(), limit: 5, index: map[string]string{}}
+	for _, p := range []string{"/sized", "/chunked"} {
+		_, err := s.download(context.Background(), srv.URL+p)
+		require.Error(t, err, p)
+	}
+	files, err := os.ReadDir(s.dir)
+	require.NoError(t, err)
+	assert.Empty(t, files, "a rejected download leaves nothing behind")
+
+	s.limit = 10
+	_, err = s.download(context.Background(), srv.URL+"/chunked")
+	require.NoError(t, err)
+}
+
+func prefixed(values []string, prefix string) []string {
+	var out []string
+	for _, v := range values {
+		out = append(out, strings.TrimPrefix(v, prefix))
+	}
+	return out
+}
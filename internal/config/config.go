@@ -2,9 +2,15 @@ package config
 
 import (
 	"fmt"
+	"net/url"
+	"path"
+	"reflect"
+	"strings"
 	"time"
 
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/spf13/viper"
+	"github.com/wnarutou/gitrieve/internal/render"
 	"github.com/wnarutou/gitrieve/internal/retry"
 	"github.com/wnarutou/gitrieve/internal/typedef"
 	"github.com/wnarutou/gitrieve/internal/ui"
@@ -14,11 +20,24 @@ type Config struct {
 	Repository       []typedef.Repository   `yaml:"repository"`
 	Storage          []typedef.MultiStorage `yaml:"storage"`
 	GitHubToken      string                 `yaml:"githubToken"`
+	GitHubTokens     []string               `yaml:"githubTokens"`
+	GitHubApp        typedef.GitHubApp      `yaml:"githubApp"`
+	GitHubHosts      []typedef.GitHubHost   `yaml:"githubHosts"`
 	ConcurrencyNum   uint                   `yaml:"cocurrencyNum" mapstructure:"cocurrencyNum"`
 	ReleaseSizeLimit int                    `yaml:"releaseSizeLimit"`
 	ReleaseNumLimit  int                    `yaml:"releaseNumLimit"`
 	RetryMaxCount    int                    `yaml:"retryMaxCount"`
 	RetryBaseDelay   time.Duration          `yaml:"retryBaseDelay"`
+	// ReconcileInterval is how often issue syncs list every issue and comment
+	// upstream to detect deletions, and project syncs fetch every project
+	// again. Init seeds 24h; negative disables it.
+	ReconcileInterval time.Duration `yaml:"reconcileInterval"`
+	// AttachmentSizeLimit is the size in bytes above which an attachment
+	// linked from an issue or discussion is not downloaded. Init seeds 100MB;
+	// negative means no limit.
+	AttachmentSizeLimit int64 `yaml:"attachmentSizeLimit"`
+	// Templates replaces the built-in markdown layouts with Go template files.
+	Templates typedef.Templates `yaml:"templates"`
 }
 
 var Path string
@@ -33,7 +52,11 @@ func Init() {
 	if err != nil {
 		ui.ErrorfExit("Error reading config file, %s", err)
 	}
-	err = vp.Unmarshal(&ins)
+	err = vp.Unmarshal(&ins, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		timeToString,
+	)))
 	if err != nil {
 		ui.ErrorfExit("Error unmarshalling config file, %s", err)
 	}
@@ -57,13 +80,39 @@ func Init() {
 	if ins.ReleaseSizeLimit == 0 {
 		ins.ReleaseSizeLimit = 300000000
 	}
+	if ins.ReconcileInterval == 0 {
+		ins.ReconcileInterval = 24 * time.Hour
+	}
+	if ins.AttachmentSizeLimit == 0 {
+		ins.AttachmentSizeLimit = 100 << 20
+	}
 	// 启动校验：每个仓库条目都必须有可用身份。身份键为空意味着永远无法被
 	// 匹配或执行，直接拒绝启动。
-	if err := validateIdentity(ins); err != nil {
+	for _, validate := range repositoryValidators {
+		if err := validate(ins); err != nil {
+			ui.ErrorfExit("Invalid configuration: %s", err)
+		}
+	}
+	if err := validateTemplates(ins.Templates); err != nil {
+		ui.ErrorfExit("Invalid configuration: %s", err)
+	}
+	if err := validateGitHubApp(ins.GitHubApp); err != nil {
+		ui.ErrorfExit("Invalid configuration: %s", err)
+	}
+	if err := validateGitHubHosts(ins.GitHubHosts); err != nil {
 		ui.ErrorfExit("Invalid configuration: %s", err)
 	}
 }
 
+// timeToString keeps date options such as release.newerThan strings: YAML
+// reads an unquoted 2024-01-31 as a timestamp.
+func timeToString(from, to reflect.Type, data interface{}) (interface{}, error) {
+	if t, ok := data.(time.Time); ok && to.Kind() == reflect.String {
+		return t.Format(time.RFC3339), nil
+	}
+	return data, nil
+}
+
 func GetIns() *Config {
 	return ins
 }
@@ -120,6 +169,34 @@ func GetRetryBaseDelay() time.Duration {
 	return ins.RetryBaseDelay
 }
 
+// GetReconcileInterval returns how often issue syncs check for deletions
+// upstream and project syncs refetch every project. Init seeds it to 24 hours
+// when the config value is zero; a negative value disables both.
+func GetReconcileInterval() time.Duration {
+	return ins.ReconcileInterval
+}
+
+// GetAttachmentSizeLimit returns the size in bytes above which an attachment is
+// not downloaded. Init seeds it to 100MB when the config value is zero; a
+// negative value means "no limit".
+func GetAttachmentSizeLimit() int64 {
+	return ins.AttachmentSizeLimit
+}
+
+// GetTemplate returns the path of the user's markdown layout for kind
+// (render.Issue, render.Pull, render.Discussion), or "" for the built-in one.
+func GetTemplate(kind string) string {
+	switch kind {
+	case render.Issue:
+		return ins.Templates.Issue
+	case render.Pull:
+		return ins.Templates.Pull
+	case render.Discussion:
+		return ins.Templates.Discussion
+	}
+	return ""
+}
+
 // GetRetryConfig assembles the retry configuration used by every GitHub API
 // call site in the issue/discussion/release syncs.
 func GetRetryConfig() retry.Config {
@@ -129,6 +206,30 @@ func GetRetryConfig() retry.Config {
 	}
 }
 
+// repositoryValidators check the repository entries of a config. Init runs
+// them on the config file, ValidateRepository on entries sent to the API.
+var repositoryValidators = []func(*Config) error{
+	validateIdentity,
+	validateGitBackend,
+	validateIssueAPI,
+	validateReleasePolicy,
+	validateActionsPolicy,
+	validateWikiPolicy,
+}
+
+// ValidateRepository rejects a repository entry the config file would be
+// rejected for, so entries created or updated through the API cannot carry
+// values a sync would trip over.
+func ValidateRepository(repo typedef.Repository) error {
+	cfg := &Config{Repository: []typedef.Repository{repo}}
+	for _, validate := range repositoryValidators {
+		if err := validate(cfg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // validateIdentity ensures every repository entry has a usable identity (a
 // non-empty URL, or orgName for user/org types). The repository identity is the
 // normalized URL; an entry without one can never be matched or executed.
@@ -144,6 +245,137 @@ func validateIdentity(cfg *Config) error {
 	return nil
 }
 
+// validateGitBackend rejects an unknown gitBackend value up front; a typo would
+// otherwise silently fall back to go-git on every sync.
+func validateGitBackend(cfg *Config) error {
+	for _, repo := range cfg.Repository {
+		switch repo.GetGitBackend() {
+		case typedef.GitBackendGoGit, typedef.GitBackendCLI:
+		default:
+			return fmt.Errorf("repository %q has unknown gitBackend %q (want %q or %q)",
+				repo.Name, repo.GitBackend, typedef.GitBackendGoGit, typedef.GitBackendCLI)
+		}
+	}
+	return nil
+}
+
+// validateIssueAPI rejects an unknown issueAPI value up front, like gitBackend.
+func validateIssueAPI(cfg *Config) error {
+	for _, repo := range cfg.Repository {
+		switch repo.GetIssueAPI() {
+		case typedef.IssueAPIREST, typedef.IssueAPIGraphQL:
+		default:
+			return fmt.Errorf("repository %q has unknown issueAPI %q (want %q or %q)",
+				repo.Name, repo.IssueAPI, typedef.IssueAPIREST, typedef.IssueAPIGraphQL)
+		}
+	}
+	return nil
+}
+
+// validateReleasePolicy rejects malformed asset globs, unknown retention modes
+// and newerThan dates up front; a bad glob would otherwise silently match
+// nothing.
+func validateReleasePolicy(cfg *Config) error {
+	for _, repo := range cfg.Repository {
+		for _, pattern := range append(append([]string{}, repo.Release.Include...), repo.Release.Exclude...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("repository %q has invalid release asset glob %q", repo.Name, pattern)
+			}
+		}
+		switch repo.Release.GetRetention() {
+		case typedef.ReleaseRetentionRetire, typedef.ReleaseRetentionKeep, typedef.ReleaseRetentionPrune:
+		default:
+			return fmt.Errorf("repository %q has unknown release.retention %q (want %q, %q or %q)", repo.Name,
+				repo.Release.Retention, typedef.ReleaseRetentionRetire, typedef.ReleaseRetentionKeep, typedef.ReleaseRetentionPrune)
+		}
+		if _, err := repo.Release.Cutoff(); err != nil {
+			return fmt.Errorf("repository %q has invalid release.newerThan %q (want YYYY-MM-DD or RFC 3339)",
+				repo.Name, repo.Release.NewerThan)
+		}
+	}
+	return nil
+}
+
+// validateActionsPolicy rejects negative run counts and day windows.
+func validateActionsPolicy(cfg *Config) error {
+	for _, repo := range cfg.Repository {
+		if repo.Actions.RunLimit < 0 {
+			return fmt.Errorf("repository %q has negative actions.runLimit %d", repo.Name, repo.Actions.RunLimit)
+		}
+		if repo.Actions.Days < 0 {
+			return fmt.Errorf("repository %q has negative actions.days %d", repo.Name, repo.Actions.Days)
+		}
+	}
+	return nil
+}
+
+// validateWikiPolicy rejects wiki.html with gitBackend cli: the rendering reads
+// the wiki through go-git, so it would otherwise be skipped on every sync.
+func validateWikiPolicy(cfg *Config) error {
+	for _, repo := range cfg.Repository {
+		if repo.Wiki.HTML && repo.GetGitBackend() == typedef.GitBackendCLI {
+			return fmt.Errorf("repository %q sets wiki.html, which needs gitBackend %q, not %q",
+				repo.Name, typedef.GitBackendGoGit, typedef.GitBackendCLI)
+		}
+	}
+	return nil
+}
+
+// validateTemplates parses the configured markdown layouts up front, so a
+// broken template fails at startup rather than on every sync.
+func validateTemplates(t typedef.Templates) error {
+	for kind, file := range map[string]string{render.Issue: t.Issue, render.Pull: t.Pull, render.Discussion: t.Discussion} {
+		if file == "" {
+			continue
+		}
+		if _, err := render.Parse(kind, file); err != nil {
+			return fmt.Errorf("templates.%s: %w", kind, err)
+		}
+	}
+	return nil
+}
+
+// validateGitHubApp checks that a configured GitHub App has everything needed
+// to mint installation tokens, so a half-filled section fails at startup
+// rather than on the first API call.
+func validateGitHubApp(app typedef.GitHubApp) error {
+	if !app.Configured() {
+		return nil
+	}
+	if app.InstallationID == 0 {
+		return fmt.Errorf("githubApp needs an installationID")
+	}
+	if app.PrivateKey == "" && app.PrivateKeyPath == "" {
+		return fmt.Errorf("githubApp needs a privateKey or privateKeyPath")
+	}
+	return nil
+}
+
+// validateGitHubHosts checks that every githubHosts entry names a distinct host
+// and that its endpoint overrides are absolute URLs.
+func validateGitHubHosts(hosts []typedef.GitHubHost) error {
+	seen := make(map[string]bool, len(hosts))
+	for _, h := range hosts {
+		host := strings.ToLower(strings.TrimSpace(h.Host))
+		if host == "" {
+			return fmt.Errorf("githubHosts entry needs a host")
+		}
+		if seen[host] {
+			return fmt.Errorf("githubHosts lists host %q twice", h.Host)
+		}
+		seen[host] = true
+		for _, raw := range []string{h.APIURL, h.UploadURL, h.GraphQLURL} {
+			if raw == "" {
+				continue
+			}
+			if u, err := url.Parse(raw); err != nil || u.Scheme == "" || u.Host == "" {
+				return fmt.Errorf("githubHosts entry %q has invalid URL %q", h.Host, raw)
+			}
+		}
+	}
+	return nil
+}
+
 // Save persists the current in-memory config back to the config file via viper.
 func Save() error {
 	if vp == nil {
@@ -153,10 +385,21 @@ func Save() error {
 	vp.Set("repository", ins.Repository)
 	vp.Set("storage", ins.Storage)
 	vp.Set("githubToken", ins.GitHubToken)
+	if len(ins.GitHubTokens) > 0 {
+		vp.Set("githubTokens", ins.GitHubTokens)
+	}
+	if ins.GitHubApp.Configured() {
+		vp.Set("githubApp", ins.GitHubApp)
+	}
+	if len(ins.GitHubHosts) > 0 {
+		vp.Set("githubHosts", ins.GitHubHosts)
+	}
 	vp.Set("cocurrencyNum", ins.ConcurrencyNum)
 	vp.Set("releaseSizeLimit", ins.ReleaseSizeLimit)
 	vp.Set("releaseNumLimit", ins.ReleaseNumLimit)
 	vp.Set("retryMaxCount", ins.RetryMaxCount)
 	vp.Set("retryBaseDelay", ins.RetryBaseDelay)
+	vp.Set("reconcileInterval", ins.ReconcileInterval)
+	vp.Set("attachmentSizeLimit", ins.AttachmentSizeLimit)
 	return vp.WriteConfig()
 }
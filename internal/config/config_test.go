@@ -143,3 +143,117 @@ func TestValidateIdentity(t *testing.T) {
 	// 空仓库列表 → 通过。
 	require.NoError(t, validateIdentity(&Config{}))
 }
+
+func TestValidateGitBackend(t *testing.T) {
+	// 未设置 → 默认 go-git，通过。
+	require.NoError(t, validateGitBackend(&Config{Repository: []typedef.Repository{
+		{Name: "a", URL: "github.com/a/a"},
+		{Name: "b", URL: "github.com/a/b", GitBackend: typedef.GitBackendCLI},
+	}}))
+
+	// 未知取值 → 拒绝。
+	err := validateGitBackend(&Config{Repository: []typedef.Repository{
+		{Name: "c", URL: "github.com/a/c", GitBackend: "libgit2"},
+	}})
+	require.Error(t, err)
+}
+
+func TestValidateIssueAPI(t *testing.T) {
+	require.NoError(t, validateIssueAPI(&Config{Repository: []typedef.Repository{
+		{Name: "a", URL: "github.com/a/a"},
+		{Name: "b", URL: "github.com/a/b", IssueAPI: typedef.IssueAPIGraphQL},
+	}}))
+
+	err := validateIssueAPI(&Config{Repository: []typedef.Repository{
+		{Name: "c", URL: "github.com/a/c", IssueAPI: "soap"},
+	}})
+	require.Error(t, err)
+}
+
+func TestValidateReleasePolicy(t *testing.T) {
+	require.NoError(t, validateReleasePolicy(&Config{Repository: []typedef.Repository{
+		{Name: "a", URL: "github.com/a/a"},
+		{Name: "b", URL: "github.com/a/b", Release: typedef.ReleasePolicy{
+			Include: []string{"*.tar.gz"}, Exclude: []string{"*-debug*"}, NewerThan: "2024-01-31",
+		}},
+		{Name: "c", URL: "github.com/a/c", Release: typedef.ReleasePolicy{NewerThan: "2024-01-31T08:00:00Z", Retention: typedef.ReleaseRetentionPrune}},
+	}}))
+
+	require.Error(t, validateReleasePolicy(&Config{Repository: []typedef.Repository{
+		{Name: "d", URL: "github.com/a/d", Release: typedef.ReleasePolicy{Exclude: []string{"[a-"}}},
+	}}))
+	require.Error(t, validateReleasePolicy(&Config{Repository: []typedef.Repository{
+		{Name: "e", URL: "github.com/a/e", Release: typedef.ReleasePolicy{NewerThan: "last year"}},
+	}}))
+	require.Error(t, validateReleasePolicy(&Config{Repository: []typedef.Repository{
+		{Name: "f", URL: "github.com/a/f", Release: typedef.ReleasePolicy{Retention: "delete"}},
+	}}))
+}
+
+func TestValidateActionsPolicy(t *testing.T) {
+	require.NoError(t, validateActionsPolicy(&Config{Repository: []typedef.Repository{
+		{Name: "a", URL: "github.com/a/a"},
+		{Name: "b", URL: "github.com/a/b", Actions: typedef.ActionsPolicy{RunLimit: 5, Days: 30}},
+	}}))
+	require.Error(t, validateActionsPolicy(&Config{Repository: []typedef.Repository{
+		{Name: "c", URL: "github.com/a/c", Actions: typedef.ActionsPolicy{RunLimit: -1}},
+	}}))
+	require.Error(t, validateActionsPolicy(&Config{Repository: []typedef.Repository{
+		{Name: "d", URL: "github.com/a/d", Actions: typedef.ActionsPolicy{Days: -7}},
+	}}))
+}
+
+func TestValidateWikiPolicy(t *testing.T) {
+	require.NoError(t, validateWikiPolicy(&Config{Repository: []typedef.Repository{
+		{Name: "a", URL: "github.com/a/a", Wiki: typedef.WikiPolicy{HTML: true}},
+		{Name: "b", URL: "github.com/a/b", GitBackend: typedef.GitBackendCLI},
+	}}))
+	require.Error(t, validateWikiPolicy(&Config{Repository: []typedef.Repository{
+		{Name: "c", URL: "github.com/a/c", GitBackend: typedef.GitBackendCLI, Wiki: typedef.WikiPolicy{HTML: true}},
+	}}))
+}
+
+func TestReleasePolicyFromConfig(t *testing.T) {
+	writeTmpConfig(t, `githubtoken: test
+releaseSizeLimit: 1000
+repository:
+  - name: a
+    url: github.com/a/a
+    release:
+      numLimit: -1
+      exclude: ["*.exe"]
+      skipPrereleases: true
+      newerThan: 2024-01-31
+`)
+	policy := GetIns().Repository[0].Release
+	require.Equal(t, -1, policy.GetNumLimit(GetReleaseNumLimit()))
+	require.Equal(t, 1000, policy.GetSizeLimit(GetReleaseSizeLimit()))
+	require.True(t, policy.SkipPrereleases)
+	require.False(t, policy.WantsAsset("setup.exe"))
+	require.True(t, policy.WantsAsset("app.tar.gz"))
+	cutoff, err := policy.Cutoff()
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC), cutoff)
+}
+
+func TestValidateGitHubApp(t *testing.T) {
+	// 未配置 App → 使用 githubToken，通过。
+	require.NoError(t, validateGitHubApp(typedef.GitHubApp{}))
+	require.NoError(t, validateGitHubApp(typedef.GitHubApp{AppID: 1, InstallationID: 2, PrivateKeyPath: "key.pem"}))
+
+	// 缺 installationID 或私钥 → 拒绝。
+	require.Error(t, validateGitHubApp(typedef.GitHubApp{AppID: 1, PrivateKey: "pem"}))
+	require.Error(t, validateGitHubApp(typedef.GitHubApp{AppID: 1, InstallationID: 2}))
+}
+
+func TestValidateGitHubHosts(t *testing.T) {
+	require.NoError(t, validateGitHubHosts(nil))
+	require.NoError(t, validateGitHubHosts([]typedef.GitHubHost{
+		{Host: "github.corp.example"},
+		{Host: "ghe.example", APIURL: "https://ghe.example/api/v3/", GraphQLURL: "https://ghe.example/api/graphql"},
+	}))
+
+	require.Error(t, validateGitHubHosts([]typedef.GitHubHost{{APIURL: "https://x/api/v3/"}}))
+	require.Error(t, validateGitHubHosts([]typedef.GitHubHost{{Host: "a.example"}, {Host: "A.example"}}))
+	require.Error(t, validateGitHubHosts([]typedef.GitHubHost{{Host: "a.example", APIURL: "a.example/api/v3"}}))
+}
@@ -45,6 +45,8 @@ func Initialize(path string) (*DB, error) {
 			id TEXT PRIMARY KEY,
 			job_name TEXT NOT NULL,
 			repo_key TEXT NOT NULL DEFAULT '',
+			skipped INTEGER NOT NULL DEFAULT 0,
+			no_wiki INTEGER NOT NULL DEFAULT 0,
 			start_time DATETIME NOT NULL,
 			end_time DATETIME,
 			status TEXT NOT NULL,
@@ -2,17 +2,30 @@ package db
 
 import "fmt"
 
+// addedColumns lists the columns added after the original schema, each with
+// the statement that adds it. Initialize creates them on a fresh database;
+// Migrate adds whichever are missing from an older one.
+var addedColumns = []struct {
+	table, column, ddl string
+}{
+	{"executions", "repo_key", `ALTER TABLE executions ADD COLUMN repo_key TEXT NOT NULL DEFAULT ''`},
+	{"executions", "skipped", `ALTER TABLE executions ADD COLUMN skipped INTEGER NOT NULL DEFAULT 0`},
+	{"executions", "no_wiki", `ALTER TABLE executions ADD COLUMN no_wiki INTEGER NOT NULL DEFAULT 0`},
+}
+
 // Migrate upgrades an existing database to the current schema. Additive-only:
-// it adds the repo_key column to executions when missing and never backfills or
+// it adds the columns in addedColumns when missing and never backfills or
 // drops data. Call once at server startup (after Initialize).
 func Migrate(d *DB) error {
-	has, err := columnExists(d, "executions", "repo_key")
-	if err != nil {
-		return fmt.Errorf("check executions.repo_key: %w", err)
-	}
-	if !has {
-		if _, err := d.Exec(`ALTER TABLE executions ADD COLUMN repo_key TEXT NOT NULL DEFAULT ''`); err != nil {
-			return fmt.Errorf("add executions.repo_key: %w", err)
+	for _, c := range addedColumns {
+		has, err := columnExists(d, c.table, c.column)
+		if err != nil {
+			return fmt.Errorf("check %s.%s: %w", c.table, c.column, err)
+		}
+		if !has {
+			if _, err := d.Exec(c.ddl); err != nil {
+				return fmt.Errorf("add %s.%s: %w", c.table, c.column, err)
+			}
 		}
 	}
 	return nil
@@ -94,6 +94,17 @@ func TestMigrateAddsRepoKeyColumn(t *testing.T) {
 	_, err = testDB.Exec(`INSERT INTO executions (id, job_name, repo_key, start_time, status) VALUES (?, ?, ?, ?, ?)`,
 		"new", "repo-b", "github.com/b/b", time.Now(), "running")
 	assert.NoError(t, err)
+
+	// The skipped column is added too, defaulting legacy rows to "not skipped".
+	var skipped bool
+	err = testDB.QueryRow(`SELECT skipped FROM executions WHERE id = 'old'`).Scan(&skipped)
+	assert.NoError(t, err)
+	assert.False(t, skipped)
+
+	var noWiki bool
+	err = testDB.QueryRow(`SELECT no_wiki FROM executions WHERE id = 'old'`).Scan(&noWiki)
+	assert.NoError(t, err)
+	assert.False(t, noWiki)
 }
 
 // TestMigrateIsIdempotent verifies Migrate on a fresh (already current) DB is a no-op.
@@ -5,20 +5,22 @@ import (
 	"fmt"
 	"os"
 	"path"
-	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/shurcooL/githubv4"
 	"github.com/wnarutou/gitrieve/internal/archive"
+	"github.com/wnarutou/gitrieve/internal/attachment"
 	"github.com/wnarutou/gitrieve/internal/config"
 	"github.com/wnarutou/gitrieve/internal/lock"
+	"github.com/wnarutou/gitrieve/internal/render"
 	"github.com/wnarutou/gitrieve/internal/retry"
 	"github.com/wnarutou/gitrieve/internal/scm"
+	"github.com/wnarutou/gitrieve/internal/scm/github"
 	"github.com/wnarutou/gitrieve/internal/storage"
+	"github.com/wnarutou/gitrieve/internal/syncstate"
 	"github.com/wnarutou/gitrieve/internal/typedef"
 	"github.com/wnarutou/gitrieve/internal/ui"
-	"golang.org/x/oauth2"
 )
 
 // Define structures for storing query results
@@ -64,82 +66,137 @@ type RepositoryData struct {
 	Discussions []DiscussionData `json:"discussions"`
 }
 
+type pageInfo struct {
+	HasNextPage bool
+	EndCursor   githubv4.String
+}
+
+type reactionGroup struct {
+	Content  string
+	Reactors struct {
+		TotalCount int
+	}
+}
+
+// userContentEdit is one revision of a discussion, comment or reply body;
+// Diff holds the body as it was before the edit.
+type userContentEdit struct {
+	EditedAt time.Time
+	Editor   struct {
+		Login string
+	}
+	Diff string
+}
+
+// replyNode is a discussion comment: the fields shared by top-level comments and
+// their replies. The edit history is fetched separately, and only for the
+// comments that were edited: nested under every comment and reply of a page,
+// it would multiply the cost of each query.
+type replyNode struct {
+	ID         string
+	DatabaseId int64
+	Author     struct {
+		Login string
+	}
+	Body           string
+	CreatedAt      time.Time
+	LastEditedAt   time.Time
+	IsAnswer       bool
+	ReactionGroups []reactionGroup
+}
+
+type replies struct {
+	Nodes    []replyNode
+	PageInfo pageInfo
+}
+
+type commentNode struct {
+	replyNode
+	Replies replies `graphql:"replies(first: 50)"`
+}
+
+type discussionNode struct {
+	ID     string
+	Author struct {
+		Login string
+	}
+	Body         string
+	Title        string
+	Number       int
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	LastEditedAt time.Time
+	Category     struct {
+		Name string
+	}
+	Locked           bool
+	ActiveLockReason string
+	IsAnswered       bool
+	AnswerChosenAt   *time.Time
+	AnswerChosenBy   *struct {
+		Login string
+	}
+	Answer *struct {
+		DatabaseId int64
+	}
+	Labels struct {
+		Nodes []struct {
+			Name string
+		}
+	} `graphql:"labels(first: 100)"`
+	Poll *struct {
+		Question       string
+		TotalVoteCount int
+		Options        struct {
+			Nodes []struct {
+				Option         string
+				TotalVoteCount int
+			}
+		} `graphql:"options(first: 100)"`
+	}
+	ReactionGroups []reactionGroup
+}
+
 // Discussion list query
 type discussionsQuery struct {
 	Repository struct {
 		Discussions struct {
-			Nodes []struct {
-				Author struct {
-					Login string
-				}
-				Body      string
-				Title     string
-				Number    int
-				CreatedAt time.Time
-				UpdatedAt time.Time
-				Category  struct {
-					Name string
-				}
-			}
-			PageInfo struct {
-				HasNextPage bool
-				EndCursor   githubv4.String
-			}
+			Nodes    []discussionNode
+			PageInfo pageInfo
 		} `graphql:"discussions(first: $discussionCount, after: $discussionCursor, orderBy: {field: UPDATED_AT, direction: DESC})"`
 	} `graphql:"repository(owner: $owner, name: $name)"`
 }
 
-// Comment query
+// Comment query, with the first page of replies of each comment
 type commentsQuery struct {
 	Repository struct {
 		Discussion struct {
 			Comments struct {
-				Nodes []struct {
-					DatabaseId int64
-					Author     struct {
-						Login string
-					}
-					Body         string
-					CreatedAt    time.Time
-					LastEditedAt time.Time
-					IsAnswer     bool
-				}
-				PageInfo struct {
-					HasNextPage bool
-					EndCursor   githubv4.String
-				}
+				Nodes    []commentNode
+				PageInfo pageInfo
 			} `graphql:"comments(first: $commentCount, after: $commentCursor)"`
 		} `graphql:"discussion(number: $number)"`
 	} `graphql:"repository(owner: $owner, name: $name)"`
 }
 
-// Reply query
+// Reply query, for the replies of a comment past the first page
 type repliesQuery struct {
-	Repository struct {
-		Discussion struct {
-			Comments struct {
-				Nodes []struct {
-					DatabaseId int64
-					Replies    struct {
-						Nodes []struct {
-							DatabaseId int64
-							Author     struct {
-								Login string
-							}
-							Body         string
-							CreatedAt    time.Time
-							LastEditedAt time.Time
-							IsAnswer     bool
-						}
-						PageInfo struct {
-							HasNextPage bool
-							EndCursor   githubv4.String
-						}
-					} `graphql:"replies(first: $replyCount, after: $replyCursor)"`
-				}
-			} `graphql:"comments(first: $commentCount, after: $commentCursor)"`
-		} `graphql:"discussion(number: $number)"`
-	} `graphql:"repository(owner: $owner, name: $name)"`
+	Node struct {
+		DiscussionComment struct {
+			Replies replies `graphql:"replies(first: $replyCount, after: $replyCursor)"`
+		} `graphql:"... on DiscussionComment"`
+	} `graphql:"node(id: $id)"`
+}
+
+// Edit history query, for a discussion, comment or reply that was edited
+type editsQuery struct {
+	Node struct {
+		UserContentEditable struct {
+			UserContentEdits struct {
+				Nodes []userContentEdit
+			} `graphql:"userContentEdits(first: 100)"`
+		} `graphql:"... on UserContentEditable"`
+	} `graphql:"node(id: $id)"`
 }
 
 func Sync(ctx context.Context, repo typedef.Repository, storages []typedef.MultiStorage) error {
@@ -202,55 +259,43 @@ func Sync(ctx context.Context, repo typedef.Repository, storages []typedef.Multi
 		}()
 	}
 
-	files, err := os.ReadDir(gitDir)
+	// The incremental cursor comes from the sync state, which a run without a
+	// cache pulls back from storage together with the archive.
+	repoDir := path.Join(workingDir, r.Host, r.Owner, repoName)
+	state, ok, err := syncstate.Load(ctx, r, syncstate.Discussions, repoDir, gitDir, storages)
 	if err != nil {
-		ui.Errorf("Error reading discussion directory: %s", err)
+		ui.Errorf("Error loading discussion sync state: %s", err)
 		return err
 	}
-
-	var lastUpdate time.Time
-	if len(files) == 0 {
-		lastUpdate = time.Unix(0, 0)
+	if !ok {
+		state = syncstate.State{}
 		ui.Printf("No discussion downloaded yet, need to download all discussions")
 	} else {
-		var updateTime time.Time
-		for _, file := range files {
-			if !strings.HasSuffix(file.Name(), ".md") {
-				continue
-			}
-
-			content, err := os.ReadFile(path.Join(gitDir, file.Name()))
-			if err != nil {
-				ui.Errorf("Error reading discussion file: %s", err)
-				return err
-			}
-
-			lines := strings.Split(string(content), "\n")
-			for _, line := range lines {
-				if strings.HasPrefix(line, "- Updated Time: ") {
-					timeStr := strings.TrimPrefix(line, "- Updated Time: ")
-					timeStr = strings.TrimSpace(timeStr)
-					updateTime, err = time.Parse("2006-01-02 15:04:05", timeStr)
-					if err != nil {
-						continue
-					}
-					break
-				}
-			}
-
-			if updateTime.After(lastUpdate) {
-				lastUpdate = updateTime
-			}
-		}
-		ui.Printf("The latest update time among all discussions is: %s", lastUpdate)
+		ui.Printf("The latest update time among all discussions is: %s", state.Cursor)
 	}
+	lastUpdate := state.Cursor
 
-	cfg := config.GetIns()
-	src := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: cfg.GitHubToken},
-	)
-	httpClient := oauth2.NewClient(context.Background(), src)
-	client := githubv4.NewClient(httpClient)
+	client, err := github.NewGraphQLClient(r.Host)
+	if err != nil {
+		ui.Errorf("Error creating github client, %s", err)
+		return err
+	}
+	attachments, err := attachment.Open(gitDir, r.Host, r.Owner, r.Name)
+	if err != nil {
+		ui.Errorf("Error opening attachments directory: %s", err)
+		return err
+	}
+	layout, err := render.Parse(render.Discussion, config.GetTemplate(render.Discussion))
+	if err != nil {
+		ui.Errorf("Error parsing discussion template: %s", err)
+		return err
+	}
+	md := markdown{layout: layout, linkify: func(body string) string {
+		if repo.RewriteAttachmentLinks {
+			return attachments.Rewrite(body)
+		}
+		return body
+	}}
 
 	// Initialize variables for discussion list query
 	discussionVariables := map[string]interface{}{
@@ -260,7 +305,8 @@ func Sync(ctx context.Context, repo typedef.Repository, storages []typedef.Multi
 		"discussionCursor": (*githubv4.String)(nil),
 	}
 
-	// Fetch discussion list
+	// Fetch discussion list, most recently updated first
+	done := false
 	for {
 		var query discussionsQuery
 		err := retry.Do(ctx, config.GetRetryConfig(), func() error {
@@ -272,177 +318,44 @@ func Sync(ctx context.Context, repo typedef.Repository, storages []typedef.Multi
 		}
 
 		for _, discussion := range query.Repository.Discussions.Nodes {
-			if discussion.UpdatedAt.Before(lastUpdate) || discussion.UpdatedAt.Equal(lastUpdate) {
-				continue
+			if !discussion.UpdatedAt.After(lastUpdate) {
+				// Everything after this one is older still.
+				done = true
+				break
 			}
 			isUpdated = true
 
-			// Initialize variables for comment query
-			commentVariables := map[string]interface{}{
-				"owner":         githubv4.String(r.Owner),
-				"name":          githubv4.String(r.Name),
-				"number":        githubv4.Int(discussion.Number),
-				"commentCount":  githubv4.Int(50),
-				"commentCursor": (*githubv4.String)(nil),
+			comments, err := fetchComments(ctx, client, r, discussion.Number)
+			if err != nil {
+				ui.Errorf("Error fetching comments for discussion %d: %s", discussion.Number, err)
+				return err
 			}
 
-			var allComments []struct {
-				DatabaseId int64
-				Author     struct {
-					Login string
-				}
-				Body         string
-				CreatedAt    time.Time
-				LastEditedAt time.Time
-				IsAnswer     bool
-				Replies      []struct {
-					DatabaseId int64
-					Author     struct {
-						Login string
-					}
-					Body         string
-					CreatedAt    time.Time
-					LastEditedAt time.Time
-					IsAnswer     bool
-				}
+			edits, err := fetchEdits(ctx, client, discussion, comments)
+			if err != nil {
+				ui.Errorf("Error fetching edit history of discussion %d: %s", discussion.Number, err)
+				return err
 			}
 
-			// Fetch comments
-			for {
-				var commentsQuery commentsQuery
-				err := retry.Do(ctx, config.GetRetryConfig(), func() error {
-					return client.Query(ctx, &commentsQuery, commentVariables)
-				})
-				if err != nil {
-					ui.Errorf("Error fetching comments for discussion %d: %s", discussion.Number, err)
-					return err
-				}
-
-				for _, comment := range commentsQuery.Repository.Discussion.Comments.Nodes {
-					// Initialize variables for reply query
-					replyVariables := map[string]interface{}{
-						"owner":         githubv4.String(r.Owner),
-						"name":          githubv4.String(r.Name),
-						"number":        githubv4.Int(discussion.Number),
-						"commentCount":  githubv4.Int(50),
-						"commentCursor": (*githubv4.String)(nil),
-						"replyCount":    githubv4.Int(50),
-						"replyCursor":   (*githubv4.String)(nil),
-					}
-
-					var allReplies []struct {
-						DatabaseId int64
-						Author     struct {
-							Login string
-						}
-						Body         string
-						CreatedAt    time.Time
-						LastEditedAt time.Time
-						IsAnswer     bool
-					}
-
-					// Fetch replies
-					for {
-						var repliesQuery repliesQuery
-						err := retry.Do(ctx, config.GetRetryConfig(), func() error {
-							return client.Query(ctx, &repliesQuery, replyVariables)
-						})
-						if err != nil {
-							ui.Errorf("Error fetching replies for comment %d: %s", comment.DatabaseId, err)
-							return err
-						}
-
-						// Find matching comment
-						for _, c := range repliesQuery.Repository.Discussion.Comments.Nodes {
-							if c.DatabaseId == comment.DatabaseId {
-								allReplies = append(allReplies, c.Replies.Nodes...)
-								if !c.Replies.PageInfo.HasNextPage {
-									break
-								}
-								replyVariables["replyCursor"] = c.Replies.PageInfo.EndCursor
-								break
-							}
-						}
-						break
-					}
-
-					allComments = append(allComments, struct {
-						DatabaseId int64
-						Author     struct {
-							Login string
-						}
-						Body         string
-						CreatedAt    time.Time
-						LastEditedAt time.Time
-						IsAnswer     bool
-						Replies      []struct {
-							DatabaseId int64
-							Author     struct {
-								Login string
-							}
-							Body         string
-							CreatedAt    time.Time
-							LastEditedAt time.Time
-							IsAnswer     bool
-						}
-					}{
-						DatabaseId:   comment.DatabaseId,
-						Author:       comment.Author,
-						Body:         comment.Body,
-						CreatedAt:    comment.CreatedAt,
-						LastEditedAt: comment.LastEditedAt,
-						IsAnswer:     comment.IsAnswer,
-						Replies:      allReplies,
-					})
-				}
-
-				if !commentsQuery.Repository.Discussion.Comments.PageInfo.HasNextPage {
-					break
+			bodies := []string{discussion.Body}
+			for _, comment := range comments {
+				bodies = append(bodies, comment.Body)
+				for _, reply := range comment.Replies.Nodes {
+					bodies = append(bodies, reply.Body)
 				}
-				commentVariables["commentCursor"] = commentsQuery.Repository.Discussion.Comments.PageInfo.EndCursor
+			}
+			if err := attachments.Fetch(ctx, bodies...); err != nil {
+				ui.Errorf("Error downloading attachments of discussion %d: %s", discussion.Number, err)
+				return err
 			}
 
 			// Write to file
 			discussionFileName := fmt.Sprintf("%d.md", discussion.Number)
 			discussionFilePath := path.Join(gitDir, discussionFileName)
-
-			var content string
-			content += fmt.Sprintf("# Discussion: %s\n\n", discussion.Title)
-			content += "## Basic Information\n\n"
-			content += fmt.Sprintf("- Created Time: %s\n", discussion.CreatedAt.Format("2006-01-02 15:04:05"))
-			content += fmt.Sprintf("- Updated Time: %s\n", discussion.UpdatedAt.Format("2006-01-02 15:04:05"))
-			content += fmt.Sprintf("- Category: %s\n", discussion.Category.Name)
-			content += fmt.Sprintf("- Author: %s\n", discussion.Author.Login)
-			content += fmt.Sprintf("- Comment Count: %d\n\n", len(allComments))
-
-			content += "## Content\n\n"
-			content += "```\n"
-			content += discussion.Body + "\n"
-			content += "```\n\n"
-
-			if len(allComments) > 0 {
-				content += "## Comments\n\n"
-				for _, comment := range allComments {
-					content += fmt.Sprintf("### Comment #%d\n\n", comment.DatabaseId)
-					content += "```\n"
-					content += comment.Body + "\n"
-					content += "```\n\n"
-					content += fmt.Sprintf("- Author: %s\n", comment.Author.Login)
-					content += fmt.Sprintf("- Created Time: %s\n", comment.CreatedAt.Format("2006-01-02 15:04:05"))
-					content += fmt.Sprintf("- Updated Time: %s\n\n", comment.LastEditedAt.Format("2006-01-02 15:04:05"))
-					content += "---\n\n"
-
-					for _, reply := range comment.Replies {
-						content += fmt.Sprintf("#### Reply #%d\n\n", reply.DatabaseId)
-						content += "```\n"
-						content += reply.Body + "\n"
-						content += "```\n\n"
-						content += fmt.Sprintf("- Author: %s\n", reply.Author.Login)
-						content += fmt.Sprintf("- Created Time: %s\n", reply.CreatedAt.Format("2006-01-02 15:04:05"))
-						content += fmt.Sprintf("- Updated Time: %s\n\n", reply.LastEditedAt.Format("2006-01-02 15:04:05"))
-						content += "---\n\n"
-					}
-				}
+			content, err := renderMarkdown(discussion, comments, edits, md)
+			if err != nil {
+				ui.Errorf("Error rendering discussion %d: %s", discussion.Number, err)
+				return err
 			}
 
 			err = os.WriteFile(discussionFilePath, []byte(content), 0644)
@@ -451,12 +364,15 @@ func Sync(ctx context.Context, repo typedef.Repository, storages []typedef.Multi
 				return err
 			}
 			ui.Printf("Success writing discussion %s to file %s", discussion.Title, discussionFilePath)
+			if discussion.UpdatedAt.After(state.Cursor) {
+				state.Cursor = discussion.UpdatedAt
+			}
 			if ctx.Err() != nil {
 				return ctx.Err()
 			}
 		}
 
-		if !query.Repository.Discussions.PageInfo.HasNextPage {
+		if done || !query.Repository.Discussions.PageInfo.HasNextPage {
 			break
 		}
 		discussionVariables["discussionCursor"] = query.Repository.Discussions.PageInfo.EndCursor
@@ -491,5 +407,94 @@ func Sync(ctx context.Context, repo typedef.Repository, storages []typedef.Multi
 		ui.Printf("All is up to date, no need to restore")
 	}
 
+	if isUpdated {
+		if !useCache {
+			repoDir = ""
+		}
+		if err := syncstate.Save(r, syncstate.Discussions, repoDir, state, storages); err != nil {
+			ui.Errorf("Error saving discussion sync state: %s", err)
+			return err
+		}
+	}
+
 	return nil
 }
+
+// fetchComments returns every comment of discussion number with all of its
+// replies.
+func fetchComments(ctx context.Context, client *githubv4.Client, r *scm.Repository, number int) ([]commentNode, error) {
+	variables := map[string]interface{}{
+		"owner":         githubv4.String(r.Owner),
+		"name":          githubv4.String(r.Name),
+		"number":        githubv4.Int(number),
+		"commentCount":  githubv4.Int(50),
+		"commentCursor": (*githubv4.String)(nil),
+	}
+	var comments []commentNode
+	for {
+		var query commentsQuery
+		err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+			return client.Query(ctx, &query, variables)
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, comment := range query.Repository.Discussion.Comments.Nodes {
+			// Page in the replies past the first page that came nested.
+			for comment.Replies.PageInfo.HasNextPage {
+				var query repliesQuery
+				replyVariables := map[string]interface{}{
+					"id":          githubv4.ID(comment.ID),
+					"replyCount":  githubv4.Int(50),
+					"replyCursor": githubv4.NewString(comment.Replies.PageInfo.EndCursor),
+				}
+				err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+					return client.Query(ctx, &query, replyVariables)
+				})
+				if err != nil {
+					return nil, fmt.Errorf("fetch replies of comment %d: %w", comment.DatabaseId, err)
+				}
+				page := query.Node.DiscussionComment.Replies
+				comment.Replies.Nodes = append(comment.Replies.Nodes, page.Nodes...)
+				comment.Replies.PageInfo = page.PageInfo
+			}
+			comments = append(comments, comment)
+		}
+		if !query.Repository.Discussion.Comments.PageInfo.HasNextPage {
+			return comments, nil
+		}
+		variables["commentCursor"] = githubv4.NewString(query.Repository.Discussion.Comments.PageInfo.EndCursor)
+	}
+}
+
+// fetchEdits returns the edit history of the discussion and of its comments
+// and replies by node ID, querying only the ones that were edited.
+func fetchEdits(ctx context.Context, client *githubv4.Client, discussion discussionNode, comments []commentNode) (map[string][]userContentEdit, error) {
+	edited := map[string]bool{}
+	if !discussion.LastEditedAt.IsZero() {
+		edited[discussion.ID] = true
+	}
+	for _, comment := range comments {
+		if !comment.LastEditedAt.IsZero() {
+			edited[comment.ID] = true
+		}
+		for _, reply := range comment.Replies.Nodes {
+			if !reply.LastEditedAt.IsZero() {
+				edited[reply.ID] = true
+			}
+		}
+	}
+	edits := make(map[string][]userContentEdit, len(edited))
+	for id := range edited {
+		var query editsQuery
+		variables := map[string]interface{}{"id": githubv4.ID(id)}
+		err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+			return client.Query(ctx, &query, variables)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("fetch edits of %s: %w", id, err)
+		}
+		edits[id] = query.Node.UserContentEditable.UserContentEdits.Nodes
+	}
+	return edits, nil
+}
@@ -2,13 +2,19 @@ package discussion
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
 	"os"
+	"path"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/wnarutou/gitrieve/internal/lock"
 	"github.com/wnarutou/gitrieve/internal/scm"
+	"github.com/wnarutou/gitrieve/internal/scm/github/githubtest"
 	"github.com/wnarutou/gitrieve/internal/typedef"
 )
 
@@ -34,3 +40,87 @@ func TestSyncBlocksWhileLockHeld(t *testing.T) {
 	err = Sync(ctx, repo, nil)
 	require.Equal(t, context.DeadlineExceeded, err, "discussion Sync must block on the held discussion lock")
 }
+
+func TestSyncStopsAtCursorAndRendersMetadata(t *testing.T) {
+	discussions := []string{`{"number":1,"title":"Old","body":"old","updatedAt":"2026-08-17T01:00:00Z","category":{"name":"Q&A"}}`}
+	var commentQueries []interface{}
+	var replyPages int
+	var editQueries []interface{}
+	host := githubtest.Serve(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var req struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		switch {
+		case strings.Contains(req.Query, "discussions("):
+			// Newest first; a second page exists but must not be needed.
+			var nodes []string
+			for i := len(discussions) - 1; i >= 0; i-- {
+				nodes = append(nodes, discussions[i])
+			}
+			fmt.Fprintf(w, `{"data":{"repository":{"discussions":{"pageInfo":{"hasNextPage":%t,"endCursor":"d1"},"nodes":[%s]}}}}`,
+				len(discussions) > 1, strings.Join(nodes, ","))
+		case strings.Contains(req.Query, "comments("):
+			commentQueries = append(commentQueries, req.Variables["number"])
+			if req.Variables["number"] != float64(2) {
+				fmt.Fprint(w, `{"data":{"repository":{"discussion":{"comments":{"pageInfo":{"hasNextPage":false},"nodes":[]}}}}}`)
+				return
+			}
+			fmt.Fprint(w, `{"data":{"repository":{"discussion":{"comments":{"pageInfo":{"hasNextPage":false},"nodes":[
+				{"id":"DC_10","databaseId":10,"author":{"login":"bob"},"body":"use v2","isAnswer":true,
+					"lastEditedAt":"2026-08-18T01:05:00Z","reactionGroups":[{"content":"HEART","reactors":{"totalCount":1}}],
+					"replies":{"pageInfo":{"hasNextPage":true,"endCursor":"r1"},"nodes":[
+						{"id":"DC_11","databaseId":11,"author":{"login":"alice"},"body":"thanks"}]}}]}}}}}`)
+		case strings.Contains(req.Query, "userContentEdits("):
+			editQueries = append(editQueries, req.Variables["id"])
+			edits := map[interface{}]string{
+				"D_2":   `{"editedAt":"2026-08-18T00:30:00Z","editor":{"login":"alice"},"diff":"hlep"}`,
+				"DC_10": `{"editedAt":"2026-08-18T01:05:00Z","editor":{"login":"bob"},"diff":"use v1"}`,
+			}
+			fmt.Fprintf(w, `{"data":{"node":{"userContentEdits":{"nodes":[%s]}}}}`, edits[req.Variables["id"]])
+		case strings.Contains(req.Query, "node("):
+			replyPages++
+			require.Equal(t, "DC_10", req.Variables["id"])
+			require.Equal(t, "r1", req.Variables["replyCursor"])
+			fmt.Fprint(w, `{"data":{"node":{"replies":{"pageInfo":{"hasNextPage":false},"nodes":[
+				{"id":"DC_12","databaseId":12,"author":{"login":"carol"},"body":"same here"}]}}}}`)
+		default:
+			t.Errorf("unexpected query %s", req.Query)
+		}
+	}))
+	repo := typedef.Repository{URL: host + "/owner/repo", UseCache: true}
+	dir := path.Join(".gitrieve", host, "owner", "repo", "discussion")
+
+	require.NoError(t, Sync(context.Background(), repo, nil))
+	discussions = append(discussions, `{"id":"D_2","number":2,"title":"How to upgrade?","body":"help","updatedAt":"2026-08-18T01:00:00Z",
+		"lastEditedAt":"2026-08-18T00:30:00Z",
+		"category":{"name":"Q&A"},"locked":true,"activeLockReason":"TOO_HEATED",
+		"isAnswered":true,"answerChosenAt":"2026-08-18T02:00:00Z","answerChosenBy":{"login":"alice"},"answer":{"databaseId":10},
+		"labels":{"nodes":[{"name":"question"}]},
+		"poll":{"question":"Which version?","totalVoteCount":3,"options":{"nodes":[{"option":"v1","totalVoteCount":1},{"option":"v2","totalVoteCount":2}]}},
+		"reactionGroups":[{"content":"THUMBS_UP","reactors":{"totalCount":2}}]}`)
+	require.NoError(t, Sync(context.Background(), repo, nil))
+
+	require.Equal(t, []interface{}{float64(1), float64(2)}, commentQueries, "the second run skips the discussion older than the cursor")
+	require.Equal(t, 1, replyPages)
+	require.ElementsMatch(t, []interface{}{"D_2", "DC_10"}, editQueries, "only edited nodes have their history fetched")
+	md, err := os.ReadFile(path.Join(dir, "2.md"))
+	require.NoError(t, err)
+	for _, line := range []string{
+		"labels:\n    - question\n",
+		"answer: 10\nanswer_chosen_by: alice\nanswer_chosen_at: 2026-08-18T02:00:00Z\n",
+		"locked: true\nlock_reason: too heated\n",
+		"reactions:\n    \"+1\": 2\n",
+		"**Edit History**\n\n- 2026-08-18 00:30:00 alice\n\n```\nhlep\n```\n",
+		"## Poll\n\nWhich version?\n\n- v1: 1\n- v2: 2\n- Total Votes: 3\n",
+		"### Comment #10\n",
+		"- Answer: yes\n- Reactions: heart 1\n",
+		"- 2026-08-18 01:05:00 bob\n\n```\nuse v1\n```\n",
+		"#### Reply #11\n",
+		"#### Reply #12\n",
+	} {
+		require.Contains(t, string(md), line)
+	}
+}
@@ -0,0 +1,138 @@
+package discussion
+
+import (
+	"strings"
+	"text/template"
+
+	"github.com/wnarutou/gitrieve/internal/render"
+)
+
+// markdown holds what rendering a discussion needs besides the discussion: the
+// layout, and linkify, which is applied to every body, e.g. to point
+// attachment links at local copies.
+type markdown struct {
+	layout  *template.Template
+	linkify func(string) string
+}
+
+// renderMarkdown renders the human-oriented view of one discussion. history
+// holds the edits of the discussion, comments and replies by node ID.
+func renderMarkdown(discussion discussionNode, comments []commentNode, history map[string][]userContentEdit, md markdown) (string, error) {
+	doc := render.Document{
+		Kind:   "Discussion",
+		Number: discussion.Number,
+		Title:  discussion.Title,
+		Body:   md.linkify(discussion.Body),
+		Edits:  edits(history[discussion.ID]),
+	}
+	doc.Meta = render.Meta{
+		{Key: "number", Value: discussion.Number},
+		{Key: "title", Value: discussion.Title},
+		{Key: "category", Value: discussion.Category.Name},
+		{Key: "author", Value: discussion.Author.Login},
+		{Key: "created_at", Value: discussion.CreatedAt},
+		{Key: "updated_at", Value: discussion.UpdatedAt},
+	}
+	var labels []string
+	for _, label := range discussion.Labels.Nodes {
+		labels = append(labels, label.Name)
+	}
+	if len(labels) > 0 {
+		doc.Meta = append(doc.Meta, render.Field{Key: "labels", Value: labels})
+	}
+	if discussion.IsAnswered && discussion.Answer != nil {
+		doc.Meta = append(doc.Meta, render.Field{Key: "answer", Value: discussion.Answer.DatabaseId})
+		if discussion.AnswerChosenBy != nil {
+			doc.Meta = append(doc.Meta, render.Field{Key: "answer_chosen_by", Value: discussion.AnswerChosenBy.Login})
+		}
+		if discussion.AnswerChosenAt != nil {
+			doc.Meta = append(doc.Meta, render.Field{Key: "answer_chosen_at", Value: *discussion.AnswerChosenAt})
+		}
+	}
+	if discussion.Locked {
+		doc.Meta = append(doc.Meta, render.Field{Key: "locked", Value: true})
+		if reason := discussion.ActiveLockReason; reason != "" {
+			doc.Meta = append(doc.Meta, render.Field{Key: "lock_reason", Value: lockReason(reason)})
+		}
+	}
+	if reactions := reactionCounts(discussion.ReactionGroups); reactions != nil {
+		doc.Meta = append(doc.Meta, render.Field{Key: "reactions", Value: reactions})
+	}
+	doc.Meta = append(doc.Meta, render.Field{Key: "comments", Value: len(comments)})
+
+	if poll := discussion.Poll; poll != nil {
+		doc.Poll = &render.Poll{Question: poll.Question, Total: poll.TotalVoteCount}
+		for _, option := range poll.Options.Nodes {
+			doc.Poll.Options = append(doc.Poll.Options, render.PollOption{Option: option.Option, Votes: option.TotalVoteCount})
+		}
+	}
+
+	for _, comment := range comments {
+		c := renderComment("Comment", comment.replyNode, history[comment.ID], md)
+		for _, reply := range comment.Replies.Nodes {
+			c.Replies = append(c.Replies, renderComment("Reply", reply, history[reply.ID], md))
+		}
+		doc.Comments = append(doc.Comments, c)
+	}
+	return render.Render(md.layout, render.Discussion, doc)
+}
+
+func renderComment(kind string, c replyNode, history []userContentEdit, md markdown) render.Comment {
+	return render.Comment{
+		Kind:      kind,
+		ID:        c.DatabaseId,
+		Author:    c.Author.Login,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.LastEditedAt,
+		Body:      md.linkify(c.Body),
+		Answer:    c.IsAnswer,
+		Reactions: reactionCounts(c.ReactionGroups),
+		Edits:     edits(history),
+	}
+}
+
+func edits(nodes []userContentEdit) []render.Edit {
+	var edits []render.Edit
+	for _, edit := range nodes {
+		edits = append(edits, render.Edit{EditedAt: edit.EditedAt, Editor: edit.Editor.Login, Body: edit.Diff})
+	}
+	return edits
+}
+
+// lockReason spells a lock reason like the REST API does for issues.
+func lockReason(reason string) string {
+	switch reason {
+	case "OFF_TOPIC":
+		return "off-topic"
+	default:
+		return strings.ReplaceAll(strings.ToLower(reason), "_", " ")
+	}
+}
+
+// reactionNames orders and names the reactions like the issue markdown.
+var reactionNames = []struct{ content, name string }{
+	{"THUMBS_UP", "+1"},
+	{"THUMBS_DOWN", "-1"},
+	{"LAUGH", "laugh"},
+	{"CONFUSED", "confused"},
+	{"HEART", "heart"},
+	{"HOORAY", "hooray"},
+	{"ROCKET", "rocket"},
+	{"EYES", "eyes"},
+}
+
+// reactionCounts lists the reaction counts in GitHub's order, or nil when
+// there are none.
+func reactionCounts(groups []reactionGroup) render.Meta {
+	counts := map[string]int{}
+	for _, g := range groups {
+		counts[g.Content] = g.Reactors.TotalCount
+	}
+	var meta render.Meta
+	for _, r := range reactionNames {
+		if n := counts[r.content]; n > 0 {
+			meta = append(meta, render.Field{Key: r.name, Value: n})
+		}
+	}
+	return meta
+}
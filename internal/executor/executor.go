@@ -5,13 +5,18 @@ import (
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/wnarutou/gitrieve/internal/actions"
 	"github.com/wnarutou/gitrieve/internal/config"
 	"github.com/wnarutou/gitrieve/internal/db"
 	"github.com/wnarutou/gitrieve/internal/discussion"
 	"github.com/wnarutou/gitrieve/internal/issue"
 	"github.com/wnarutou/gitrieve/internal/logger"
+	"github.com/wnarutou/gitrieve/internal/metadata"
+	"github.com/wnarutou/gitrieve/internal/project"
+	"github.com/wnarutou/gitrieve/internal/pull"
 	"github.com/wnarutou/gitrieve/internal/release"
 	"github.com/wnarutou/gitrieve/internal/repository"
+	"github.com/wnarutou/gitrieve/internal/security"
 	"github.com/wnarutou/gitrieve/internal/typedef"
 	"github.com/wnarutou/gitrieve/internal/ui"
 	"github.com/wnarutou/gitrieve/internal/wiki"
@@ -169,14 +174,20 @@ func (e *Executor) executeAsync(ctx context.Context, jobID string, job typedef.R
 	// partial archive is still attempted. When the caller cancels, Sync returns
 	// promptly with the context error; skip the "failed" log in that case.
 	codeErr := repository.Sync(ctx, job, false, storages)
+	if errors.Is(codeErr, repository.ErrUnchanged) {
+		// Nothing moved upstream: the sync already logged the skip. Record it
+		// so the repository stats can count skipped runs, then carry on.
+		e.markSkipped(jobID)
+		codeErr = nil
+	}
 	if codeErr != nil && ctx.Err() == nil {
 		ui.Errorf("Code sync failed: %v", codeErr)
 	}
 
 	// Download the configured metadata/content components. Best-effort: a
-	// component may legitimately fail (e.g. a repo with no wiki), so failures
+	// component may legitimately fail (e.g. a token without access), so failures
 	// are logged as errors but the job status reflects only the code sync.
-	e.downloadComponents(ctx, job, storages)
+	e.downloadComponents(ctx, jobID, job, storages)
 
 	if ctx.Err() != nil {
 		// Final log line before the terminal status update (see note above).
@@ -194,10 +205,12 @@ func (e *Executor) executeAsync(ctx context.Context, jobID string, job typedef.R
 }
 
 // downloadComponents runs the per-repository metadata/content syncs enabled in
-// the config (releases, issues, wiki, discussions), mirroring what the daemon
-// schedules. Each runs independently; progress and failures are logged via ui
-// so they surface in the job's log stream.
-func (e *Executor) downloadComponents(ctx context.Context, job typedef.Repository, storages []typedef.MultiStorage) {
+// the config (releases, issues, pull requests, wiki, discussions, actions,
+// metadata, projects, security), mirroring what the daemon schedules. Each runs
+// independently; progress and failures are logged via ui so they surface in
+// the job's log stream. A repository without a wiki is recorded on the
+// execution rather than logged as a failure.
+func (e *Executor) downloadComponents(ctx context.Context, jobID string, job typedef.Repository, storages []typedef.MultiStorage) {
 	run := func(name string, enabled bool, fn func() error) {
 		if !enabled || ctx.Err() != nil {
 			return
@@ -213,8 +226,20 @@ func (e *Executor) downloadComponents(ctx context.Context, job typedef.Repositor
 	}
 	run("releases", job.DownloadReleases, func() error { return release.DownloadAllAssets(ctx, job, storages) })
 	run("issues", job.DownloadIssues, func() error { return issue.Sync(ctx, job, storages) })
-	run("wiki", job.DownloadWiki, func() error { return wiki.Sync(ctx, job, storages) })
+	run("pull requests", job.DownloadPullRequests, func() error { return pull.Sync(ctx, job, storages) })
+	run("wiki", job.DownloadWiki, func() error {
+		err := wiki.Sync(ctx, job, storages)
+		if errors.Is(err, repository.ErrNoWiki) {
+			e.markNoWiki(jobID)
+			return nil
+		}
+		return err
+	})
 	run("discussion", job.DownloadDiscussion, func() error { return discussion.Sync(ctx, job, storages) })
+	run("actions", job.DownloadActions, func() error { return actions.Sync(ctx, job, storages) })
+	run("metadata", job.DownloadMetadata, func() error { return metadata.Sync(ctx, job, storages) })
+	run("projects", job.DownloadProjects, func() error { return project.Sync(ctx, job, storages) })
+	run("security", job.DownloadSecurity, func() error { return security.Sync(ctx, job, storages) })
 }
 
 func (e *Executor) CancelJob(jobID string) error {
@@ -251,6 +276,21 @@ func (e *Executor) updateJobStatus(jobID string, status string, errorMessage str
 	return err
 }
 
+// markSkipped flags an execution whose code sync was skipped because the
+// remote refs were unchanged.
+func (e *Executor) markSkipped(jobID string) {
+	if _, err := e.db.Exec(`UPDATE executions SET skipped = 1 WHERE id = ?`, jobID); err != nil {
+		ui.Errorf("Failed to record skipped run: %v", err)
+	}
+}
+
+// markNoWiki flags an execution whose wiki sync found no wiki to archive.
+func (e *Executor) markNoWiki(jobID string) {
+	if _, err := e.db.Exec(`UPDATE executions SET no_wiki = 1 WHERE id = ?`, jobID); err != nil {
+		ui.Errorf("Failed to record missing wiki: %v", err)
+	}
+}
+
 func (e *Executor) IsJobRunning(jobID string) bool {
 	e.mu.RLock()
 	_, exists := e.runningJobs[jobID]
@@ -13,6 +13,22 @@ import (
 	"github.com/wnarutou/gitrieve/internal/typedef"
 )
 
+// TestMain runs the package's tests from a scratch directory: the jobs they
+// start sync into ./.gitrieve and lock there, possibly after the test that
+// started them returned, and must not leave that behind in the source tree.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "gitrieve-executor-test")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 func newTestExecutor(t *testing.T) (*Executor, *db.DB) {
 	t.Helper()
 	testDB, err := db.Initialize(":memory:")
@@ -0,0 +1,552 @@
+package issue
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+	"github.com/wnarutou/gitrieve/internal/attachment"
+	"github.com/wnarutou/gitrieve/internal/config"
+	"github.com/wnarutou/gitrieve/internal/retry"
+	"github.com/wnarutou/gitrieve/internal/scm"
+	"github.com/wnarutou/gitrieve/internal/typedef"
+	"github.com/wnarutou/gitrieve/internal/ui"
+)
+
+// The GraphQL sync fetches issues and pull requests in batches of
+// graphQLBatch, each with its first 100 comments and timeline events nested,
+// so an issue costs a share of one request instead of three REST calls.
+// Longer comment threads and timelines are paged per issue.
+const graphQLBatch = 25
+
+type gqlActor struct {
+	Login string
+}
+
+type gqlReactionGroup struct {
+	Content  string
+	Reactors struct {
+		TotalCount int
+	}
+}
+
+type gqlPageInfo struct {
+	HasNextPage bool
+	EndCursor   githubv4.String
+}
+
+type gqlComment struct {
+	ID             string
+	DatabaseID     int64 `graphql:"databaseId"`
+	Author         gqlActor
+	Body           string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	ReactionGroups []gqlReactionGroup
+}
+
+type gqlComments struct {
+	Nodes    []gqlComment
+	PageInfo gqlPageInfo
+}
+
+type gqlEvent struct {
+	Actor     gqlActor
+	CreatedAt time.Time
+}
+
+type gqlRepositoryRef struct {
+	Number     int
+	Repository struct {
+		NameWithOwner string
+	}
+}
+
+// gqlTimelineItem is one timeline event; only the fragment matching
+// Typename is filled.
+type gqlTimelineItem struct {
+	Typename    string `graphql:"__typename"`
+	ClosedEvent struct {
+		gqlEvent
+		Closer struct {
+			Commit struct {
+				Oid string
+			} `graphql:"... on Commit"`
+		}
+	} `graphql:"... on ClosedEvent"`
+	ReopenedEvent struct {
+		gqlEvent
+	} `graphql:"... on ReopenedEvent"`
+	LabeledEvent struct {
+		gqlEvent
+		Label struct {
+			Name string
+		}
+	} `graphql:"... on LabeledEvent"`
+	UnlabeledEvent struct {
+		gqlEvent
+		Label struct {
+			Name string
+		}
+	} `graphql:"... on UnlabeledEvent"`
+	AssignedEvent struct {
+		gqlEvent
+		Assignee struct {
+			User gqlActor `graphql:"... on User"`
+		}
+	} `graphql:"... on AssignedEvent"`
+	UnassignedEvent struct {
+		gqlEvent
+		Assignee struct {
+			User gqlActor `graphql:"... on User"`
+		}
+	} `graphql:"... on UnassignedEvent"`
+	MilestonedEvent struct {
+		gqlEvent
+		MilestoneTitle string
+	} `graphql:"... on MilestonedEvent"`
+	DemilestonedEvent struct {
+		gqlEvent
+		MilestoneTitle string
+	} `graphql:"... on DemilestonedEvent"`
+	RenamedTitleEvent struct {
+		gqlEvent
+		PreviousTitle string
+		CurrentTitle  string
+	} `graphql:"... on RenamedTitleEvent"`
+	CrossReferencedEvent struct {
+		gqlEvent
+		Source struct {
+			Issue       gqlRepositoryRef `graphql:"... on Issue"`
+			PullRequest gqlRepositoryRef `graphql:"... on PullRequest"`
+		}
+	} `graphql:"... on CrossReferencedEvent"`
+	ReferencedEvent struct {
+		gqlEvent
+		Commit struct {
+			Oid string
+		}
+	} `graphql:"... on ReferencedEvent"`
+	TransferredEvent struct {
+		gqlEvent
+	} `graphql:"... on TransferredEvent"`
+	LockedEvent struct {
+		gqlEvent
+	} `graphql:"... on LockedEvent"`
+	UnlockedEvent struct {
+		gqlEvent
+	} `graphql:"... on UnlockedEvent"`
+}
+
+type gqlTimeline struct {
+	Nodes    []gqlTimelineItem
+	PageInfo gqlPageInfo
+}
+
+// gqlIssue is an issue or a pull request; both expose the same fields.
+type gqlIssue struct {
+	ID               string
+	Number           int
+	Title            string
+	Body             string
+	State            string
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Author           gqlActor
+	Locked           bool
+	ActiveLockReason string
+	Milestone        *struct {
+		Title string
+	}
+	Labels struct {
+		Nodes []struct {
+			Name string
+		}
+	} `graphql:"labels(first: 100)"`
+	Assignees struct {
+		Nodes []gqlActor
+	} `graphql:"assignees(first: 100)"`
+	ReactionGroups []gqlReactionGroup
+	Comments       gqlComments `graphql:"comments(first: 100)"`
+	TimelineItems  gqlTimeline `graphql:"timelineItems(first: 100, itemTypes: [CLOSED_EVENT, REOPENED_EVENT, LABELED_EVENT, UNLABELED_EVENT, ASSIGNED_EVENT, UNASSIGNED_EVENT, MILESTONED_EVENT, DEMILESTONED_EVENT, RENAMED_TITLE_EVENT, CROSS_REFERENCED_EVENT, REFERENCED_EVENT, TRANSFERRED_EVENT, LOCKED_EVENT, UNLOCKED_EVENT])"`
+}
+
+type gqlIssuesQuery struct {
+	Repository struct {
+		Issues struct {
+			Nodes    []gqlIssue
+			PageInfo gqlPageInfo
+		} `graphql:"issues(first: $count, after: $cursor, orderBy: {field: UPDATED_AT, direction: ASC}, filterBy: {since: $since})"`
+	} `graphql:"repository(owner: $owner, name: $name)"`
+}
+
+// Pull requests cannot be filtered by update time: walk them newest first and
+// stop at the cursor.
+type gqlPullRequestsQuery struct {
+	Repository struct {
+		PullRequests struct {
+			Nodes    []gqlIssue
+			PageInfo gqlPageInfo
+		} `graphql:"pullRequests(first: $count, after: $cursor, orderBy: {field: UPDATED_AT, direction: DESC})"`
+	} `graphql:"repository(owner: $owner, name: $name)"`
+}
+
+type gqlCommentsPageQuery struct {
+	Node struct {
+		Issue struct {
+			Comments gqlComments `graphql:"comments(first: 100, after: $cursor)"`
+		} `graphql:"... on Issue"`
+		PullRequest struct {
+			Comments gqlComments `graphql:"comments(first: 100, after: $cursor)"`
+		} `graphql:"... on PullRequest"`
+	} `graphql:"node(id: $id)"`
+}
+
+type gqlTimelinePageQuery struct {
+	Node struct {
+		Issue struct {
+			TimelineItems gqlTimeline `graphql:"timelineItems(first: 100, after: $cursor, itemTypes: [CLOSED_EVENT, REOPENED_EVENT, LABELED_EVENT, UNLABELED_EVENT, ASSIGNED_EVENT, UNASSIGNED_EVENT, MILESTONED_EVENT, DEMILESTONED_EVENT, RENAMED_TITLE_EVENT, CROSS_REFERENCED_EVENT, REFERENCED_EVENT, TRANSFERRED_EVENT, LOCKED_EVENT, UNLOCKED_EVENT])"`
+		} `graphql:"... on Issue"`
+		PullRequest struct {
+			TimelineItems gqlTimeline `graphql:"timelineItems(first: 100, after: $cursor, itemTypes: [CLOSED_EVENT, REOPENED_EVENT, LABELED_EVENT, UNLABELED_EVENT, ASSIGNED_EVENT, UNASSIGNED_EVENT, MILESTONED_EVENT, DEMILESTONED_EVENT, RENAMED_TITLE_EVENT, CROSS_REFERENCED_EVENT, REFERENCED_EVENT, TRANSFERRED_EVENT, LOCKED_EVENT, UNLOCKED_EVENT])"`
+		} `graphql:"... on PullRequest"`
+	} `graphql:"node(id: $id)"`
+}
+
+// syncGraphQL is the GraphQL counterpart of the REST loop in Sync: it stores
+// every issue and pull request updated after lastUpdate, advancing
+// *cursor, and reports whether it stored any.
+func syncGraphQL(ctx context.Context, client *githubv4.Client, r *scm.Repository, dir string, lastUpdate time.Time,
+	attachments *attachment.Store, md markdown, cursor *time.Time) (bool, error) {
+	updated := false
+	store := func(issue *gqlIssue, isPR bool) error {
+		if err := completeIssue(ctx, client, issue); err != nil {
+			ui.Errorf("Error fetching comments and timeline of issue %d, %s", issue.Number, err)
+			return err
+		}
+		prev, err := readRecord(dir, issue.Number)
+		if err != nil {
+			ui.Errorf("Error reading issue file #%d.json, %s", issue.Number, err)
+			return err
+		}
+		rec, comments, err := issue.record(isPR)
+		if err != nil {
+			ui.Errorf("Error encoding issue %d, %s", issue.Number, err)
+			return err
+		}
+		if err := saveIssue(ctx, dir, attachments, md, issue.Number, rec, comments, prev); err != nil {
+			ui.Errorf("Error writing issue #%d, %s", issue.Number, err)
+			return err
+		}
+		ui.Printf("Success writing issue #%d", issue.Number)
+		updated = true
+		if issue.UpdatedAt.After(*cursor) {
+			*cursor = issue.UpdatedAt
+		}
+		return ctx.Err()
+	}
+
+	var since *githubv4.DateTime
+	if !lastUpdate.IsZero() {
+		since = &githubv4.DateTime{Time: lastUpdate.UTC()}
+	}
+	variables := map[string]interface{}{
+		"owner":  githubv4.String(r.Owner),
+		"name":   githubv4.String(r.Name),
+		"count":  githubv4.Int(graphQLBatch),
+		"cursor": (*githubv4.String)(nil),
+		"since":  since,
+	}
+	for {
+		var query gqlIssuesQuery
+		err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+			return client.Query(ctx, &query, variables)
+		})
+		if err != nil {
+			ui.Errorf("Error fetching issues, %s", err)
+			return updated, err
+		}
+		issues := query.Repository.Issues
+		ui.Printf("Fetched %d issues", len(issues.Nodes))
+		for i := range issues.Nodes {
+			if err := store(&issues.Nodes[i], false); err != nil {
+				return updated, err
+			}
+		}
+		if !issues.PageInfo.HasNextPage {
+			break
+		}
+		variables["cursor"] = githubv4.NewString(issues.PageInfo.EndCursor)
+	}
+
+	delete(variables, "since")
+	variables["cursor"] = (*githubv4.String)(nil)
+	var pending []gqlIssue
+	for done := false; !done; {
+		var query gqlPullRequestsQuery
+		err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+			return client.Query(ctx, &query, variables)
+		})
+		if err != nil {
+			ui.Errorf("Error fetching pull requests, %s", err)
+			return updated, err
+		}
+		pulls := query.Repository.PullRequests
+		ui.Printf("Fetched %d pull requests", len(pulls.Nodes))
+		for _, pr := range pulls.Nodes {
+			if !lastUpdate.IsZero() && !pr.UpdatedAt.After(lastUpdate) {
+				done = true
+				break
+			}
+			pending = append(pending, pr)
+		}
+		if !pulls.PageInfo.HasNextPage {
+			break
+		}
+		variables["cursor"] = githubv4.NewString(pulls.PageInfo.EndCursor)
+	}
+	// Store oldest-updated first, like the issues.
+	for i := len(pending) - 1; i >= 0; i-- {
+		if err := store(&pending[i], true); err != nil {
+			return updated, err
+		}
+	}
+	return updated, nil
+}
+
+// completeIssue pages in the comments and timeline events past the first 100
+// that came nested in the batch.
+func completeIssue(ctx context.Context, client *githubv4.Client, issue *gqlIssue) error {
+	for issue.Comments.PageInfo.HasNextPage {
+		var query gqlCommentsPageQuery
+		variables := map[string]interface{}{
+			"id":     githubv4.ID(issue.ID),
+			"cursor": githubv4.NewString(issue.Comments.PageInfo.EndCursor),
+		}
+		if err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+			return client.Query(ctx, &query, variables)
+		}); err != nil {
+			return err
+		}
+		page := query.Node.Issue.Comments
+		if len(query.Node.PullRequest.Comments.Nodes) > 0 || query.Node.PullRequest.Comments.PageInfo.HasNextPage {
+			page = query.Node.PullRequest.Comments
+		}
+		issue.Comments.Nodes = append(issue.Comments.Nodes, page.Nodes...)
+		issue.Comments.PageInfo = page.PageInfo
+	}
+	for issue.TimelineItems.PageInfo.HasNextPage {
+		var query gqlTimelinePageQuery
+		variables := map[string]interface{}{
+			"id":     githubv4.ID(issue.ID),
+			"cursor": githubv4.NewString(issue.TimelineItems.PageInfo.EndCursor),
+		}
+		if err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+			return client.Query(ctx, &query, variables)
+		}); err != nil {
+			return err
+		}
+		page := query.Node.Issue.TimelineItems
+		if len(query.Node.PullRequest.TimelineItems.Nodes) > 0 || query.Node.PullRequest.TimelineItems.PageInfo.HasNextPage {
+			page = query.Node.PullRequest.TimelineItems
+		}
+		issue.TimelineItems.Nodes = append(issue.TimelineItems.Nodes, page.Nodes...)
+		issue.TimelineItems.PageInfo = page.PageInfo
+	}
+	return nil
+}
+
+// record maps the issue onto the REST shape the markdown renderer and the
+// reconciliation read, and returns its comments separately for merging.
+func (issue *gqlIssue) record(isPR bool) (issueRecord, []json.RawMessage, error) {
+	obj := map[string]interface{}{
+		"node_id":    issue.ID,
+		"number":     issue.Number,
+		"title":      issue.Title,
+		"body":       issue.Body,
+		"state":      restState(issue.State),
+		"created_at": issue.CreatedAt,
+		"updated_at": issue.UpdatedAt,
+		"user":       map[string]string{"login": issue.Author.Login},
+		"locked":     issue.Locked,
+		"reactions":  restReactions(issue.ReactionGroups),
+	}
+	if reason := restLockReason(issue.ActiveLockReason); reason != "" {
+		obj["active_lock_reason"] = reason
+	}
+	if issue.Milestone != nil {
+		obj["milestone"] = map[string]string{"title": issue.Milestone.Title}
+	}
+	labels := []map[string]string{}
+	for _, l := range issue.Labels.Nodes {
+		labels = append(labels, map[string]string{"name": l.Name})
+	}
+	obj["labels"] = labels
+	assignees := []map[string]string{}
+	for _, a := range issue.Assignees.Nodes {
+		assignees = append(assignees, map[string]string{"login": a.Login})
+	}
+	obj["assignees"] = assignees
+	if isPR {
+		obj["pull_request"] = map[string]interface{}{}
+	}
+	rawIssue, err := json.Marshal(obj)
+	if err != nil {
+		return issueRecord{}, nil, err
+	}
+
+	comments := []json.RawMessage{}
+	for _, c := range issue.Comments.Nodes {
+		raw, err := json.Marshal(map[string]interface{}{
+			"id":         c.DatabaseID,
+			"node_id":    c.ID,
+			"body":       c.Body,
+			"user":       map[string]string{"login": c.Author.Login},
+			"created_at": c.CreatedAt,
+			"updated_at": c.UpdatedAt,
+			"reactions":  restReactions(c.ReactionGroups),
+		})
+		if err != nil {
+			return issueRecord{}, nil, err
+		}
+		comments = append(comments, raw)
+	}
+
+	timeline := []json.RawMessage{}
+	for _, item := range issue.TimelineItems.Nodes {
+		event := restEvent(item)
+		if event == nil {
+			continue
+		}
+		raw, err := json.Marshal(event)
+		if err != nil {
+			return issueRecord{}, nil, err
+		}
+		timeline = append(timeline, raw)
+	}
+	return issueRecord{API: typedef.IssueAPIGraphQL, Issue: rawIssue, Timeline: timeline}, comments, nil
+}
+
+// restEvent maps a timeline item onto a REST timeline event, or nil for a type
+// it does not know.
+func restEvent(item gqlTimelineItem) map[string]interface{} {
+	var (
+		base   gqlEvent
+		fields = map[string]interface{}{}
+	)
+	switch item.Typename {
+	case "ClosedEvent":
+		base = item.ClosedEvent.gqlEvent
+		if oid := item.ClosedEvent.Closer.Commit.Oid; oid != "" {
+			fields["commit_id"] = oid
+		}
+	case "ReopenedEvent":
+		base = item.ReopenedEvent.gqlEvent
+	case "LabeledEvent":
+		base = item.LabeledEvent.gqlEvent
+		fields["label"] = map[string]string{"name": item.LabeledEvent.Label.Name}
+	case "UnlabeledEvent":
+		base = item.UnlabeledEvent.gqlEvent
+		fields["label"] = map[string]string{"name": item.UnlabeledEvent.Label.Name}
+	case "AssignedEvent":
+		base = item.AssignedEvent.gqlEvent
+		fields["assignee"] = map[string]string{"login": item.AssignedEvent.Assignee.User.Login}
+	case "UnassignedEvent":
+		base = item.UnassignedEvent.gqlEvent
+		fields["assignee"] = map[string]string{"login": item.UnassignedEvent.Assignee.User.Login}
+	case "MilestonedEvent":
+		base = item.MilestonedEvent.gqlEvent
+		fields["milestone"] = map[string]string{"title": item.MilestonedEvent.MilestoneTitle}
+	case "DemilestonedEvent":
+		base = item.DemilestonedEvent.gqlEvent
+		fields["milestone"] = map[string]string{"title": item.DemilestonedEvent.MilestoneTitle}
+	case "RenamedTitleEvent":
+		base = item.RenamedTitleEvent.gqlEvent
+		fields["rename"] = map[string]string{"from": item.RenamedTitleEvent.PreviousTitle, "to": item.RenamedTitleEvent.CurrentTitle}
+	case "CrossReferencedEvent":
+		base = item.CrossReferencedEvent.gqlEvent
+		src := item.CrossReferencedEvent.Source.Issue
+		if src.Number == 0 {
+			src = item.CrossReferencedEvent.Source.PullRequest
+		}
+		fields["source"] = map[string]interface{}{
+			"type": "issue",
+			"issue": map[string]interface{}{
+				"number":     src.Number,
+				"repository": map[string]string{"full_name": src.Repository.NameWithOwner},
+			},
+		}
+	case "ReferencedEvent":
+		base = item.ReferencedEvent.gqlEvent
+		if oid := item.ReferencedEvent.Commit.Oid; oid != "" {
+			fields["commit_id"] = oid
+		}
+	case "TransferredEvent":
+		base = item.TransferredEvent.gqlEvent
+	case "LockedEvent":
+		base = item.LockedEvent.gqlEvent
+	case "UnlockedEvent":
+		base = item.UnlockedEvent.gqlEvent
+	default:
+		return nil
+	}
+	// ClosedEvent -> closed, CrossReferencedEvent -> cross-referenced, ...
+	name := strings.TrimSuffix(item.Typename, "Event")
+	switch name {
+	case "CrossReferenced":
+		name = "cross-referenced"
+	case "RenamedTitle":
+		name = "renamed"
+	default:
+		name = strings.ToLower(name)
+	}
+	fields["event"] = name
+	fields["actor"] = map[string]string{"login": base.Actor.Login}
+	fields["created_at"] = base.CreatedAt
+	return fields
+}
+
+func restState(state string) string {
+	// A merged pull request is "closed" over REST.
+	if state == "MERGED" {
+		return "closed"
+	}
+	return strings.ToLower(state)
+}
+
+func restLockReason(reason string) string {
+	switch reason {
+	case "OFF_TOPIC":
+		return "off-topic"
+	case "TOO_HEATED":
+		return "too heated"
+	default:
+		return strings.ToLower(reason)
+	}
+}
+
+var restReactionNames = map[string]string{
+	"THUMBS_UP":   "+1",
+	"THUMBS_DOWN": "-1",
+	"LAUGH":       "laugh",
+	"HOORAY":      "hooray",
+	"CONFUSED":    "confused",
+	"HEART":       "heart",
+	"ROCKET":      "rocket",
+	"EYES":        "eyes",
+}
+
+func restReactions(groups []gqlReactionGroup) map[string]int {
+	reactions := map[string]int{"total_count": 0}
+	for _, g := range groups {
+		name, ok := restReactionNames[g.Content]
+		if !ok {
+			continue
+		}
+		reactions[name] = g.Reactors.TotalCount
+		reactions["total_count"] += g.Reactors.TotalCount
+	}
+	return reactions
+}
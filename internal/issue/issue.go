@@ -2,20 +2,24 @@ package issue
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path"
-	"strings"
 	"time"
 
 	gh "github.com/google/go-github/v56/github"
 	"github.com/google/uuid"
 	"github.com/wnarutou/gitrieve/internal/archive"
+	"github.com/wnarutou/gitrieve/internal/attachment"
 	"github.com/wnarutou/gitrieve/internal/config"
 	"github.com/wnarutou/gitrieve/internal/lock"
+	"github.com/wnarutou/gitrieve/internal/render"
 	"github.com/wnarutou/gitrieve/internal/retry"
 	"github.com/wnarutou/gitrieve/internal/scm"
+	"github.com/wnarutou/gitrieve/internal/scm/github"
 	"github.com/wnarutou/gitrieve/internal/storage"
+	"github.com/wnarutou/gitrieve/internal/syncstate"
 	"github.com/wnarutou/gitrieve/internal/typedef"
 	"github.com/wnarutou/gitrieve/internal/ui"
 )
@@ -91,52 +95,30 @@ func Sync(ctx context.Context, repo typedef.Repository, storages []typedef.Multi
 			ui.Printf("Cleanup completed for directory: %s", gitDir)
 		}()
 	}
-	// Get all issue files in the gitDir directory
-	files, err := os.ReadDir(gitDir)
+	// The incremental cursor comes from the sync state, which a run without a
+	// cache pulls back from storage together with the archive.
+	repoDir := path.Join(workingDir, r.Host, r.Owner, repoName)
+	state, ok, err := syncstate.Load(ctx, r, syncstate.Issues, repoDir, gitDir, storages)
 	if err != nil {
-		ui.Errorf("Error reading issue directory: %s", err)
+		ui.Errorf("Error loading issue sync state: %s", err)
 		return err
 	}
-
-	var lastUpdate time.Time
-	if len(files) == 0 {
-		// Keep the zero value so go-github omits the since filter and the
-		// first sync downloads every issue and pull request.
+	if !ok {
+		// A cache from before the state file existed: take the cursor from
+		// the JSON exports once. Without those either, keep the zero value so
+		// go-github omits the since filter and the first sync downloads every
+		// issue and pull request.
+		state = syncstate.State{}
+		state.Cursor, ok, err = lastUpdated(gitDir)
+		if err != nil {
+			ui.Errorf("Error reading issue directory: %s", err)
+			return err
+		}
+	}
+	lastUpdate := state.Cursor
+	if !ok {
 		ui.Printf("No issues downloaded yet, need to download all issues")
 	} else {
-		// Traverse all issue files to get the latest update time
-		var updateTime time.Time
-		for _, file := range files {
-			if !strings.HasSuffix(file.Name(), ".md") {
-				continue
-			}
-
-			content, err := os.ReadFile(path.Join(gitDir, file.Name()))
-			if err != nil {
-				ui.Errorf("Error reading issue file: %s", err)
-				return err
-			}
-
-			// Parse the markdown file content to get the update time
-			lines := strings.Split(string(content), "\n")
-			for _, line := range lines {
-				if strings.HasPrefix(line, "- Updated Time: ") {
-					timeStr := strings.TrimPrefix(line, "- Updated Time: ")
-					timeStr = strings.TrimSpace(timeStr)
-					// use UTC time to avoid be affect by local time zone
-					loc, _ := time.LoadLocation("UTC")
-					updateTime, err = time.ParseInLocation("2006-01-02 15:04:05", timeStr, loc)
-					if err != nil {
-						continue
-					}
-					break
-				}
-			}
-
-			if updateTime.After(lastUpdate) {
-				lastUpdate = updateTime
-			}
-		}
 		ui.Printf("The latest update time among all issues is: %s", lastUpdate)
 	}
 
@@ -144,110 +126,151 @@ func Sync(ctx context.Context, repo typedef.Repository, storages []typedef.Multi
 	// syncs use the same instant in UTC without reinterpreting wall-clock time.
 	opt := newIssueListOptions(lastUpdate)
 
-	cfg := config.GetIns()
-	client := gh.NewClient(nil).WithAuthToken(cfg.GitHubToken)
-	for {
-		var (
-			issues []*gh.Issue
-			resp   *gh.Response
-		)
-		err := retry.Do(ctx, config.GetRetryConfig(), func() error {
-			var apiErr error
-			issues, resp, apiErr = client.Issues.ListByRepo(ctx, r.Owner, r.Name, opt)
-			return apiErr
-		})
+	client, err := github.NewRESTClient(r.Host)
+	if err != nil {
+		ui.Errorf("Error creating github client, %s", err)
+		return err
+	}
+	attachments, err := attachment.Open(gitDir, r.Host, r.Owner, r.Name)
+	if err != nil {
+		ui.Errorf("Error opening attachments directory, %s", err)
+		return err
+	}
+	layout, err := render.Parse(render.Issue, config.GetTemplate(render.Issue))
+	if err != nil {
+		ui.Errorf("Error parsing issue template, %s", err)
+		return err
+	}
+	// The markdown links to the downloaded copies when asked to; the JSON
+	// export always keeps the original URLs.
+	md := markdown{layout: layout, linkify: func(body string) string {
+		if repo.RewriteAttachmentLinks {
+			return attachments.Rewrite(body)
+		}
+		return body
+	}}
+	if repo.GetIssueAPI() == typedef.IssueAPIGraphQL {
+		gqlClient, err := github.NewGraphQLClient(r.Host)
 		if err != nil {
-			ui.Errorf("Error fetching issues, %s", err)
+			ui.Errorf("Error creating github graphql client, %s", err)
 			return err
 		}
-		ui.Printf("Fetching page %d, total %d issues", opt.Page, len(issues))
-
-		// Verified that for each issue, if the issue or any comment under it is updated, the issue's update time will be updated
-		// Traverse all issues
-		for _, issue := range issues {
-			isUpdated = true
-			// Get all comments under the issue
-			commentsOpt := &gh.IssueListCommentsOptions{
-				ListOptions: gh.ListOptions{
-					PerPage: 100,
-				},
+		updated, err := syncGraphQL(ctx, gqlClient, r, gitDir, lastUpdate, attachments, md, &state.Cursor)
+		if err != nil {
+			return err
+		}
+		isUpdated = updated
+	} else {
+		for {
+			var (
+				rawIssues []json.RawMessage
+				resp      *gh.Response
+			)
+			err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+				var apiErr error
+				rawIssues, resp, apiErr = github.ListRaw(ctx, client, fmt.Sprintf("repos/%v/%v/issues", r.Owner, r.Name), opt)
+				return apiErr
+			})
+			if err != nil {
+				ui.Errorf("Error fetching issues, %s", err)
+				return err
 			}
-			var allComments []*gh.IssueComment
-			for {
-				var (
-					comments []*gh.IssueComment
-					resp     *gh.Response
-				)
-				err := retry.Do(ctx, config.GetRetryConfig(), func() error {
-					var apiErr error
-					comments, resp, apiErr = client.Issues.ListComments(ctx, r.Owner, r.Name, issue.GetNumber(), commentsOpt)
-					return apiErr
-				})
+			issues, err := decodeIssues(rawIssues)
+			if err != nil {
+				ui.Errorf("Error fetching issues, %s", err)
+				return err
+			}
+			ui.Printf("Fetching page %d, total %d issues", opt.Page, len(issues))
+
+			// Verified that for each issue, if the issue or any comment under it is updated, the issue's update time will be updated
+			// Traverse all issues
+			for i, issue := range issues {
+				isUpdated = true
+				// Get all comments under the issue
+				commentsOpt := &gh.IssueListCommentsOptions{
+					ListOptions: gh.ListOptions{
+						PerPage: 100,
+					},
+				}
+				rawComments := []json.RawMessage{}
+				for {
+					var (
+						comments []json.RawMessage
+						resp     *gh.Response
+					)
+					err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+						var apiErr error
+						comments, resp, apiErr = github.ListRaw(ctx, client, fmt.Sprintf("repos/%v/%v/issues/%d/comments", r.Owner, r.Name, issue.GetNumber()), commentsOpt)
+						return apiErr
+					})
+					if err != nil {
+						ui.Errorf("Error fetching comments of issue %d, %s", issue.GetNumber(), err)
+						return err
+					}
+					rawComments = append(rawComments, comments...)
+
+					if resp.NextPage == 0 {
+						break
+					}
+					commentsOpt.Page = resp.NextPage
+				}
+				prev, err := readRecord(gitDir, issue.GetNumber())
 				if err != nil {
-					ui.Errorf("Error fetching comments of issue %d, %s", issue.GetNumber(), err)
+					ui.Errorf("Error reading issue file #%d.json, %s", issue.GetNumber(), err)
 					return err
 				}
-				allComments = append(allComments, comments...)
-
-				if resp.NextPage == 0 {
-					break
+				// Keep the timeline of the previous REST export while the issue's
+				// updated_at has not moved (the issue at the cursor is listed by
+				// every incremental sync); otherwise fetch it again.
+				rawTimeline := prev.Timeline
+				if prev.API != "" || prev.Timeline == nil || !prev.updatedAt().Equal(issue.GetUpdatedAt().Time) {
+					rawTimeline, err = fetchTimeline(ctx, client, r, issue.GetNumber())
+					if err != nil {
+						ui.Errorf("Error fetching timeline of issue %d, %s", issue.GetNumber(), err)
+						return err
+					}
+				}
+				issueFilePath := path.Join(gitDir, fmt.Sprintf("#%d.md", issue.GetNumber()))
+				rec := issueRecord{Issue: rawIssues[i], Timeline: rawTimeline}
+				if err := saveIssue(ctx, gitDir, attachments, md, issue.GetNumber(), rec, rawComments, prev); err != nil {
+					ui.Errorf("Error writing issue file %s, %s", issueFilePath, err)
+					return err
+				}
+				ui.Printf("Success writing issue #%d to file %s", issue.GetNumber(), issueFilePath)
+				if issue.GetUpdatedAt().After(state.Cursor) {
+					state.Cursor = issue.GetUpdatedAt().Time
+				}
+				if ctx.Err() != nil {
+					return ctx.Err()
 				}
-				commentsOpt.Page = resp.NextPage
-			}
-
-			// Create issue file
-			issueFileName := fmt.Sprintf("#%d.md", issue.GetNumber())
-			issueFilePath := path.Join(gitDir, issueFileName)
-
-			// Generate markdown content
-			var content string
-			if issue.IsPullRequest() {
-				content += fmt.Sprintf("# PullRequest #%d: %s\n\n", issue.GetNumber(), issue.GetTitle())
-			} else {
-				content += fmt.Sprintf("# Issue #%d: %s\n\n", issue.GetNumber(), issue.GetTitle())
 			}
-			content += "## Basic Information\n\n"
-			content += fmt.Sprintf("- Created Time: %s\n", issue.GetCreatedAt().Format("2006-01-02 15:04:05"))
-			content += fmt.Sprintf("- Updated Time: %s\n", issue.GetUpdatedAt().Format("2006-01-02 15:04:05"))
-			content += fmt.Sprintf("- State: %s\n", issue.GetState())
-			content += fmt.Sprintf("- Author: %s\n", issue.GetUser().GetLogin())
-			content += fmt.Sprintf("- Comment Count: %d\n\n", len(allComments))
-
-			content += "## Content\n\n"
-			content += "```\n\n"
-			content += issue.GetBody() + "\n\n"
-			content += "```\n\n"
 
-			if len(allComments) > 0 {
-				content += "## Comments\n\n"
-				for _, comment := range allComments {
-					content += fmt.Sprintf("### Comment #%d\n\n", comment.GetID())
-					content += "```\n\n"
-					content += comment.GetBody() + "\n\n"
-					content += "```\n\n"
-					content += fmt.Sprintf("- Author: %s\n", comment.GetUser().GetLogin())
-					content += fmt.Sprintf("- Created Time: %s\n", comment.GetCreatedAt().Format("2006-01-02 15:04:05"))
-					content += fmt.Sprintf("- Updated Time: %s\n\n", comment.GetUpdatedAt().Format("2006-01-02 15:04:05"))
-					content += "---\n\n"
-				}
+			if resp.NextPage == 0 {
+				break
 			}
+			opt.Page = resp.NextPage
+		}
+	}
 
-			// Write to file
-			err = os.WriteFile(issueFilePath, []byte(content), 0644)
+	// Deletions never bump updated_at, so the cursor cannot see them: every
+	// reconcileInterval, list what still exists upstream and tombstone the
+	// rest. A full sync has just listed everything and only starts the clock.
+	now := time.Now().UTC()
+	interval := config.GetReconcileInterval()
+	due := interval >= 0 && now.Sub(state.Reconciled) >= interval
+	if due {
+		if ok {
+			ui.Printf("Checking for issues and comments deleted upstream")
+			changed, err := reconcile(ctx, client, r, gitDir, now, md)
 			if err != nil {
-				ui.Errorf("Error writing issue file %s, %s", issueFilePath, err)
+				ui.Errorf("Error checking for deleted issues, %s", err)
 				return err
 			}
-			ui.Printf("Success writing issue #%d to file %s", issue.GetNumber(), issueFilePath)
-			if ctx.Err() != nil {
-				return ctx.Err()
+			if changed > 0 {
+				isUpdated = true
 			}
 		}
-
-		if resp.NextPage == 0 {
-			break
-		}
-		opt.Page = resp.NextPage
+		state.Reconciled = now
 	}
 
 	if isUpdated {
@@ -280,5 +303,46 @@ func Sync(ctx context.Context, repo typedef.Repository, storages []typedef.Multi
 		ui.Printf("All is up to date, no need to restore")
 	}
 
+	if isUpdated || due {
+		if !useCache {
+			repoDir = ""
+		}
+		if err := syncstate.Save(r, syncstate.Issues, repoDir, state, storages); err != nil {
+			ui.Errorf("Error saving issue sync state, %s", err)
+			return err
+		}
+	}
+
 	return nil
 }
+
+// saveIssue stores one fetched issue in dir. rec carries the issue and its
+// timeline, fresh its comments as just fetched and prev the record archived
+// before. An issue fetched again exists upstream, whatever an earlier
+// reconciliation found; comments that vanished since are kept and tombstoned.
+// Linked attachments are downloaded before the markdown and JSON are written.
+func saveIssue(ctx context.Context, dir string, attachments *attachment.Store, md markdown,
+	number int, rec issueRecord, fresh []json.RawMessage, prev issueRecord) error {
+	rec.DeletedComments = prev.DeletedComments
+	if err := rec.mergeComments(prev.Comments, fresh, time.Now().UTC()); err != nil {
+		return err
+	}
+	var issue struct {
+		Body string `json:"body"`
+	}
+	if err := json.Unmarshal(rec.Issue, &issue); err != nil {
+		return fmt.Errorf("decode issue: %w", err)
+	}
+	comments, err := decodeComments(rec.Comments)
+	if err != nil {
+		return err
+	}
+	bodies := []string{issue.Body}
+	for _, comment := range comments {
+		bodies = append(bodies, comment.GetBody())
+	}
+	if err := attachments.Fetch(ctx, bodies...); err != nil {
+		return fmt.Errorf("download attachments: %w", err)
+	}
+	return writeIssue(dir, number, rec, md)
+}
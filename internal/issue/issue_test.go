@@ -2,17 +2,24 @@ package issue
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"path"
+	"strings"
 	"testing"
 	"time"
 
 	gh "github.com/google/go-github/v56/github"
 	"github.com/stretchr/testify/require"
+	"github.com/wnarutou/gitrieve/internal/archive"
 	"github.com/wnarutou/gitrieve/internal/lock"
 	"github.com/wnarutou/gitrieve/internal/scm"
+	"github.com/wnarutou/gitrieve/internal/scm/github/githubtest"
+	"github.com/wnarutou/gitrieve/internal/syncstate"
 	"github.com/wnarutou/gitrieve/internal/typedef"
 )
 
@@ -75,3 +82,351 @@ func TestNewIssueListOptionsPreservesInstantInUTC(t *testing.T) {
 	query := captureIssueListQuery(t, newIssueListOptions(lastUpdate))
 	require.Equal(t, "2026-08-17T01:30:45Z", query.Get("since"))
 }
+
+func TestSyncWritesLosslessJSONAndResumesFromIt(t *testing.T) {
+	var sinces []string
+	host := githubtest.Serve(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/repos/owner/repo/issues":
+			sinces = append(sinces, r.URL.Query().Get("since"))
+			fmt.Fprint(w, `[{"number":1,"title":"Bug","state":"open","updated_at":"2026-08-17T01:30:45Z",
+				"labels":[{"name":"bug"}],"milestone":{"title":"v1"},"reactions":{"+1":2},"x_unmodelled":"kept"}]`)
+		case "/repos/owner/repo/issues/1/timeline":
+			fmt.Fprint(w, `[]`)
+		case "/repos/owner/repo/issues/1/comments":
+			fmt.Fprint(w, `[{"id":99,"body":"me too","user":{"login":"alice"}}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	repo := typedef.Repository{URL: host + "/owner/repo", UseCache: true}
+
+	require.NoError(t, Sync(context.Background(), repo, nil))
+	data, err := os.ReadFile(path.Join(".gitrieve", host, "owner", "repo", "issues", "#1.json"))
+	require.NoError(t, err)
+	var rec struct {
+		Issue    map[string]interface{}   `json:"issue"`
+		Comments []map[string]interface{} `json:"comments"`
+	}
+	require.NoError(t, json.Unmarshal(data, &rec))
+	require.Equal(t, "kept", rec.Issue["x_unmodelled"], "fields go-github does not model survive")
+	require.Equal(t, map[string]interface{}{"title": "v1"}, rec.Issue["milestone"])
+	require.Len(t, rec.Comments, 1)
+	require.EqualValues(t, 99, rec.Comments[0]["id"])
+	_, err = os.Stat(path.Join(".gitrieve", host, "owner", "repo", "issues", "#1.md"))
+	require.NoError(t, err, "the markdown is still written")
+
+	require.NoError(t, Sync(context.Background(), repo, nil))
+	require.Equal(t, []string{"", "2026-08-17T01:30:45Z"}, sinces, "the cursor comes from the JSON export")
+}
+
+func TestSyncDownloadsAttachmentsAndRewritesLinks(t *testing.T) {
+	var host string
+	host = githubtest.Serve(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/owner/repo/issues":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `[{"number":1,"title":"Bug","state":"open","updated_at":"2026-08-17T01:30:45Z",
+				"body":"![shot](http://%s/owner/repo/assets/1/shot)"}]`, host)
+		case "/repos/owner/repo/issues/1/timeline":
+			fmt.Fprint(w, `[]`)
+		case "/repos/owner/repo/issues/1/comments":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `[]`)
+		case "/owner/repo/assets/1/shot":
+			w.Header().Set("Content-Type", "image/png")
+			fmt.Fprint(w, "png bytes")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	repo := typedef.Repository{URL: host + "/owner/repo", UseCache: true, RewriteAttachmentLinks: true}
+	dir := path.Join(".gitrieve", host, "owner", "repo", "issues")
+
+	require.NoError(t, Sync(context.Background(), repo, nil))
+	md, err := os.ReadFile(path.Join(dir, "#1.md"))
+	require.NoError(t, err)
+	require.Regexp(t, `!\[shot\]\(attachments/[0-9a-f]{64}\.png\)`, string(md))
+	data, err := os.ReadFile(path.Join(dir, "#1.json"))
+	require.NoError(t, err)
+	require.Contains(t, string(data), "/owner/repo/assets/1/shot", "the JSON export keeps the original URL")
+}
+
+func TestSyncRendersMetadataAndTimeline(t *testing.T) {
+	var pages []string
+	host := githubtest.Serve(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/repos/owner/repo/issues":
+			fmt.Fprint(w, `[{"number":1,"title":"Bug","state":"closed","updated_at":"2026-08-17T01:30:45Z",
+				"labels":[{"name":"bug"},{"name":"p1"}],"assignees":[{"login":"bob"}],"milestone":{"title":"v1"},
+				"locked":true,"active_lock_reason":"resolved","reactions":{"total_count":3,"+1":2,"heart":1}}]`)
+		case "/repos/owner/repo/issues/1/comments":
+			fmt.Fprint(w, `[]`)
+		case "/repos/owner/repo/issues/1/timeline":
+			pages = append(pages, r.URL.Query().Get("page"))
+			fmt.Fprint(w, `[
+				{"event":"labeled","actor":{"login":"alice"},"created_at":"2026-08-17T01:00:00Z","label":{"name":"bug"}},
+				{"event":"commented","actor":{"login":"alice"},"created_at":"2026-08-17T01:05:00Z","body":"hi"},
+				{"event":"cross-referenced","actor":{"login":"carol"},"created_at":"2026-08-17T01:10:00Z",
+					"source":{"type":"issue","issue":{"number":7,"repository":{"full_name":"other/repo"}}}},
+				{"event":"closed","actor":{"login":"bob"},"created_at":"2026-08-17T01:30:45Z","commit_id":"0123456789abcdef"}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	repo := typedef.Repository{URL: host + "/owner/repo", UseCache: true}
+	dir := path.Join(".gitrieve", host, "owner", "repo", "issues")
+
+	require.NoError(t, Sync(context.Background(), repo, nil))
+	md, err := os.ReadFile(path.Join(dir, "#1.md"))
+	require.NoError(t, err)
+	for _, line := range []string{
+		"labels:\n    - bug\n    - p1\n",
+		"assignees:\n    - bob\n",
+		"milestone: v1\n",
+		"locked: true\nlock_reason: resolved\n",
+		"reactions:\n    \"+1\": 2\n    heart: 1\n",
+		"## Timeline\n\n- 2026-08-17 01:00:00 alice labeled bug\n" +
+			"- 2026-08-17 01:10:00 carol cross-referenced from other/repo#7\n" +
+			"- 2026-08-17 01:30:45 bob closed in 0123456\n",
+	} {
+		require.Contains(t, string(md), line)
+	}
+	rec, err := readRecord(dir, 1)
+	require.NoError(t, err)
+	require.Len(t, rec.Timeline, 4, "the JSON keeps every event, comments included")
+	require.Equal(t, []string{""}, pages)
+}
+
+func TestSyncRefetchesWholeTimelineWhenIssueUpdated(t *testing.T) {
+	var pages []string
+	updated := "2026-08-17T01:00:00Z"
+	timeline := `[{"id":1,"event":"labeled"},{"id":2,"event":"closed"}]`
+	host := githubtest.Serve(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/repos/owner/repo/issues":
+			fmt.Fprintf(w, `[{"number":1,"title":"Bug","state":"closed","updated_at":%q}]`, updated)
+		case "/repos/owner/repo/issues/1/comments":
+			fmt.Fprint(w, `[]`)
+		case "/repos/owner/repo/issues/1/timeline":
+			pages = append(pages, r.URL.Query().Get("page"))
+			fmt.Fprint(w, timeline)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	repo := typedef.Repository{URL: host + "/owner/repo", UseCache: true}
+	dir := path.Join(".gitrieve", host, "owner", "repo", "issues")
+
+	require.NoError(t, Sync(context.Background(), repo, nil))
+	require.Equal(t, []string{""}, pages)
+
+	// Listed again at the cursor, but not updated: the archived timeline is kept.
+	require.NoError(t, Sync(context.Background(), repo, nil))
+	require.Equal(t, []string{""}, pages)
+
+	// An event was deleted: the whole timeline is fetched again from page one.
+	updated = "2026-08-17T02:00:00Z"
+	timeline = `[{"id":2,"event":"closed"}]`
+	require.NoError(t, Sync(context.Background(), repo, nil))
+	require.Equal(t, []string{"", ""}, pages)
+	rec, err := readRecord(dir, 1)
+	require.NoError(t, err)
+	require.Len(t, rec.Timeline, 1)
+	require.JSONEq(t, `{"id":2,"event":"closed"}`, string(rec.Timeline[0]))
+}
+
+func TestSyncTombstonesIssuesAndCommentsDeletedUpstream(t *testing.T) {
+	issues := map[int]string{
+		1: `{"number":1,"title":"Kept","state":"open","updated_at":"2026-08-17T01:00:00Z"}`,
+		2: `{"number":2,"title":"Doomed","state":"open","updated_at":"2026-08-17T02:00:00Z"}`,
+	}
+	comments := `[{"id":10,"body":"first"},{"id":11,"body":"second"}]`
+	host := githubtest.Serve(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/repos/owner/repo/issues":
+			// Like GitHub, only list what was updated at or after since, so
+			// deletions never show up in an incremental listing.
+			var list []string
+			for _, n := range []int{1, 2} {
+				issue, ok := issues[n]
+				if !ok {
+					continue
+				}
+				var fields struct {
+					UpdatedAt string `json:"updated_at"`
+				}
+				require.NoError(t, json.Unmarshal([]byte(issue), &fields))
+				if fields.UpdatedAt >= r.URL.Query().Get("since") {
+					list = append(list, issue)
+				}
+			}
+			fmt.Fprintf(w, "[%s]", strings.Join(list, ","))
+		case "/repos/owner/repo/issues/comments":
+			fmt.Fprint(w, comments)
+		case "/repos/owner/repo/issues/1/comments":
+			fmt.Fprint(w, comments)
+		case "/repos/owner/repo/issues/1/timeline", "/repos/owner/repo/issues/2/timeline", "/repos/owner/repo/issues/2/comments":
+			fmt.Fprint(w, `[]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	repo := typedef.Repository{URL: host + "/owner/repo", UseCache: true}
+	dir := path.Join(".gitrieve", host, "owner", "repo", "issues")
+	require.NoError(t, Sync(context.Background(), repo, nil))
+
+	// Issue 2 and comment 11 are deleted; the interval has elapsed.
+	delete(issues, 2)
+	comments = `[{"id":10,"body":"first"}]`
+	r, err := scm.NewRepository(repo.URL)
+	require.NoError(t, err)
+	repoDir := path.Join(".gitrieve", host, "owner", "repo")
+	state, ok, err := syncstate.Load(context.Background(), r, syncstate.Issues, repoDir, dir, nil)
+	require.NoError(t, err)
+	require.True(t, ok)
+	state.Reconciled = time.Now().Add(-25 * time.Hour)
+	require.NoError(t, syncstate.Save(r, syncstate.Issues, repoDir, state, nil))
+	require.NoError(t, Sync(context.Background(), repo, nil))
+
+	rec, err := readRecord(dir, 2)
+	require.NoError(t, err)
+	require.NotNil(t, rec.DeletedAt)
+	md, err := os.ReadFile(path.Join(dir, "#2.md"))
+	require.NoError(t, err)
+	require.Contains(t, string(md), "# Issue #2: Doomed\n\n> Deleted upstream: missing from GitHub since")
+
+	rec, err = readRecord(dir, 1)
+	require.NoError(t, err)
+	require.Nil(t, rec.DeletedAt)
+	require.Len(t, rec.Comments, 2, "the deleted comment is kept")
+	require.Contains(t, rec.DeletedComments, int64(11))
+	detected := rec.DeletedComments[11]
+	md, err = os.ReadFile(path.Join(dir, "#1.md"))
+	require.NoError(t, err)
+	require.Contains(t, string(md), "### Comment #10\n")
+	require.Contains(t, string(md), "### Comment #11 (deleted upstream since ")
+
+	// Issue 1 is updated later: the normal sync keeps the tombstoned comment
+	// and its original detection time.
+	issues[1] = `{"number":1,"title":"Kept","state":"open","updated_at":"2026-08-18T01:00:00Z"}`
+	require.NoError(t, Sync(context.Background(), repo, nil))
+	rec, err = readRecord(dir, 1)
+	require.NoError(t, err)
+	require.Len(t, rec.Comments, 2)
+	require.Equal(t, detected, rec.DeletedComments[11])
+}
+
+func TestSyncWithoutCacheResumesFromStoredState(t *testing.T) {
+	var sinces []string
+	issues := []string{`{"number":1,"title":"Old","state":"open","updated_at":"2026-08-17T01:00:00Z"}`}
+	host := githubtest.Serve(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/repos/owner/repo/issues":
+			sinces = append(sinces, r.URL.Query().Get("since"))
+			fmt.Fprintf(w, "[%s]", issues[len(issues)-1])
+		case strings.HasPrefix(r.URL.Path, "/repos/owner/repo/issues/"):
+			fmt.Fprint(w, `[]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	store := typedef.MultiStorage{Storage: typedef.Storage{Name: "local", Type: "file", Path: t.TempDir()}}
+	repo := typedef.Repository{URL: host + "/owner/repo"}
+
+	require.NoError(t, Sync(context.Background(), repo, []typedef.MultiStorage{store}))
+	issues = append(issues, `{"number":2,"title":"New","state":"open","updated_at":"2026-08-18T01:00:00Z"}`)
+	require.NoError(t, Sync(context.Background(), repo, []typedef.MultiStorage{store}))
+
+	require.Equal(t, []string{"", "2026-08-17T01:00:00Z"}, sinces, "the second run resumes from the stored cursor")
+	stored := path.Join(store.Path, host, "owner", "repo")
+	data, err := os.ReadFile(path.Join(stored, "issues.state.json"))
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"cursor": "2026-08-18T01:00:00Z"`)
+
+	// The archive uploaded by the incremental run still holds issue 1.
+	data, err = os.ReadFile(path.Join(stored, "issues.tar.gz"))
+	require.NoError(t, err)
+	out := t.TempDir()
+	require.NoError(t, archive.Extract(context.Background(), data, "issues", out))
+	for _, f := range []string{"#1.md", "#1.json", "#2.md", "#2.json"} {
+		_, err := os.Stat(path.Join(out, f))
+		require.NoError(t, err, f)
+	}
+}
+
+func TestSyncOverGraphQLBatchesCommentsAndTimeline(t *testing.T) {
+	var sinces []interface{}
+	var commentPages int
+	host := githubtest.Serve(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/graphql" {
+			http.NotFound(w, r)
+			return
+		}
+		var req struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		switch {
+		case strings.Contains(req.Query, "issues("):
+			sinces = append(sinces, req.Variables["since"])
+			fmt.Fprint(w, `{"data":{"repository":{"issues":{"pageInfo":{"hasNextPage":false},"nodes":[{
+				"id":"I_1","number":1,"title":"Bug","body":"broken","state":"CLOSED",
+				"createdAt":"2026-08-17T01:00:00Z","updatedAt":"2026-08-17T01:30:45Z","author":{"login":"alice"},
+				"locked":true,"activeLockReason":"OFF_TOPIC","milestone":{"title":"v1"},
+				"labels":{"nodes":[{"name":"bug"}]},"assignees":{"nodes":[{"login":"bob"}]},
+				"reactionGroups":[{"content":"THUMBS_UP","reactors":{"totalCount":2}}],
+				"comments":{"pageInfo":{"hasNextPage":true,"endCursor":"c1"},"nodes":[
+					{"id":"C_1","databaseId":11,"author":{"login":"bob"},"body":"first","createdAt":"2026-08-17T01:05:00Z","updatedAt":"2026-08-17T01:05:00Z","reactionGroups":[]}]},
+				"timelineItems":{"pageInfo":{"hasNextPage":false},"nodes":[
+					{"__typename":"LabeledEvent","actor":{"login":"alice"},"createdAt":"2026-08-17T01:01:00Z","label":{"name":"bug"}},
+					{"__typename":"ClosedEvent","actor":{"login":"bob"},"createdAt":"2026-08-17T01:30:45Z","closer":{"oid":"0123456789abcdef"}}]}}]}}}}`)
+		case strings.Contains(req.Query, "pullRequests("):
+			fmt.Fprint(w, `{"data":{"repository":{"pullRequests":{"pageInfo":{"hasNextPage":false},"nodes":[]}}}}`)
+		case strings.Contains(req.Query, "comments(first: 100, after: $cursor)"):
+			commentPages++
+			require.Equal(t, "I_1", req.Variables["id"])
+			require.Equal(t, "c1", req.Variables["cursor"])
+			fmt.Fprint(w, `{"data":{"node":{"comments":{"pageInfo":{"hasNextPage":false},"nodes":[
+				{"id":"C_2","databaseId":12,"author":{"login":"carol"},"body":"second","createdAt":"2026-08-17T01:10:00Z","updatedAt":"2026-08-17T01:10:00Z","reactionGroups":[]}]}}}}`)
+		default:
+			t.Errorf("unexpected query %s", req.Query)
+		}
+	}))
+	repo := typedef.Repository{URL: host + "/owner/repo", UseCache: true, IssueAPI: typedef.IssueAPIGraphQL}
+	dir := path.Join(".gitrieve", host, "owner", "repo", "issues")
+
+	require.NoError(t, Sync(context.Background(), repo, nil))
+	require.Equal(t, 1, commentPages)
+	md, err := os.ReadFile(path.Join(dir, "#1.md"))
+	require.NoError(t, err)
+	for _, line := range []string{
+		"# Issue #1: Bug\n",
+		"state: closed\n",
+		"labels:\n    - bug\n",
+		"assignees:\n    - bob\n",
+		"milestone: v1\n",
+		"lock_reason: off-topic\n",
+		"reactions:\n    \"+1\": 2\n",
+		"### Comment #11\n",
+		"### Comment #12\n",
+		"- 2026-08-17 01:01:00 alice labeled bug\n",
+		"- 2026-08-17 01:30:45 bob closed in 0123456\n",
+	} {
+		require.Contains(t, string(md), line)
+	}
+	rec, err := readRecord(dir, 1)
+	require.NoError(t, err)
+	require.Equal(t, typedef.IssueAPIGraphQL, rec.API)
+	require.Len(t, rec.Comments, 2)
+
+	require.NoError(t, Sync(context.Background(), repo, nil))
+	require.Equal(t, []interface{}{nil, "2026-08-17T01:30:45Z"}, sinces, "the second run resumes from the cursor")
+}
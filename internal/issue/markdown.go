@@ -0,0 +1,126 @@
+package issue
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"text/template"
+
+	gh "github.com/google/go-github/v56/github"
+	"github.com/wnarutou/gitrieve/internal/render"
+)
+
+// markdown holds what rendering an issue record needs besides the record: the
+// layout, and linkify, which is applied to every body, e.g. to point
+// attachment links at local copies.
+type markdown struct {
+	layout  *template.Template
+	linkify func(string) string
+}
+
+// renderMarkdown renders the human-oriented view of one issue record.
+func renderMarkdown(rec issueRecord, md markdown) (string, error) {
+	var issue *gh.Issue
+	if err := json.Unmarshal(rec.Issue, &issue); err != nil {
+		return "", fmt.Errorf("decode issue: %w", err)
+	}
+	allComments, err := decodeComments(rec.Comments)
+	if err != nil {
+		return "", err
+	}
+	timeline, err := decodeTimeline(rec.Timeline)
+	if err != nil {
+		return "", err
+	}
+
+	doc := render.Document{
+		Kind:     "Issue",
+		Number:   issue.GetNumber(),
+		Title:    issue.GetTitle(),
+		Body:     md.linkify(issue.GetBody()),
+		Timeline: timelineLines(timeline),
+	}
+	kind := "issue"
+	if issue.IsPullRequest() {
+		doc.Kind = "PullRequest"
+		kind = "pull_request"
+	}
+	doc.Meta = render.Meta{
+		{Key: "number", Value: issue.GetNumber()},
+		{Key: "title", Value: issue.GetTitle()},
+		{Key: "type", Value: kind},
+		{Key: "state", Value: issue.GetState()},
+		{Key: "author", Value: issue.GetUser().GetLogin()},
+		{Key: "created_at", Value: issue.GetCreatedAt().Time},
+		{Key: "updated_at", Value: issue.GetUpdatedAt().Time},
+	}
+	if issue.ClosedAt != nil {
+		doc.Meta = append(doc.Meta, render.Field{Key: "closed_at", Value: issue.GetClosedAt().Time})
+	}
+	if url := issue.GetHTMLURL(); url != "" {
+		doc.Meta = append(doc.Meta, render.Field{Key: "url", Value: url})
+	}
+	var labels, assignees []string
+	for _, label := range issue.Labels {
+		labels = append(labels, label.GetName())
+	}
+	for _, assignee := range issue.Assignees {
+		assignees = append(assignees, assignee.GetLogin())
+	}
+	if len(labels) > 0 {
+		doc.Meta = append(doc.Meta, render.Field{Key: "labels", Value: labels})
+	}
+	if len(assignees) > 0 {
+		doc.Meta = append(doc.Meta, render.Field{Key: "assignees", Value: assignees})
+	}
+	if issue.Milestone != nil {
+		doc.Meta = append(doc.Meta, render.Field{Key: "milestone", Value: issue.GetMilestone().GetTitle()})
+	}
+	if issue.GetLocked() {
+		doc.Meta = append(doc.Meta, render.Field{Key: "locked", Value: true})
+		if reason := issue.GetActiveLockReason(); reason != "" {
+			doc.Meta = append(doc.Meta, render.Field{Key: "lock_reason", Value: reason})
+		}
+	}
+	if reactions := reactionCounts(issue.Reactions); reactions != nil {
+		doc.Meta = append(doc.Meta, render.Field{Key: "reactions", Value: reactions})
+	}
+	doc.Meta = append(doc.Meta, render.Field{Key: "comments", Value: len(allComments)})
+	if rec.DeletedAt != nil {
+		doc.Meta = append(doc.Meta, render.Field{Key: "deleted_at", Value: *rec.DeletedAt})
+		doc.Notice = fmt.Sprintf("Deleted upstream: missing from GitHub since %s. This is the last archived copy.", rec.DeletedAt.Format(timeLayout))
+	}
+
+	for _, comment := range allComments {
+		c := render.Comment{
+			Kind:      "Comment",
+			ID:        comment.GetID(),
+			Author:    comment.GetUser().GetLogin(),
+			CreatedAt: comment.GetCreatedAt().Time,
+			UpdatedAt: comment.GetUpdatedAt().Time,
+			Body:      md.linkify(comment.GetBody()),
+			Reactions: reactionCounts(comment.Reactions),
+		}
+		if deletedAt, ok := rec.DeletedComments[comment.GetID()]; ok {
+			c.Notice = "deleted upstream since " + deletedAt.Format(timeLayout)
+		}
+		doc.Comments = append(doc.Comments, c)
+	}
+	return render.Render(md.layout, render.Issue, doc)
+}
+
+// writeIssue writes the markdown and then the JSON export of one issue to dir.
+// The JSON export is written last, so it never describes a markdown that
+// failed to write. A failed issue is fetched again next time: the sync state
+// only advances once the whole sync succeeded.
+func writeIssue(dir string, number int, rec issueRecord, md markdown) error {
+	content, err := renderMarkdown(rec, md)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path.Join(dir, fmt.Sprintf("#%d.md", number)), []byte(content), 0644); err != nil {
+		return err
+	}
+	return writeRecord(dir, number, rec)
+}
@@ -0,0 +1,121 @@
+package issue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	gh "github.com/google/go-github/v56/github"
+	"github.com/wnarutou/gitrieve/internal/config"
+	"github.com/wnarutou/gitrieve/internal/retry"
+	"github.com/wnarutou/gitrieve/internal/scm"
+	"github.com/wnarutou/gitrieve/internal/scm/github"
+	"github.com/wnarutou/gitrieve/internal/ui"
+)
+
+// reconcile lists every issue number and comment ID that currently exists
+// upstream and tombstones the archived issues and comments that are missing,
+// re-rendering their markdown. Nothing is removed. It returns the number of
+// records it changed.
+func reconcile(ctx context.Context, client *gh.Client, r *scm.Repository, dir string, now time.Time, md markdown) (int, error) {
+	issues, err := listIDs(ctx, client, fmt.Sprintf("repos/%v/%v/issues", r.Owner, r.Name), "all", "number")
+	if err != nil {
+		return 0, err
+	}
+	comments, err := listIDs(ctx, client, fmt.Sprintf("repos/%v/%v/issues/comments", r.Owner, r.Name), "", "id")
+	if err != nil {
+		return 0, err
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	changed := 0
+	for _, file := range files {
+		name := file.Name()
+		if !strings.HasPrefix(name, "#") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		number, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "#"), ".json"))
+		if err != nil {
+			continue
+		}
+		rec, err := readRecord(dir, number)
+		if err != nil {
+			return changed, err
+		}
+
+		dirty := false
+		if !issues[int64(number)] {
+			// The comments went with the issue; its tombstone covers them.
+			if rec.DeletedAt == nil {
+				rec.DeletedAt = &now
+				dirty = true
+				ui.Printf("Issue #%d was deleted upstream, keeping the archived copy", number)
+			}
+		} else {
+			for _, raw := range rec.Comments {
+				id, err := commentID(raw)
+				if err != nil {
+					return changed, err
+				}
+				if !comments[id] && rec.markCommentDeleted(id, now) {
+					dirty = true
+					ui.Printf("Comment #%d of issue #%d was deleted upstream, keeping the archived copy", id, number)
+				}
+			}
+		}
+		if !dirty {
+			continue
+		}
+		if err := writeIssue(dir, number, rec, md); err != nil {
+			return changed, err
+		}
+		changed++
+	}
+	return changed, nil
+}
+
+// listIDs lists every element of a REST list endpoint and returns the set of
+// their numeric field key (an issue's number, a comment's id).
+func listIDs(ctx context.Context, client *gh.Client, u, state, key string) (map[int64]bool, error) {
+	opt := &struct {
+		State string `url:"state,omitempty"`
+		gh.ListOptions
+	}{State: state, ListOptions: gh.ListOptions{PerPage: 100}}
+	ids := map[int64]bool{}
+	for {
+		var (
+			page []json.RawMessage
+			resp *gh.Response
+		)
+		err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+			var apiErr error
+			page, resp, apiErr = github.ListRaw(ctx, client, u, opt)
+			return apiErr
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, raw := range page {
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &fields); err != nil {
+				return nil, err
+			}
+			id, err := strconv.ParseInt(string(fields[key]), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("decode %s: %w", key, err)
+			}
+			ids[id] = true
+		}
+		if resp.NextPage == 0 {
+			return ids, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
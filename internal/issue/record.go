@@ -0,0 +1,188 @@
+package issue
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	gh "github.com/google/go-github/v56/github"
+)
+
+// issueRecord is the lossless export of one issue or pull request, written as
+// #<number>.json next to the markdown. Issue and Comments hold the objects
+// exactly as the GitHub REST API returned them (labels, assignees, milestone,
+// reactions, comment IDs, ...), so fields go-github does not model survive too.
+// Timeline holds the issue's events (closes, reopens, cross-references, label
+// changes, transfers, ...), oldest first.
+//
+// Nothing archived is ever dropped: DeletedAt records when the issue was found
+// missing upstream, and DeletedComments when each of its missing comments was,
+// keyed by comment ID. Those comments stay in Comments.
+type issueRecord struct {
+	// API is "graphql" for a record built by the GraphQL sync, whose objects
+	// are mapped onto the REST shape and hold only the fields it queries.
+	API             string              `json:"api,omitempty"`
+	Issue           json.RawMessage     `json:"issue"`
+	Comments        []json.RawMessage   `json:"comments"`
+	Timeline        []json.RawMessage   `json:"timeline"`
+	DeletedAt       *time.Time          `json:"deleted_at,omitempty"`
+	DeletedComments map[int64]time.Time `json:"deleted_comments,omitempty"`
+}
+
+// updatedAt reads the updated_at of the archived issue, or the zero time when
+// there is none.
+func (rec issueRecord) updatedAt() time.Time {
+	var issue struct {
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+	if len(rec.Issue) == 0 || json.Unmarshal(rec.Issue, &issue) != nil {
+		return time.Time{}
+	}
+	return issue.UpdatedAt
+}
+
+// commentID reads the id of a raw comment.
+func commentID(raw json.RawMessage) (int64, error) {
+	var c struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return 0, fmt.Errorf("decode issue comment: %w", err)
+	}
+	return c.ID, nil
+}
+
+// mergeComments sets rec.Comments to the comments just fetched plus every
+// previously archived one that is gone upstream, tombstoned at now. Comment
+// IDs grow over time, so sorting by ID keeps them in posting order.
+func (rec *issueRecord) mergeComments(prev, fresh []json.RawMessage, now time.Time) error {
+	type comment struct {
+		id  int64
+		raw json.RawMessage
+	}
+	var merged []comment
+	current := make(map[int64]bool, len(fresh))
+	for _, raw := range fresh {
+		id, err := commentID(raw)
+		if err != nil {
+			return err
+		}
+		current[id] = true
+		merged = append(merged, comment{id, raw})
+	}
+	for _, raw := range prev {
+		id, err := commentID(raw)
+		if err != nil {
+			return err
+		}
+		if current[id] {
+			continue
+		}
+		rec.markCommentDeleted(id, now)
+		merged = append(merged, comment{id, raw})
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].id < merged[j].id })
+	rec.Comments = make([]json.RawMessage, len(merged))
+	for i, c := range merged {
+		rec.Comments[i] = c.raw
+	}
+	return nil
+}
+
+// markCommentDeleted tombstones comment id at now unless it already is, and
+// reports whether it changed rec.
+func (rec *issueRecord) markCommentDeleted(id int64, now time.Time) bool {
+	if _, ok := rec.DeletedComments[id]; ok {
+		return false
+	}
+	if rec.DeletedComments == nil {
+		rec.DeletedComments = map[int64]time.Time{}
+	}
+	rec.DeletedComments[id] = now
+	return true
+}
+
+// decodeIssues decodes raw issues for the markdown renderer.
+func decodeIssues(raw []json.RawMessage) ([]*gh.Issue, error) {
+	issues := make([]*gh.Issue, len(raw))
+	for i, r := range raw {
+		if err := json.Unmarshal(r, &issues[i]); err != nil {
+			return nil, fmt.Errorf("decode issue: %w", err)
+		}
+	}
+	return issues, nil
+}
+
+// decodeComments decodes raw issue comments for the markdown renderer.
+func decodeComments(raw []json.RawMessage) ([]*gh.IssueComment, error) {
+	comments := make([]*gh.IssueComment, len(raw))
+	for i, r := range raw {
+		if err := json.Unmarshal(r, &comments[i]); err != nil {
+			return nil, fmt.Errorf("decode issue comment: %w", err)
+		}
+	}
+	return comments, nil
+}
+
+// writeRecord writes the JSON export of one issue to dir.
+func writeRecord(dir string, number int, rec issueRecord) error {
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path.Join(dir, fmt.Sprintf("#%d.json", number)), data, 0644)
+}
+
+// readRecord reads the JSON export of one issue from dir. A missing export
+// yields an empty record.
+func readRecord(dir string, number int) (issueRecord, error) {
+	var rec issueRecord
+	data, err := os.ReadFile(path.Join(dir, fmt.Sprintf("#%d.json", number)))
+	if os.IsNotExist(err) {
+		return rec, nil
+	}
+	if err != nil {
+		return rec, err
+	}
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return rec, fmt.Errorf("parse #%d.json: %w", number, err)
+	}
+	return rec, nil
+}
+
+// lastUpdated returns the newest updated_at among the JSON exports in dir. It
+// seeds the cursor of a cache that predates the sync state file. ok is false
+// when dir holds no exports: either nothing was synced yet, or the cache
+// predates the JSON export and must be re-downloaded in full to gain it.
+func lastUpdated(dir string) (last time.Time, ok bool, err error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(path.Join(dir, file.Name()))
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		var rec struct {
+			Issue struct {
+				UpdatedAt time.Time `json:"updated_at"`
+			} `json:"issue"`
+		}
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return time.Time{}, false, fmt.Errorf("parse %s: %w", file.Name(), err)
+		}
+		ok = true
+		if rec.Issue.UpdatedAt.After(last) {
+			last = rec.Issue.UpdatedAt
+		}
+	}
+	return last, ok, nil
+}
@@ -0,0 +1,158 @@
+package issue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	gh "github.com/google/go-github/v56/github"
+	"github.com/wnarutou/gitrieve/internal/config"
+	"github.com/wnarutou/gitrieve/internal/render"
+	"github.com/wnarutou/gitrieve/internal/retry"
+	"github.com/wnarutou/gitrieve/internal/scm"
+	"github.com/wnarutou/gitrieve/internal/scm/github"
+)
+
+const (
+	timeLayout      = render.TimeLayout
+	timelinePerPage = 100
+)
+
+// fetchTimeline returns the complete timeline of an issue, oldest first. It
+// is always fetched whole: deleting an event (e.g. along with its comment)
+// shifts every later one to another page, so earlier pages cannot be reused.
+func fetchTimeline(ctx context.Context, client *gh.Client, r *scm.Repository, number int) ([]json.RawMessage, error) {
+	events := []json.RawMessage{}
+	opt := &gh.ListOptions{PerPage: timelinePerPage}
+	for {
+		var (
+			page []json.RawMessage
+			resp *gh.Response
+		)
+		err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+			var apiErr error
+			page, resp, apiErr = github.ListRaw(ctx, client, fmt.Sprintf("repos/%v/%v/issues/%d/timeline", r.Owner, r.Name, number), opt)
+			return apiErr
+		})
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, page...)
+		if resp.NextPage == 0 {
+			return events, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// decodeTimeline decodes raw timeline events for the markdown renderer.
+func decodeTimeline(raw []json.RawMessage) ([]*gh.Timeline, error) {
+	events := make([]*gh.Timeline, len(raw))
+	for i, r := range raw {
+		if err := json.Unmarshal(r, &events[i]); err != nil {
+			return nil, fmt.Errorf("decode timeline event: %w", err)
+		}
+	}
+	return events, nil
+}
+
+// timelineLines renders one line per event, e.g.
+// "2026-08-17 01:30:45 alice labeled bug". Comments are rendered in their
+// own section and are left out here.
+func timelineLines(events []*gh.Timeline) []string {
+	var lines []string
+	for _, e := range events {
+		event := e.GetEvent()
+		if event == "commented" {
+			continue
+		}
+		// committed events carry the commit's author date and name instead.
+		when := e.GetCreatedAt()
+		if when.IsZero() {
+			when = e.GetSubmittedAt()
+		}
+		if when.IsZero() {
+			when = e.GetAuthor().GetDate()
+		}
+		actor := e.GetActor().GetLogin()
+		if actor == "" {
+			actor = e.GetUser().GetLogin()
+		}
+		if actor == "" {
+			actor = e.GetAuthor().GetName()
+		}
+
+		line := fmt.Sprintf("%s %s %s", when.Format(timeLayout), actor, event)
+		if detail := eventDetail(e); detail != "" {
+			line += " " + detail
+		}
+		lines = append(lines, strings.TrimRight(line, " "))
+	}
+	return lines
+}
+
+func eventDetail(e *gh.Timeline) string {
+	switch e.GetEvent() {
+	case "labeled", "unlabeled":
+		return e.GetLabel().GetName()
+	case "assigned", "unassigned":
+		return e.GetAssignee().GetLogin()
+	case "milestoned", "demilestoned":
+		return e.GetMilestone().GetTitle()
+	case "renamed":
+		return fmt.Sprintf("%q -> %q", e.GetRename().GetFrom(), e.GetRename().GetTo())
+	case "cross-referenced":
+		src := e.GetSource().GetIssue()
+		if repo := src.GetRepository().GetFullName(); repo != "" {
+			return fmt.Sprintf("from %s#%d", repo, src.GetNumber())
+		}
+		return fmt.Sprintf("from #%d", src.GetNumber())
+	case "review_requested", "review_request_removed":
+		if team := e.GetRequestedTeam().GetName(); team != "" {
+			return team
+		}
+		return e.GetReviewer().GetLogin()
+	case "reviewed":
+		return strings.ToLower(e.GetState())
+	case "committed":
+		title, _, _ := strings.Cut(e.GetMessage(), "\n")
+		return shortSHA(e.GetSHA()) + " " + title
+	}
+	if id := e.GetCommitID(); id != "" {
+		// closed, referenced, merged, ... by a commit.
+		return "in " + shortSHA(id)
+	}
+	return ""
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// reactionCounts lists the reaction counts of r in GitHub's order, or nil
+// when there are none.
+func reactionCounts(r *gh.Reactions) render.Meta {
+	if r.GetTotalCount() == 0 {
+		return nil
+	}
+	var counts render.Meta
+	for _, c := range []render.Field{
+		{Key: "+1", Value: r.GetPlusOne()},
+		{Key: "-1", Value: r.GetMinusOne()},
+		{Key: "laugh", Value: r.GetLaugh()},
+		{Key: "confused", Value: r.GetConfused()},
+		{Key: "heart", Value: r.GetHeart()},
+		{Key: "hooray", Value: r.GetHooray()},
+		{Key: "rocket", Value: r.GetRocket()},
+		{Key: "eyes", Value: r.GetEyes()},
+	} {
+		if c.Value.(int) > 0 {
+			counts = append(counts, c)
+		}
+	}
+	return counts
+}
@@ -0,0 +1,254 @@
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	gh "github.com/google/go-github/v56/github"
+	"github.com/wnarutou/gitrieve/internal/lock"
+	"github.com/wnarutou/gitrieve/internal/scm"
+	"github.com/wnarutou/gitrieve/internal/scm/github"
+	"github.com/wnarutou/gitrieve/internal/storage"
+	"github.com/wnarutou/gitrieve/internal/typedef"
+	"github.com/wnarutou/gitrieve/internal/ui"
+)
+
+const (
+	snapshotFile = "metadata.json"
+	historyFile  = "metadata.history.json"
+)
+
+// Snapshot is what metadata.json holds: the settings of a repository that
+// live outside its git history and are lost when it is taken down.
+type Snapshot struct {
+	Repository       Repository                `json:"repository"`
+	Labels           []*gh.Label               `json:"labels"`
+	Milestones       []*gh.Milestone           `json:"milestones"`
+	BranchProtection map[string]*gh.Protection `json:"branch_protection"`
+}
+
+// Repository holds the repository settings and counters of a Snapshot.
+type Repository struct {
+	FullName      string    `json:"full_name"`
+	Description   string    `json:"description"`
+	Homepage      string    `json:"homepage"`
+	Topics        []string  `json:"topics"`
+	License       string    `json:"license"` // SPDX ID, empty when GitHub detects none
+	DefaultBranch string    `json:"default_branch"`
+	Visibility    string    `json:"visibility"`
+	Private       bool      `json:"private"`
+	Archived      bool      `json:"archived"`
+	Disabled      bool      `json:"disabled"`
+	Fork          bool      `json:"fork"`
+	Parent        string    `json:"parent,omitempty"` // full name of the repository this one is forked from
+	Stars         int       `json:"stargazers_count"`
+	Forks         int       `json:"forks_count"`
+	Watchers      int       `json:"subscribers_count"`
+	OpenIssues    int       `json:"open_issues_count"`
+	CreatedAt     time.Time `json:"created_at"`
+	PushedAt      time.Time `json:"pushed_at"`
+}
+
+// Entry is one element of metadata.history.json: the tracked fields that
+// differed from the previous snapshot when a sync took a new one. The first
+// entry of a history records the first snapshot, its fields changing from
+// null.
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Changes []Change  `json:"changes"`
+}
+
+// Change is one changed field of an Entry, with its JSON values.
+type Change struct {
+	Field string          `json:"field"`
+	From  json.RawMessage `json:"from"`
+	To    json.RawMessage `json:"to"`
+}
+
+// Sync writes a metadata.json snapshot of a repository's settings to every
+// storage, and appends to metadata.history.json next to it when a tracked
+// field (see tracked) changed since the snapshot it replaces. Each storage
+// keeps its own history.
+func Sync(ctx context.Context, repo typedef.Repository, storages []typedef.MultiStorage) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	r, err := scm.NewRepository(repo.URL)
+	if err != nil {
+		return err
+	}
+
+	// Serialize concurrent syncs of the same repo's metadata: they
+	// read-modify-write the same history file.
+	unlock, err := lock.Acquire(ctx, r, "metadata")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	c, err := github.New(r.Host)
+	if err != nil {
+		return err
+	}
+	snapshot, err := fetch(ctx, c, r)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", snapshotFile, err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, s := range storages {
+		backend, err := storage.GetStorage(s)
+		if err != nil {
+			return err
+		}
+		dir := path.Join(s.Path, r.Host, r.Owner, r.Name)
+		var history []Entry
+		if err := load(backend, path.Join(dir, historyFile), &history); err != nil {
+			return err
+		}
+		var previous *Snapshot
+		if err := load(backend, path.Join(dir, snapshotFile), &previous); err != nil {
+			return err
+		}
+		if changes := diff(previous, snapshot); len(changes) > 0 {
+			history = append(history, Entry{Time: now, Changes: changes})
+			encoded, err := json.MarshalIndent(history, "", "  ")
+			if err != nil {
+				return fmt.Errorf("encode %s: %w", historyFile, err)
+			}
+			if err := backend.PutObject(path.Join(dir, historyFile), encoded); err != nil {
+				return err
+			}
+			ui.Printf("Recorded %d metadata change(s) of %s in storage %s", len(changes), snapshot.Repository.FullName, s.Name)
+		}
+		if err := backend.PutObject(path.Join(dir, snapshotFile), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fetch(ctx context.Context, c *github.Client, r *scm.Repository) (*Snapshot, error) {
+	info, err := c.GetRepository(ctx, r.Owner, r.Name)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := c.GetLabels(ctx, r.Owner, r.Name)
+	if err != nil {
+		return nil, err
+	}
+	milestones, err := c.GetMilestones(ctx, r.Owner, r.Name)
+	if err != nil {
+		return nil, err
+	}
+	protections, err := c.GetBranchProtections(ctx, r.Owner, r.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{
+		Repository: Repository{
+			FullName:      info.GetFullName(),
+			Description:   info.GetDescription(),
+			Homepage:      info.GetHomepage(),
+			Topics:        info.Topics,
+			License:       info.GetLicense().GetSPDXID(),
+			DefaultBranch: info.GetDefaultBranch(),
+			Visibility:    info.GetVisibility(),
+			Private:       info.GetPrivate(),
+			Archived:      info.GetArchived(),
+			Disabled:      info.GetDisabled(),
+			Fork:          info.GetFork(),
+			Parent:        info.GetParent().GetFullName(),
+			Stars:         info.GetStargazersCount(),
+			Forks:         info.GetForksCount(),
+			Watchers:      info.GetSubscribersCount(),
+			OpenIssues:    info.GetOpenIssuesCount(),
+			CreatedAt:     info.GetCreatedAt().Time,
+			PushedAt:      info.GetPushedAt().Time,
+		},
+		Labels:           labels,
+		Milestones:       milestones,
+		BranchProtection: protections,
+	}, nil
+}
+
+// load decodes the JSON object at p into v, leaving v alone when there is
+// none yet.
+func load(backend storage.Storage, p string, v interface{}) error {
+	obj, err := backend.GetObject(p)
+	if errors.Is(err, storage.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(obj.Content, v); err != nil {
+		return fmt.Errorf("parse %s: %w", p, err)
+	}
+	return nil
+}
+
+// field is a tracked field of a Snapshot.
+type field struct {
+	name  string
+	value interface{}
+}
+
+// tracked returns the fields of s whose changes are recorded in the history.
+// Counters and the push time are left out: they change on nearly every sync.
+func tracked(s *Snapshot) []field {
+	labels := make([]string, 0, len(s.Labels))
+	for _, l := range s.Labels {
+		labels = append(labels, l.GetName())
+	}
+	milestones := make([]string, 0, len(s.Milestones))
+	for _, m := range s.Milestones {
+		milestones = append(milestones, m.GetTitle())
+	}
+	r := s.Repository
+	return []field{
+		{"full_name", r.FullName},
+		{"description", r.Description},
+		{"homepage", r.Homepage},
+		{"topics", r.Topics},
+		{"license", r.License},
+		{"default_branch", r.DefaultBranch},
+		{"visibility", r.Visibility},
+		{"private", r.Private},
+		{"archived", r.Archived},
+		{"disabled", r.Disabled},
+		{"fork", r.Fork},
+		{"parent", r.Parent},
+		{"labels", labels},
+		{"milestones", milestones},
+		{"branch_protection", s.BranchProtection},
+	}
+}
+
+// diff returns the tracked fields that differ between previous, which is nil
+// before the first snapshot, and current.
+func diff(previous, current *Snapshot) []Change {
+	var before []field
+	if previous != nil {
+		before = tracked(previous)
+	}
+	var changes []Change
+	for i, f := range tracked(current) {
+		to, _ := json.Marshal(f.value)
+		from := json.RawMessage("null")
+		if before != nil {
+			from, _ = json.Marshal(before[i].value)
+		}
+		if !bytes.Equal(from, to) {
+			changes = append(changes, Change{Field: f.name, From: from, To: to})
+		}
+	}
+	return changes
+}
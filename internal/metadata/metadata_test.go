@@ -0,0 +1,94 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wnarutou/gitrieve/internal/scm/github/githubtest"
+	"github.com/wnarutou/gitrieve/internal/storage"
+	"github.com/wnarutou/gitrieve/internal/typedef"
+)
+
+func TestSyncCancelledContextReturnsImmediately(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := Sync(ctx, typedef.Repository{URL: "github.com/test/repo"}, nil)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestSyncRecordsSnapshotAndChangeHistory(t *testing.T) {
+	repository := `{"full_name":"owner/repo","description":"A tool","topics":["backup"],
+		"license":{"spdx_id":"MIT"},"default_branch":"main","visibility":"public","stargazers_count":%d}`
+	stars := 1
+	host := githubtest.Serve(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/repos/owner/repo":
+			fmt.Fprintf(w, repository, stars)
+		case "/repos/owner/repo/labels":
+			fmt.Fprint(w, `[{"name":"bug","color":"d73a4a"}]`)
+		case "/repos/owner/repo/milestones":
+			require.Equal(t, "all", r.URL.Query().Get("state"))
+			fmt.Fprint(w, `[{"number":1,"title":"v1.0","state":"closed"}]`)
+		case "/repos/owner/repo/branches":
+			require.Equal(t, "true", r.URL.Query().Get("protected"))
+			fmt.Fprint(w, `[{"name":"main","protected":true},{"name":"release","protected":true}]`)
+		case "/repos/owner/repo/branches/main/protection":
+			fmt.Fprint(w, `{"required_linear_history":{"enabled":true}}`)
+		case "/repos/owner/repo/branches/release/protection":
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"message":"Resource not accessible by integration"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	dir := t.TempDir()
+	storages := []typedef.MultiStorage{{Storage: typedef.Storage{Name: "local", Type: storage.FileStorage, Path: dir}}}
+	repo := typedef.Repository{URL: host + "/owner/repo"}
+	t.Cleanup(func() { os.RemoveAll(".gitrieve") })
+	base := path.Join(dir, host, "owner", "repo")
+	readHistory := func() []Entry {
+		data, err := os.ReadFile(path.Join(base, "metadata.history.json"))
+		require.NoError(t, err)
+		var history []Entry
+		require.NoError(t, json.Unmarshal(data, &history))
+		return history
+	}
+
+	require.NoError(t, Sync(context.Background(), repo, storages))
+	data, err := os.ReadFile(path.Join(base, "metadata.json"))
+	require.NoError(t, err)
+	var snapshot Snapshot
+	require.NoError(t, json.Unmarshal(data, &snapshot))
+	require.Equal(t, "MIT", snapshot.Repository.License)
+	require.Equal(t, 1, snapshot.Repository.Stars)
+	require.Equal(t, "v1.0", snapshot.Milestones[0].GetTitle())
+	require.True(t, snapshot.BranchProtection["main"].GetRequireLinearHistory().Enabled)
+	require.Contains(t, snapshot.BranchProtection, "release", "a protected branch is recorded without admin rights")
+	require.Nil(t, snapshot.BranchProtection["release"])
+	history := readHistory()
+	require.Len(t, history, 1)
+	require.Equal(t, "full_name", history[0].Changes[0].Field)
+	require.JSONEq(t, `null`, string(history[0].Changes[0].From))
+
+	stars = 2
+	require.NoError(t, Sync(context.Background(), repo, storages))
+	require.Len(t, readHistory(), 1, "counter changes are not recorded")
+
+	repository = strings.Replace(repository, `"full_name":"owner/repo"`, `"full_name":"owner/renamed","archived":true`, 1)
+	require.NoError(t, Sync(context.Background(), repo, storages))
+	history = readHistory()
+	require.Len(t, history, 2)
+	require.Equal(t, []Change{
+		{Field: "full_name", From: json.RawMessage(`"owner/repo"`), To: json.RawMessage(`"owner/renamed"`)},
+		{Field: "archived", From: json.RawMessage(`false`), To: json.RawMessage(`true`)},
+	}, history[1].Changes)
+}
@@ -0,0 +1,133 @@
+package project
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/wnarutou/gitrieve/internal/render"
+)
+
+// renderMarkdown renders the human-oriented view of one project: its
+// description, fields and views, and a table of its items with a column per
+// field.
+func renderMarkdown(project Project) (string, error) {
+	meta := render.Meta{
+		{Key: "number", Value: project.Number},
+		{Key: "title", Value: project.Title},
+		{Key: "creator", Value: project.Creator},
+		{Key: "public", Value: project.Public},
+		{Key: "closed", Value: project.Closed},
+		{Key: "created_at", Value: project.CreatedAt},
+		{Key: "updated_at", Value: project.UpdatedAt},
+	}
+	if project.URL != "" {
+		meta = append(meta, render.Field{Key: "url", Value: project.URL})
+	}
+	meta = append(meta, render.Field{Key: "items", Value: len(project.Items)})
+	fm, err := render.FrontMatter(meta)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString(fm)
+	fmt.Fprintf(&b, "\n# %s\n", project.Title)
+	if project.ShortDescription != "" {
+		fmt.Fprintf(&b, "\n%s\n", project.ShortDescription)
+	}
+	if readme := render.Body(project.Readme); readme != "" {
+		fmt.Fprintf(&b, "\n%s\n", readme)
+	}
+
+	if len(project.Fields) > 0 {
+		b.WriteString("\n## Fields\n\n")
+		for _, f := range project.Fields {
+			fmt.Fprintf(&b, "- %s (%s)", f.Name, strings.ToLower(strings.ReplaceAll(f.DataType, "_", " ")))
+			if len(f.Options) > 0 {
+				fmt.Fprintf(&b, ": %s", strings.Join(f.Options, ", "))
+			}
+			var iterations []string
+			for _, it := range f.Iterations {
+				iterations = append(iterations, fmt.Sprintf("%s from %s, %d days", it.Title, it.StartDate, it.Duration))
+			}
+			if len(iterations) > 0 {
+				fmt.Fprintf(&b, ": %s", strings.Join(iterations, "; "))
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if len(project.Views) > 0 {
+		b.WriteString("\n## Views\n\n")
+		for _, v := range project.Views {
+			fmt.Fprintf(&b, "- %s (%s)", v.Name, strings.ToLower(strings.TrimSuffix(v.Layout, "_LAYOUT")))
+			if v.Filter != "" {
+				fmt.Fprintf(&b, ", filter `%s`", v.Filter)
+			}
+			if len(v.Fields) > 0 {
+				fmt.Fprintf(&b, ", showing %s", strings.Join(v.Fields, ", "))
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if len(project.Items) > 0 {
+		b.WriteString("\n## Items\n\n")
+		header := []string{"Item"}
+		for _, f := range project.Fields {
+			header = append(header, cell(f.Name))
+		}
+		fmt.Fprintf(&b, "| %s |\n", strings.Join(header, " | "))
+		fmt.Fprintf(&b, "|%s\n", strings.Repeat(" --- |", len(header)))
+		for _, item := range project.Items {
+			row := []string{itemRef(item)}
+			for _, f := range project.Fields {
+				row = append(row, cell(valueOf(item, f.Name)))
+			}
+			fmt.Fprintf(&b, "| %s |\n", strings.Join(row, " | "))
+		}
+	}
+	return b.String(), nil
+}
+
+// itemRef names an item by what it points at: owner/repo#12 linked to the
+// issue or pull request, or "draft".
+func itemRef(item Item) string {
+	ref := "draft"
+	switch {
+	case item.Number != 0 && item.URL != "":
+		ref = fmt.Sprintf("[%s#%d](%s)", item.Repository, item.Number, item.URL)
+	case item.Number != 0:
+		ref = fmt.Sprintf("%s#%d", item.Repository, item.Number)
+	case item.Type == "REDACTED":
+		ref = "redacted"
+	}
+	if item.Archived {
+		ref += " (archived)"
+	}
+	return ref
+}
+
+func valueOf(item Item, field string) string {
+	for _, v := range item.Values {
+		if v.Field != field {
+			continue
+		}
+		switch value := v.Value.(type) {
+		case []string:
+			return strings.Join(value, ", ")
+		case float64:
+			return strconv.FormatFloat(value, 'f', -1, 64)
+		default:
+			return fmt.Sprint(value)
+		}
+	}
+	return ""
+}
+
+// cell makes s safe inside a markdown table cell.
+func cell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.Join(strings.Fields(s), " ")
+}
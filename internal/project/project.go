@@ -0,0 +1,709 @@
+package project
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shurcooL/githubv4"
+	"github.com/wnarutou/gitrieve/internal/archive"
+	"github.com/wnarutou/gitrieve/internal/config"
+	"github.com/wnarutou/gitrieve/internal/lock"
+	"github.com/wnarutou/gitrieve/internal/retry"
+	"github.com/wnarutou/gitrieve/internal/scm"
+	"github.com/wnarutou/gitrieve/internal/scm/github"
+	"github.com/wnarutou/gitrieve/internal/storage"
+	"github.com/wnarutou/gitrieve/internal/syncstate"
+	"github.com/wnarutou/gitrieve/internal/typedef"
+	"github.com/wnarutou/gitrieve/internal/ui"
+)
+
+// Define structures for storing query results
+type Project struct {
+	Number           int       `json:"number"`
+	Title            string    `json:"title"`
+	ShortDescription string    `json:"shortDescription"`
+	Readme           string    `json:"readme"`
+	URL              string    `json:"url"`
+	Public           bool      `json:"public"`
+	Closed           bool      `json:"closed"`
+	Creator          string    `json:"creator"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+	Fields           []Field   `json:"fields"`
+	Views            []View    `json:"views"`
+	Items            []Item    `json:"items"`
+}
+
+type Field struct {
+	Name       string      `json:"name"`
+	DataType   string      `json:"dataType"`
+	Options    []string    `json:"options,omitempty"`    // single select fields
+	Iterations []Iteration `json:"iterations,omitempty"` // iteration fields, completed ones included
+}
+
+type Iteration struct {
+	Title     string `json:"title"`
+	StartDate string `json:"startDate"`
+	Duration  int    `json:"duration"` // days
+}
+
+type View struct {
+	Number int      `json:"number"`
+	Name   string   `json:"name"`
+	Layout string   `json:"layout"`
+	Filter string   `json:"filter"`
+	Fields []string `json:"fields"` // visible fields, in order
+}
+
+// Item is a card of a project: an issue, a pull request or a draft issue, and
+// the values it has for the project's fields.
+type Item struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"` // ISSUE, PULL_REQUEST, DRAFT_ISSUE or REDACTED
+	Archived   bool      `json:"archived"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+	Repository string    `json:"repository,omitempty"`
+	Number     int       `json:"number,omitempty"`
+	Title      string    `json:"title"`
+	URL        string    `json:"url,omitempty"`
+	State      string    `json:"state,omitempty"`
+	Body       string    `json:"body,omitempty"` // draft issues only
+	Values     []Value   `json:"values"`
+}
+
+// Value is the value of one field of an item: a string, a number or, for
+// labels and users, a list of names.
+type Value struct {
+	Field string      `json:"field"`
+	Value interface{} `json:"value"`
+}
+
+type pageInfo struct {
+	HasNextPage bool
+	EndCursor   githubv4.String
+}
+
+// morePages is the page info of a connection fetched in one fixed-size page,
+// where only truncation is reported.
+type morePages struct {
+	HasNextPage bool
+}
+
+type projectSummary struct {
+	Number    int
+	UpdatedAt time.Time
+}
+
+type projectList struct {
+	Nodes    []projectSummary
+	PageInfo pageInfo
+}
+
+// fieldName selects the name of the field a value or view column belongs to.
+type fieldName struct {
+	Common struct {
+		Name string
+	} `graphql:"... on ProjectV2FieldCommon"`
+}
+
+type iteration struct {
+	Title     string
+	StartDate string
+	Duration  int
+}
+
+type fieldNode struct {
+	Common struct {
+		Name     string
+		DataType string
+	} `graphql:"... on ProjectV2FieldCommon"`
+	SingleSelect struct {
+		Options []struct {
+			Name string
+		}
+	} `graphql:"... on ProjectV2SingleSelectField"`
+	Iteration struct {
+		Configuration struct {
+			Iterations          []iteration
+			CompletedIterations []iteration
+		}
+	} `graphql:"... on ProjectV2IterationField"`
+}
+
+type viewNode struct {
+	Number int
+	Name   string
+	Layout string
+	Filter string
+	Fields struct {
+		Nodes    []fieldName
+		PageInfo morePages
+	} `graphql:"fields(first: 50)"`
+}
+
+type projectNode struct {
+	Number           int
+	Title            string
+	ShortDescription string
+	Readme           string
+	URL              string
+	Public           bool
+	Closed           bool
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Creator          struct {
+		Login string
+	}
+	Fields struct {
+		Nodes    []fieldNode
+		PageInfo morePages
+	} `graphql:"fields(first: 100)"`
+	Views struct {
+		Nodes    []viewNode
+		PageInfo morePages
+	} `graphql:"views(first: 50)"`
+}
+
+type contentNode struct {
+	Number     int
+	Title      string
+	URL        string
+	Repository struct {
+		NameWithOwner string
+	}
+}
+
+// valueNode is one field value of an item. Every fragment sees the field
+// name, so Typename tells which one holds the value.
+type valueNode struct {
+	Typename string `graphql:"__typename"`
+	Text     struct {
+		Text  string
+		Field fieldName
+	} `graphql:"... on ProjectV2ItemFieldTextValue"`
+	Number struct {
+		Number float64
+		Field  fieldName
+	} `graphql:"... on ProjectV2ItemFieldNumberValue"`
+	Date struct {
+		Date  string
+		Field fieldName
+	} `graphql:"... on ProjectV2ItemFieldDateValue"`
+	SingleSelect struct {
+		Name  string
+		Field fieldName
+	} `graphql:"... on ProjectV2ItemFieldSingleSelectValue"`
+	Iteration struct {
+		Title string
+		Field fieldName
+	} `graphql:"... on ProjectV2ItemFieldIterationValue"`
+	Labels struct {
+		Labels struct {
+			Nodes []struct {
+				Name string
+			}
+			PageInfo morePages
+		} `graphql:"labels(first: 20)"`
+		Field fieldName
+	} `graphql:"... on ProjectV2ItemFieldLabelValue"`
+	Users struct {
+		Users struct {
+			Nodes []struct {
+				Login string
+			}
+			PageInfo morePages
+		} `graphql:"users(first: 20)"`
+		Field fieldName
+	} `graphql:"... on ProjectV2ItemFieldUserValue"`
+	Milestone struct {
+		Milestone struct {
+			Title string
+		}
+		Field fieldName
+	} `graphql:"... on ProjectV2ItemFieldMilestoneValue"`
+	Repository struct {
+		Repository struct {
+			NameWithOwner string
+		}
+		Field fieldName
+	} `graphql:"... on ProjectV2ItemFieldRepositoryValue"`
+}
+
+type itemNode struct {
+	ID         string
+	Type       string
+	IsArchived bool
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Content    struct {
+		Typename string `graphql:"__typename"`
+		Issue    struct {
+			contentNode
+			State string
+		} `graphql:"... on Issue"`
+		// Issue and pull request states are different enums, which GraphQL
+		// will not merge into one field.
+		PullRequest struct {
+			contentNode
+			State string `graphql:"pullRequestState: state"`
+		} `graphql:"... on PullRequest"`
+		DraftIssue struct {
+			Title string
+			Body  string
+		} `graphql:"... on DraftIssue"`
+	}
+	FieldValues struct {
+		Nodes    []valueNode
+		PageInfo morePages
+	} `graphql:"fieldValues(first: 50)"`
+}
+
+// Linked project list query
+type repositoryProjectsQuery struct {
+	Repository struct {
+		ProjectsV2 projectList `graphql:"projectsV2(first: $projectCount, after: $projectCursor)"`
+	} `graphql:"repository(owner: $owner, name: $name)"`
+}
+
+// Owner project list query
+type ownerProjectsQuery struct {
+	RepositoryOwner struct {
+		Owner struct {
+			ProjectsV2 projectList `graphql:"projectsV2(first: $projectCount, after: $projectCursor)"`
+		} `graphql:"... on ProjectV2Owner"`
+	} `graphql:"repositoryOwner(login: $owner)"`
+}
+
+// Project query, with its fields and views
+type projectQuery struct {
+	RepositoryOwner struct {
+		Owner struct {
+			ProjectV2 projectNode `graphql:"projectV2(number: $number)"`
+		} `graphql:"... on ProjectV2Owner"`
+	} `graphql:"repositoryOwner(login: $owner)"`
+}
+
+// Item query, one page of a project's items with their field values
+type itemsQuery struct {
+	RepositoryOwner struct {
+		Owner struct {
+			ProjectV2 struct {
+				Items struct {
+					Nodes    []itemNode
+					PageInfo pageInfo
+				} `graphql:"items(first: $itemCount, after: $itemCursor)"`
+			} `graphql:"projectV2(number: $number)"`
+		} `graphql:"... on ProjectV2Owner"`
+	} `graphql:"repositoryOwner(login: $owner)"`
+}
+
+// Sync archives the Projects v2 boards linked to a repository, and with
+// projects.includeOwner every board of its owner, as projects/<number>.json
+// (fields, views, items and their field values) and a readable
+// projects/<number>.md, stored as projects.tar.gz. A project's updatedAt is
+// the incremental cursor: only projects changed since the last sync, or not
+// archived yet, are fetched, and every project once per reconcileInterval.
+func Sync(ctx context.Context, repo typedef.Repository, storages []typedef.MultiStorage) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	isUpdated := false
+	useCache := repo.UseCache
+	currentDir, err := os.Getwd()
+	if err != nil {
+		ui.Errorf("Error getting current directory: %s", err)
+		return err
+	}
+
+	var workingDir string
+	if useCache {
+		workingDir = path.Join(currentDir, ".gitrieve")
+	} else {
+		id := uuid.New().String()
+		workingDir = path.Join(currentDir, ".gitrieve", id)
+	}
+
+	err = storage.CreateDirIfNotExist(workingDir)
+	if err != nil {
+		ui.Errorf("Error creating working directory, %s", err)
+		return err
+	}
+
+	r, err := scm.NewRepository(repo.URL)
+	if err != nil {
+		return err
+	}
+	repoName := r.Name
+	if repoName == "." || repoName == "/" {
+		ui.Errorf("Invalid repository name")
+		return err
+	}
+
+	// Serialize concurrent syncs of the same repo's projects: they share
+	// the .gitrieve/projects cache dir and the projects.tar.gz path.
+	unlock, err := lock.Acquire(ctx, r, "project", currentDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	projectDir := path.Join(workingDir, r.Host, r.Owner, repoName, "projects")
+	err = storage.CreateDirIfNotExist(projectDir)
+	if err != nil {
+		ui.Errorf("Error creating working directory, %s", err)
+		return err
+	}
+	if !useCache {
+		defer func() {
+			if err := os.RemoveAll(projectDir); err != nil {
+				ui.Errorf("Error cleaning up working directory: %s", err)
+				return
+			}
+			ui.Printf("Cleanup completed for directory: %s", projectDir)
+		}()
+	}
+
+	// The incremental cursor comes from the sync state, which a run without a
+	// cache pulls back from storage together with the archive.
+	repoDir := path.Join(workingDir, r.Host, r.Owner, repoName)
+	state, ok, err := syncstate.Load(ctx, r, syncstate.Projects, repoDir, projectDir, storages)
+	if err != nil {
+		ui.Errorf("Error loading project sync state: %s", err)
+		return err
+	}
+	if !ok {
+		state = syncstate.State{}
+		ui.Printf("No project downloaded yet, need to download all projects")
+	} else {
+		ui.Printf("The latest update time among all projects is: %s", state.Cursor)
+	}
+	lastUpdate := state.Cursor
+
+	client, err := github.NewGraphQLClient(r.Host)
+	if err != nil {
+		ui.Errorf("Error creating github client, %s", err)
+		return err
+	}
+
+	projects, err := listProjects(ctx, client, r, repo.Projects.IncludeOwner)
+	if err != nil {
+		ui.Errorf("Error fetching projects: %s", err)
+		return err
+	}
+	// Editing an issue or pull request on a board does not move the project's
+	// updatedAt, so the cursor cannot see it: every reconcileInterval, fetch
+	// every project again and rewrite the ones that differ. A full sync has
+	// just fetched everything and only starts the clock.
+	now := time.Now().UTC()
+	interval := config.GetReconcileInterval()
+	due := interval >= 0 && now.Sub(state.Reconciled) >= interval
+	if due && ok {
+		ui.Printf("Fetching every project again for changes to their items")
+	}
+	for _, summary := range projects {
+		jsonFilePath := path.Join(projectDir, fmt.Sprintf("%d.json", summary.Number))
+		// A project older than the cursor may still be new to the archive,
+		// e.g. one linked since or an owner's board just included.
+		prev, err := os.ReadFile(jsonFilePath)
+		if err == nil && !summary.UpdatedAt.After(lastUpdate) && !(due && ok) {
+			continue
+		}
+
+		project, err := fetchProject(ctx, client, r, summary.Number)
+		if err != nil {
+			ui.Errorf("Error fetching project %d: %s", summary.Number, err)
+			return err
+		}
+		data, err := json.MarshalIndent(project, "", "  ")
+		if err != nil {
+			ui.Errorf("Error encoding project %d: %s", project.Number, err)
+			return err
+		}
+		if !bytes.Equal(data, prev) {
+			isUpdated = true
+			if err := os.WriteFile(jsonFilePath, data, 0644); err != nil {
+				ui.Errorf("Error writing project file %s: %s", jsonFilePath, err)
+				return err
+			}
+			content, err := renderMarkdown(project)
+			if err != nil {
+				ui.Errorf("Error rendering project %d: %s", project.Number, err)
+				return err
+			}
+			mdFilePath := path.Join(projectDir, fmt.Sprintf("%d.md", project.Number))
+			if err := os.WriteFile(mdFilePath, []byte(content), 0644); err != nil {
+				ui.Errorf("Error writing project file %s: %s", mdFilePath, err)
+				return err
+			}
+			ui.Printf("Success writing project %s to file %s", project.Title, jsonFilePath)
+		}
+		if summary.UpdatedAt.After(state.Cursor) {
+			state.Cursor = summary.UpdatedAt
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	if isUpdated {
+		// Archive the projects dir directly from projectDir. Create takes an
+		// absolute path and never changes the process cwd, so it is safe to
+		// run from concurrent job goroutines.
+		buf, err := archive.Create(ctx, projectDir, "projects")
+		if err != nil {
+			ui.Errorf("Error creating archive: %s", err)
+			return err
+		}
+
+		base := "projects.tar.gz"
+
+		for _, s := range storages {
+			backend, err := storage.GetStorage(s)
+			if err != nil {
+				ui.Errorf("Error getting backend: %s", err)
+				return err
+			}
+			err = backend.PutObject(path.Join(s.Path, r.Host, r.Owner, r.Name, base), buf.Bytes())
+			if err != nil {
+				ui.Errorf("Error storing file: %s", err)
+				return err
+			}
+			ui.Printf("File %s stored", path.Join(s.Path, r.Host, r.Owner, r.Name, base))
+		}
+	} else {
+		ui.Printf("All is up to date, no need to restore")
+	}
+
+	if due {
+		state.Reconciled = now
+	}
+	if isUpdated || due {
+		if !useCache {
+			repoDir = ""
+		}
+		if err := syncstate.Save(r, syncstate.Projects, repoDir, state, storages); err != nil {
+			ui.Errorf("Error saving project sync state: %s", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// listProjects returns the projects linked to the repository, and with owner
+// those of its owner too, by number. A project linked to a repository always
+// belongs to the repository's owner, so numbers do not clash.
+func listProjects(ctx context.Context, client *githubv4.Client, r *scm.Repository, owner bool) ([]projectSummary, error) {
+	variables := map[string]interface{}{
+		"owner":         githubv4.String(r.Owner),
+		"projectCount":  githubv4.Int(50),
+		"projectCursor": (*githubv4.String)(nil),
+	}
+	byNumber := make(map[int]projectSummary)
+	for {
+		var query repositoryProjectsQuery
+		variables["name"] = githubv4.String(r.Name)
+		err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+			return client.Query(ctx, &query, variables)
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range query.Repository.ProjectsV2.Nodes {
+			byNumber[p.Number] = p
+		}
+		if !query.Repository.ProjectsV2.PageInfo.HasNextPage {
+			break
+		}
+		variables["projectCursor"] = githubv4.NewString(query.Repository.ProjectsV2.PageInfo.EndCursor)
+	}
+	if owner {
+		delete(variables, "name")
+		variables["projectCursor"] = (*githubv4.String)(nil)
+		for {
+			var query ownerProjectsQuery
+			err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+				return client.Query(ctx, &query, variables)
+			})
+			if err != nil {
+				return nil, err
+			}
+			page := query.RepositoryOwner.Owner.ProjectsV2
+			for _, p := range page.Nodes {
+				byNumber[p.Number] = p
+			}
+			if !page.PageInfo.HasNextPage {
+				break
+			}
+			variables["projectCursor"] = githubv4.NewString(page.PageInfo.EndCursor)
+		}
+	}
+	projects := make([]projectSummary, 0, len(byNumber))
+	for _, p := range byNumber {
+		projects = append(projects, p)
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Number < projects[j].Number })
+	return projects, nil
+}
+
+// fetchProject returns project number of the repository's owner with all of
+// its items.
+func fetchProject(ctx context.Context, client *githubv4.Client, r *scm.Repository, number int) (Project, error) {
+	variables := map[string]interface{}{
+		"owner":  githubv4.String(r.Owner),
+		"number": githubv4.Int(number),
+	}
+	var query projectQuery
+	err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+		return client.Query(ctx, &query, variables)
+	})
+	if err != nil {
+		return Project{}, err
+	}
+	project := newProject(query.RepositoryOwner.Owner.ProjectV2)
+
+	variables["itemCount"] = githubv4.Int(50)
+	variables["itemCursor"] = (*githubv4.String)(nil)
+	for {
+		if ctx.Err() != nil {
+			return Project{}, ctx.Err()
+		}
+		var query itemsQuery
+		err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+			return client.Query(ctx, &query, variables)
+		})
+		if err != nil {
+			return Project{}, fmt.Errorf("fetch items: %w", err)
+		}
+		items := query.RepositoryOwner.Owner.ProjectV2.Items
+		for _, item := range items.Nodes {
+			project.Items = append(project.Items, newItem(item))
+		}
+		if !items.PageInfo.HasNextPage {
+			return project, nil
+		}
+		variables["itemCursor"] = githubv4.NewString(items.PageInfo.EndCursor)
+	}
+}
+
+func newProject(node projectNode) Project {
+	project := Project{
+		Number:           node.Number,
+		Title:            node.Title,
+		ShortDescription: node.ShortDescription,
+		Readme:           node.Readme,
+		URL:              node.URL,
+		Public:           node.Public,
+		Closed:           node.Closed,
+		Creator:          node.Creator.Login,
+		CreatedAt:        node.CreatedAt,
+		UpdatedAt:        node.UpdatedAt,
+	}
+	for _, f := range node.Fields.Nodes {
+		field := Field{Name: f.Common.Name, DataType: f.Common.DataType}
+		for _, option := range f.SingleSelect.Options {
+			field.Options = append(field.Options, option.Name)
+		}
+		for _, it := range append(f.Iteration.Configuration.Iterations, f.Iteration.Configuration.CompletedIterations...) {
+			field.Iterations = append(field.Iterations, Iteration(it))
+		}
+		project.Fields = append(project.Fields, field)
+	}
+	if node.Fields.PageInfo.HasNextPage {
+		ui.Printf("Project %d has more than %d fields, archiving the first ones only", node.Number, len(node.Fields.Nodes))
+	}
+	for _, v := range node.Views.Nodes {
+		view := View{Number: v.Number, Name: v.Name, Layout: v.Layout, Filter: v.Filter}
+		for _, f := range v.Fields.Nodes {
+			view.Fields = append(view.Fields, f.Common.Name)
+		}
+		if v.Fields.PageInfo.HasNextPage {
+			ui.Printf("View %s of project %d shows more than %d fields, archiving the first ones only", v.Name, node.Number, len(v.Fields.Nodes))
+		}
+		project.Views = append(project.Views, view)
+	}
+	if node.Views.PageInfo.HasNextPage {
+		ui.Printf("Project %d has more than %d views, archiving the first ones only", node.Number, len(node.Views.Nodes))
+	}
+	return project
+}
+
+func newItem(node itemNode) Item {
+	item := Item{
+		ID:        node.ID,
+		Type:      node.Type,
+		Archived:  node.IsArchived,
+		CreatedAt: node.CreatedAt,
+		UpdatedAt: node.UpdatedAt,
+	}
+	var content contentNode
+	switch node.Content.Typename {
+	case "Issue":
+		content, item.State = node.Content.Issue.contentNode, node.Content.Issue.State
+	case "PullRequest":
+		content, item.State = node.Content.PullRequest.contentNode, node.Content.PullRequest.State
+	case "DraftIssue":
+		item.Title, item.Body = node.Content.DraftIssue.Title, node.Content.DraftIssue.Body
+	}
+	if content.Number != 0 {
+		item.Repository, item.Number = content.Repository.NameWithOwner, content.Number
+		item.Title, item.URL = content.Title, content.URL
+	}
+	for _, v := range node.FieldValues.Nodes {
+		if value, ok := newValue(v); ok {
+			item.Values = append(item.Values, value)
+		}
+		if v.Labels.Labels.PageInfo.HasNextPage {
+			ui.Printf("Item %s has more than %d labels in field %s, archiving the first ones only", node.ID, len(v.Labels.Labels.Nodes), v.Labels.Field.Common.Name)
+		}
+		if v.Users.Users.PageInfo.HasNextPage {
+			ui.Printf("Item %s has more than %d users in field %s, archiving the first ones only", node.ID, len(v.Users.Users.Nodes), v.Users.Field.Common.Name)
+		}
+	}
+	if node.FieldValues.PageInfo.HasNextPage {
+		ui.Printf("Item %s has more than %d field values, archiving the first ones only", node.ID, len(node.FieldValues.Nodes))
+	}
+	return item
+}
+
+// newValue converts a field value, reporting false for kinds not archived
+// (e.g. linked pull requests and reviewers, which the item content covers).
+func newValue(v valueNode) (Value, bool) {
+	switch v.Typename {
+	case "ProjectV2ItemFieldTextValue":
+		return Value{Field: v.Text.Field.Common.Name, Value: v.Text.Text}, true
+	case "ProjectV2ItemFieldNumberValue":
+		return Value{Field: v.Number.Field.Common.Name, Value: v.Number.Number}, true
+	case "ProjectV2ItemFieldDateValue":
+		return Value{Field: v.Date.Field.Common.Name, Value: v.Date.Date}, true
+	case "ProjectV2ItemFieldSingleSelectValue":
+		return Value{Field: v.SingleSelect.Field.Common.Name, Value: v.SingleSelect.Name}, true
+	case "ProjectV2ItemFieldIterationValue":
+		return Value{Field: v.Iteration.Field.Common.Name, Value: v.Iteration.Title}, true
+	case "ProjectV2ItemFieldLabelValue":
+		var names []string
+		for _, label := range v.Labels.Labels.Nodes {
+			names = append(names, label.Name)
+		}
+		return Value{Field: v.Labels.Field.Common.Name, Value: names}, true
+	case "ProjectV2ItemFieldUserValue":
+		var logins []string
+		for _, user := range v.Users.Users.Nodes {
+			logins = append(logins, user.Login)
+		}
+		return Value{Field: v.Users.Field.Common.Name, Value: logins}, true
+	case "ProjectV2ItemFieldMilestoneValue":
+		return Value{Field: v.Milestone.Field.Common.Name, Value: v.Milestone.Milestone.Title}, true
+	case "ProjectV2ItemFieldRepositoryValue":
+		return Value{Field: v.Repository.Field.Common.Name, Value: v.Repository.Repository.NameWithOwner}, true
+	}
+	return Value{}, false
+}
@@ -0,0 +1,174 @@
+package project
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wnarutou/gitrieve/internal/lock"
+	"github.com/wnarutou/gitrieve/internal/scm"
+	"github.com/wnarutou/gitrieve/internal/scm/github/githubtest"
+	"github.com/wnarutou/gitrieve/internal/typedef"
+)
+
+func TestSyncCancelledContextReturnsImmediately(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := Sync(ctx, typedef.Repository{URL: "github.com/test/repo"}, nil)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestSyncBlocksWhileLockHeld(t *testing.T) {
+	repo := typedef.Repository{URL: "github.com/test/repo", UseCache: true}
+	r, err := scm.NewRepository(repo.URL)
+	require.NoError(t, err)
+	release, err := lock.Acquire(context.Background(), r, "project")
+	require.NoError(t, err)
+	defer release()
+	t.Cleanup(func() { os.RemoveAll(".gitrieve") })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	err = Sync(ctx, repo, nil)
+	require.Equal(t, context.DeadlineExceeded, err, "project Sync must block on the held project lock")
+}
+
+func TestSyncArchivesChangedProjects(t *testing.T) {
+	var projectQueries []interface{}
+	var itemPages int
+	host := githubtest.Serve(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var req struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		switch {
+		case strings.Contains(req.Query, "repository(owner: $owner, name: $name)"):
+			fmt.Fprint(w, `{"data":{"repository":{"projectsV2":{"pageInfo":{"hasNextPage":false},"nodes":[{"number":1,"updatedAt":"2026-08-17T01:00:00Z"}]}}}}`)
+		case strings.Contains(req.Query, "projectsV2("):
+			require.NotContains(t, req.Variables, "name", "every declared variable must be used")
+			fmt.Fprint(w, `{"data":{"repositoryOwner":{"projectsV2":{"pageInfo":{"hasNextPage":false},"nodes":[
+				{"number":1,"updatedAt":"2026-08-17T01:00:00Z"},{"number":2,"updatedAt":"2026-08-10T00:00:00Z"}]}}}}`)
+		case strings.Contains(req.Query, "items("):
+			itemPages++
+			if req.Variables["number"] != float64(1) {
+				fmt.Fprint(w, `{"data":{"repositoryOwner":{"projectV2":{"items":{"pageInfo":{"hasNextPage":false},"nodes":[]}}}}}`)
+				return
+			}
+			if req.Variables["itemCursor"] == nil {
+				fmt.Fprint(w, `{"data":{"repositoryOwner":{"projectV2":{"items":{"pageInfo":{"hasNextPage":true,"endCursor":"i1"},"nodes":[
+					{"id":"PVTI_1","type":"ISSUE","createdAt":"2026-08-01T00:00:00Z","updatedAt":"2026-08-17T01:00:00Z",
+						"content":{"__typename":"Issue","number":12,"title":"Crash | on start","url":"https://github.com/owner/repo/issues/12","state":"OPEN","repository":{"nameWithOwner":"owner/repo"}},
+						"fieldValues":{"nodes":[
+							{"__typename":"ProjectV2ItemFieldTextValue","text":"Crash | on start","field":{"name":"Title"}},
+							{"__typename":"ProjectV2ItemFieldSingleSelectValue","name":"In Progress","field":{"name":"Status"}},
+							{"__typename":"ProjectV2ItemFieldNumberValue","number":3,"field":{"name":"Estimate"}},
+							{"__typename":"ProjectV2ItemFieldUserValue","users":{"nodes":[{"login":"alice"},{"login":"bob"}]},"field":{"name":"Assignees"}},
+							{"__typename":"ProjectV2ItemFieldPullRequestValue","field":{"name":"Linked pull requests"}}]}}]}}}}}`)
+				return
+			}
+			require.Equal(t, "i1", req.Variables["itemCursor"])
+			fmt.Fprint(w, `{"data":{"repositoryOwner":{"projectV2":{"items":{"pageInfo":{"hasNextPage":false},"nodes":[
+				{"id":"PVTI_2","type":"DRAFT_ISSUE","isArchived":true,"content":{"__typename":"DraftIssue","title":"Idea","body":"maybe"},
+					"fieldValues":{"nodes":[{"__typename":"ProjectV2ItemFieldTextValue","text":"Idea","field":{"name":"Title"}}]}}]}}}}}`)
+		case strings.Contains(req.Query, "projectV2("):
+			projectQueries = append(projectQueries, req.Variables["number"])
+			fmt.Fprintf(w, `{"data":{"repositoryOwner":{"projectV2":{"number":%v,"title":"Roadmap","shortDescription":"What is next",
+				"url":"https://github.com/orgs/owner/projects/1","creator":{"login":"alice"},"updatedAt":"2026-08-17T01:00:00Z",
+				"fields":{"nodes":[{"name":"Title","dataType":"TITLE"},{"name":"Status","dataType":"SINGLE_SELECT","options":[{"name":"Todo"},{"name":"In Progress"}]},
+					{"name":"Estimate","dataType":"NUMBER"},{"name":"Assignees","dataType":"ASSIGNEES"},
+					{"name":"Sprint","dataType":"ITERATION","configuration":{"iterations":[{"title":"Sprint 2","startDate":"2026-08-10","duration":14}]}}]},
+				"views":{"nodes":[{"number":1,"name":"Board","layout":"BOARD_LAYOUT","filter":"is:open","fields":{"nodes":[{"name":"Title"},{"name":"Status"}]}}]}}}}}`,
+				req.Variables["number"])
+		default:
+			t.Errorf("unexpected query %s", req.Query)
+		}
+	}))
+	repo := typedef.Repository{URL: host + "/owner/repo", UseCache: true}
+	dir := path.Join(".gitrieve", host, "owner", "repo", "projects")
+
+	require.NoError(t, Sync(context.Background(), repo, nil))
+	require.Equal(t, []interface{}{float64(1)}, projectQueries)
+	require.Equal(t, 2, itemPages)
+	data, err := os.ReadFile(path.Join(dir, "1.json"))
+	require.NoError(t, err)
+	var project Project
+	require.NoError(t, json.Unmarshal(data, &project))
+	require.Equal(t, []string{"Todo", "In Progress"}, project.Fields[1].Options)
+	require.Equal(t, []Iteration{{Title: "Sprint 2", StartDate: "2026-08-10", Duration: 14}}, project.Fields[4].Iterations)
+	require.Len(t, project.Items, 2)
+	require.Equal(t, "OPEN", project.Items[0].State)
+	require.Len(t, project.Items[0].Values, 4, "unsupported value kinds are left out")
+	require.Equal(t, "maybe", project.Items[1].Body)
+	md, err := os.ReadFile(path.Join(dir, "1.md"))
+	require.NoError(t, err)
+	for _, line := range []string{
+		"---\nnumber: 1\ntitle: Roadmap\ncreator: alice\n",
+		"# Roadmap\n\nWhat is next\n",
+		"- Status (single select): Todo, In Progress\n",
+		"- Sprint (iteration): Sprint 2 from 2026-08-10, 14 days\n",
+		"- Board (board), filter `is:open`, showing Title, Status\n",
+		"| Item | Title | Status | Estimate | Assignees | Sprint |\n",
+		"| [owner/repo#12](https://github.com/owner/repo/issues/12) | Crash \\| on start | In Progress | 3 | alice, bob |  |\n",
+		"| draft (archived) | Idea |  |  |  |  |\n",
+	} {
+		require.Contains(t, string(md), line)
+	}
+
+	// Nothing changed: no project is fetched again. Then the owner's boards
+	// are included: the one not archived yet is fetched although it is older
+	// than the cursor.
+	require.NoError(t, Sync(context.Background(), repo, nil))
+	require.Equal(t, []interface{}{float64(1)}, projectQueries)
+	repo.Projects.IncludeOwner = true
+	require.NoError(t, Sync(context.Background(), repo, nil))
+	require.Equal(t, []interface{}{float64(1), float64(2)}, projectQueries)
+	require.FileExists(t, path.Join(dir, "2.md"))
+}
+
+func TestSyncRefetchesProjectsOnReconcileInterval(t *testing.T) {
+	var projectQueries int
+	title := "Crash on start"
+	host := githubtest.ServeWithConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var req struct {
+			Query string `json:"query"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		switch {
+		case strings.Contains(req.Query, "projectsV2("):
+			fmt.Fprint(w, `{"data":{"repository":{"projectsV2":{"pageInfo":{"hasNextPage":false},"nodes":[{"number":1,"updatedAt":"2026-08-17T01:00:00Z"}]}}}}`)
+		case strings.Contains(req.Query, "items("):
+			fmt.Fprintf(w, `{"data":{"repositoryOwner":{"projectV2":{"items":{"pageInfo":{"hasNextPage":false},"nodes":[
+				{"id":"PVTI_1","type":"ISSUE","content":{"__typename":"Issue","number":12,"title":%q,"state":"OPEN","repository":{"nameWithOwner":"owner/repo"}},
+					"fieldValues":{"pageInfo":{"hasNextPage":true},"nodes":[]}}]}}}}}`, title)
+		case strings.Contains(req.Query, "projectV2("):
+			projectQueries++
+			fmt.Fprint(w, `{"data":{"repositoryOwner":{"projectV2":{"number":1,"title":"Roadmap","updatedAt":"2026-08-17T01:00:00Z"}}}}`)
+		default:
+			t.Errorf("unexpected query %s", req.Query)
+		}
+	}), "reconcileInterval: 1ns\n")
+	repo := typedef.Repository{URL: host + "/owner/repo", UseCache: true}
+	dir := path.Join(".gitrieve", host, "owner", "repo", "projects")
+
+	require.NoError(t, Sync(context.Background(), repo, nil))
+	require.Equal(t, 1, projectQueries)
+
+	// The project's updatedAt has not moved, but the issue on it was renamed.
+	title = "Crash on startup"
+	require.NoError(t, Sync(context.Background(), repo, nil))
+	require.Equal(t, 2, projectQueries, "every project is fetched again once the interval has passed")
+	data, err := os.ReadFile(path.Join(dir, "1.json"))
+	require.NoError(t, err)
+	require.Contains(t, string(data), title)
+}
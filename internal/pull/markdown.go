@@ -0,0 +1,127 @@
+package pull
+
+import (
+	"encoding/json"
+	"strings"
+	"text/template"
+
+	gh "github.com/google/go-github/v56/github"
+	"github.com/wnarutou/gitrieve/internal/render"
+)
+
+// renderMarkdown renders the human-oriented view of one pull request with
+// layout: the description, its commits, every review verdict and the review
+// comment threads grouped by the line they discuss.
+func renderMarkdown(layout *template.Template, pr *gh.PullRequest, rawReviews, rawComments, rawCommits []json.RawMessage) (string, error) {
+	var reviews []*gh.PullRequestReview
+	var comments []*gh.PullRequestComment
+	var commits []*gh.RepositoryCommit
+	for _, d := range []struct {
+		raw []json.RawMessage
+		out interface{}
+	}{{rawReviews, &reviews}, {rawComments, &comments}, {rawCommits, &commits}} {
+		data, err := json.Marshal(d.raw)
+		if err != nil {
+			return "", err
+		}
+		if err := json.Unmarshal(data, d.out); err != nil {
+			return "", err
+		}
+	}
+
+	doc := render.Document{
+		Kind:   "PullRequest",
+		Number: pr.GetNumber(),
+		Title:  pr.GetTitle(),
+		Body:   pr.GetBody(),
+	}
+	doc.Meta = render.Meta{
+		{Key: "number", Value: pr.GetNumber()},
+		{Key: "title", Value: pr.GetTitle()},
+		{Key: "state", Value: pr.GetState()},
+	}
+	if pr.GetDraft() {
+		doc.Meta = append(doc.Meta, render.Field{Key: "draft", Value: true})
+	}
+	doc.Meta = append(doc.Meta,
+		render.Field{Key: "author", Value: pr.GetUser().GetLogin()},
+		render.Field{Key: "created_at", Value: pr.GetCreatedAt().Time},
+		render.Field{Key: "updated_at", Value: pr.GetUpdatedAt().Time},
+	)
+	if pr.ClosedAt != nil {
+		doc.Meta = append(doc.Meta, render.Field{Key: "closed_at", Value: pr.GetClosedAt().Time})
+	}
+	if pr.MergedAt != nil {
+		doc.Meta = append(doc.Meta, render.Field{Key: "merged_at", Value: pr.GetMergedAt().Time})
+	}
+	doc.Meta = append(doc.Meta,
+		render.Field{Key: "base", Value: pr.GetBase().GetLabel()},
+		render.Field{Key: "head", Value: pr.GetHead().GetLabel()},
+	)
+	if url := pr.GetHTMLURL(); url != "" {
+		doc.Meta = append(doc.Meta, render.Field{Key: "url", Value: url})
+	}
+	var labels []string
+	for _, label := range pr.Labels {
+		labels = append(labels, label.GetName())
+	}
+	if len(labels) > 0 {
+		doc.Meta = append(doc.Meta, render.Field{Key: "labels", Value: labels})
+	}
+	doc.Meta = append(doc.Meta,
+		render.Field{Key: "commits", Value: len(commits)},
+		render.Field{Key: "reviews", Value: len(reviews)},
+		render.Field{Key: "review_comments", Value: len(comments)},
+	)
+
+	for _, c := range commits {
+		sha := c.GetSHA()
+		if len(sha) > 7 {
+			sha = sha[:7]
+		}
+		title, _, _ := strings.Cut(c.GetCommit().GetMessage(), "\n")
+		doc.Commits = append(doc.Commits, render.Commit{SHA: sha, Title: title, Author: c.GetCommit().GetAuthor().GetName()})
+	}
+	for _, rv := range reviews {
+		doc.Reviews = append(doc.Reviews, render.Review{
+			ID:          rv.GetID(),
+			State:       rv.GetState(),
+			Author:      rv.GetUser().GetLogin(),
+			SubmittedAt: rv.GetSubmittedAt().Time,
+			Body:        rv.GetBody(),
+		})
+	}
+
+	// Group replies under the comment that started their thread, keeping
+	// threads in the order they were started.
+	threads := make(map[int64]*render.Thread)
+	var roots []int64
+	for _, c := range comments {
+		root := c.GetInReplyTo()
+		if root == 0 {
+			root = c.GetID()
+		}
+		thread, ok := threads[root]
+		if !ok {
+			line := c.GetLine()
+			if line == 0 {
+				line = c.GetOriginalLine()
+			}
+			thread = &render.Thread{Path: c.GetPath(), Line: line, DiffHunk: c.GetDiffHunk()}
+			threads[root] = thread
+			roots = append(roots, root)
+		}
+		thread.Comments = append(thread.Comments, render.Comment{
+			Kind:      "Comment",
+			ID:        c.GetID(),
+			Author:    c.GetUser().GetLogin(),
+			CreatedAt: c.GetCreatedAt().Time,
+			UpdatedAt: c.GetUpdatedAt().Time,
+			Body:      c.GetBody(),
+		})
+	}
+	for _, root := range roots {
+		doc.Threads = append(doc.Threads, *threads[root])
+	}
+	return render.Render(layout, render.Pull, doc)
+}
@@ -0,0 +1,394 @@
+package pull
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	gh "github.com/google/go-github/v56/github"
+	"github.com/google/uuid"
+	"github.com/wnarutou/gitrieve/internal/archive"
+	"github.com/wnarutou/gitrieve/internal/config"
+	"github.com/wnarutou/gitrieve/internal/lock"
+	"github.com/wnarutou/gitrieve/internal/render"
+	"github.com/wnarutou/gitrieve/internal/retry"
+	"github.com/wnarutou/gitrieve/internal/scm"
+	"github.com/wnarutou/gitrieve/internal/scm/github"
+	"github.com/wnarutou/gitrieve/internal/storage"
+	"github.com/wnarutou/gitrieve/internal/syncstate"
+	"github.com/wnarutou/gitrieve/internal/typedef"
+	"github.com/wnarutou/gitrieve/internal/ui"
+)
+
+// Sync archives the code review history of a repository's pull requests:
+// per PR a directory #<number>/ holding the raw API objects (pull.json,
+// reviews.json, review_comments.json, commits.json), the .patch and .diff,
+// and a readable #<number>.md. Only PRs updated since the last sync are
+// fetched again; the result is stored as pulls.tar.gz.
+func Sync(ctx context.Context, repo typedef.Repository, storages []typedef.MultiStorage) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	isUpdated := false
+	useCache := repo.UseCache
+	// get current directory
+	currentDir, _ := os.Getwd()
+
+	var workingDir string
+	if useCache {
+		workingDir = path.Join(currentDir, ".gitrieve")
+	} else {
+		id := uuid.New().String()
+		workingDir = path.Join(currentDir, ".gitrieve", id)
+	}
+
+	// create a working directory if not exist
+	err := storage.CreateDirIfNotExist(workingDir)
+	if err != nil {
+		ui.Errorf("Error creating working directory, %s", err)
+		return err
+	}
+
+	// get the repo name from the URL
+	r, err := scm.NewRepository(repo.URL)
+	if err != nil {
+		return err
+	}
+	repoName := r.Name
+	// check if repo name is valid
+	if repoName == "." || repoName == "/" {
+		ui.Errorf("Invalid repository name")
+		return err
+	}
+
+	// Serialize concurrent syncs of the same repo's pull requests: they share
+	// the .gitrieve/pulls cache dir and the pulls.tar.gz storage path.
+	unlock, err := lock.Acquire(ctx, r, "pull", currentDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	pullDir := path.Join(workingDir, r.Host, r.Owner, repoName, "pulls")
+	err = storage.CreateDirIfNotExist(pullDir)
+	if err != nil {
+		ui.Errorf("Error creating working directory, %s", err)
+		return err
+	}
+	if !useCache {
+		defer func() {
+			if err := os.RemoveAll(pullDir); err != nil {
+				ui.Errorf("Error cleaning up working directory, %s", err)
+				return
+			}
+			ui.Printf("Cleanup completed for directory: %s", pullDir)
+		}()
+	}
+
+	// The incremental cursor comes from the sync state, which a run without a
+	// cache pulls back from storage together with the archive.
+	repoDir := path.Join(workingDir, r.Host, r.Owner, repoName)
+	state, ok, err := syncstate.Load(ctx, r, syncstate.Pulls, repoDir, pullDir, storages)
+	if err != nil {
+		ui.Errorf("Error loading pull request sync state: %s", err)
+		return err
+	}
+	if !ok {
+		// A cache from before the state file existed: take the cursor from
+		// the stored pull.json files once.
+		state = syncstate.State{}
+		state.Cursor, err = lastUpdated(pullDir)
+		if err != nil {
+			ui.Errorf("Error reading pull request directory: %s", err)
+			return err
+		}
+	}
+	lastUpdate := state.Cursor
+	if lastUpdate.IsZero() {
+		ui.Printf("No pull requests downloaded yet, need to download all pull requests")
+	} else {
+		ui.Printf("The latest update time among all pull requests is: %s", lastUpdate)
+	}
+
+	client, err := github.NewRESTClient(r.Host)
+	if err != nil {
+		ui.Errorf("Error creating github client, %s", err)
+		return err
+	}
+	layout, err := render.Parse(render.Pull, config.GetTemplate(render.Pull))
+	if err != nil {
+		ui.Errorf("Error parsing pull request template, %s", err)
+		return err
+	}
+
+	// The pulls endpoint has no since filter: walk newest-updated first and
+	// stop at the first PR not updated since the last sync. Review activity
+	// bumps a PR's updated_at, so it is the whole cursor.
+	opt := &gh.PullRequestListOptions{
+		State:       "all",
+		Sort:        "updated",
+		Direction:   "desc",
+		ListOptions: gh.ListOptions{PerPage: 100},
+	}
+	var pending []*gh.PullRequest
+	var pendingRaw []json.RawMessage
+	for done := false; !done; {
+		var (
+			rawPulls []json.RawMessage
+			resp     *gh.Response
+		)
+		err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+			var apiErr error
+			rawPulls, resp, apiErr = github.ListRaw(ctx, client, fmt.Sprintf("repos/%v/%v/pulls", r.Owner, r.Name), opt)
+			return apiErr
+		})
+		if err != nil {
+			ui.Errorf("Error fetching pull requests, %s", err)
+			return err
+		}
+		ui.Printf("Fetching page %d, total %d pull requests", opt.Page, len(rawPulls))
+
+		for _, raw := range rawPulls {
+			var pr gh.PullRequest
+			if err := json.Unmarshal(raw, &pr); err != nil {
+				ui.Errorf("Error decoding pull request, %s", err)
+				return err
+			}
+			if !lastUpdate.IsZero() && !pr.GetUpdatedAt().After(lastUpdate) {
+				done = true
+				break
+			}
+			pending = append(pending, &pr)
+			pendingRaw = append(pendingRaw, raw)
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	// Download oldest-updated first, so an interrupted sync never leaves a
+	// newer cursor in place than the PRs it skipped.
+	for i := len(pending) - 1; i >= 0; i-- {
+		isUpdated = true
+		if err := syncPull(ctx, client, r, pullDir, layout, pending[i], pendingRaw[i]); err != nil {
+			return err
+		}
+		if pending[i].GetUpdatedAt().After(state.Cursor) {
+			state.Cursor = pending[i].GetUpdatedAt().Time
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	if isUpdated {
+		// Archive the pulls dir directly from pullDir. Create takes an
+		// absolute path and never changes the process cwd, so it is safe to
+		// run from concurrent job goroutines.
+		buf, err := archive.Create(ctx, pullDir, "pulls")
+		if err != nil {
+			ui.Errorf("Error creating archive, %s", err)
+			return err
+		}
+
+		base := "pulls.tar.gz"
+
+		// Handle storages
+		for _, s := range storages {
+			backend, err := storage.GetStorage(s)
+			if err != nil {
+				ui.Errorf("Error getting backend, %s", err)
+				return err
+			}
+			err = backend.PutObject(path.Join(s.Path, r.Host, r.Owner, r.Name, base), buf.Bytes())
+			if err != nil {
+				ui.Errorf("Error storing file, %s", err)
+				return err
+			}
+			ui.Printf("File %s stored", path.Join(s.Path, r.Host, r.Owner, r.Name, base))
+		}
+		if !useCache {
+			repoDir = ""
+		}
+		if err := syncstate.Save(r, syncstate.Pulls, repoDir, state, storages); err != nil {
+			ui.Errorf("Error saving pull request sync state, %s", err)
+			return err
+		}
+	} else {
+		ui.Printf("All is up to date, no need to restore")
+	}
+
+	return nil
+}
+
+// maxListedCommits is how many commits GitHub lists for a pull request at
+// most; longer ones are cut off without an error.
+const maxListedCommits = 250
+
+// syncPull downloads everything of one pull request into pullDir/#<number>/.
+// pull.json is written last, so a directory without one is a PR that failed
+// halfway.
+func syncPull(ctx context.Context, client *gh.Client, r *scm.Repository, pullDir string, layout *template.Template, pr *gh.PullRequest,
+	raw json.RawMessage) error {
+	number := pr.GetNumber()
+	dir := path.Join(pullDir, fmt.Sprintf("#%d", number))
+	if err := storage.CreateDirIfNotExist(dir); err != nil {
+		ui.Errorf("Error creating working directory, %s", err)
+		return err
+	}
+	base := fmt.Sprintf("repos/%v/%v/pulls/%d", r.Owner, r.Name, number)
+
+	reviews, err := listAll(ctx, client, base+"/reviews")
+	if err != nil {
+		ui.Errorf("Error fetching reviews of pull request %d, %s", number, err)
+		return err
+	}
+	comments, err := listAll(ctx, client, base+"/comments")
+	if err != nil {
+		ui.Errorf("Error fetching review comments of pull request %d, %s", number, err)
+		return err
+	}
+	commits, err := listAll(ctx, client, base+"/commits")
+	if err != nil {
+		ui.Errorf("Error fetching commits of pull request %d, %s", number, err)
+		return err
+	}
+	if len(commits) >= maxListedCommits {
+		ui.Printf("Pull request %d lists only its first %d commits, the rest are in the code archive", number, maxListedCommits)
+	}
+	for name, items := range map[string][]json.RawMessage{
+		"reviews.json":         reviews,
+		"review_comments.json": comments,
+		"commits.json":         commits,
+	} {
+		if err := writeJSON(path.Join(dir, name), items); err != nil {
+			ui.Errorf("Error writing pull request file %s, %s", name, err)
+			return err
+		}
+	}
+
+	for ext, mediaType := range map[string]string{
+		"patch": "application/vnd.github.patch",
+		"diff":  "application/vnd.github.diff",
+	} {
+		var data []byte
+		err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+			var apiErr error
+			data, apiErr = github.GetMedia(ctx, client, base, mediaType)
+			return apiErr
+		})
+		if err != nil {
+			var respErr *gh.ErrorResponse
+			if errors.As(err, &respErr) && respErr.Response != nil &&
+				(respErr.Response.StatusCode == http.StatusNotAcceptable || respErr.Response.StatusCode == http.StatusUnprocessableEntity) {
+				// GitHub refuses to render diffs of very large PRs; the commits
+				// are still listed and live in the code archive.
+				ui.Printf("Pull request %d is too large for a .%s, skipping it", number, ext)
+				continue
+			}
+			ui.Errorf("Error fetching .%s of pull request %d, %s", ext, number, err)
+			return err
+		}
+		if err := os.WriteFile(path.Join(dir, fmt.Sprintf("#%d.%s", number, ext)), data, 0644); err != nil {
+			ui.Errorf("Error writing pull request file, %s", err)
+			return err
+		}
+	}
+
+	md, err := renderMarkdown(layout, pr, reviews, comments, commits)
+	if err != nil {
+		ui.Errorf("Error rendering pull request %d, %s", number, err)
+		return err
+	}
+	if err := os.WriteFile(path.Join(dir, fmt.Sprintf("#%d.md", number)), []byte(md), 0644); err != nil {
+		ui.Errorf("Error writing pull request file, %s", err)
+		return err
+	}
+	if err := os.WriteFile(path.Join(dir, "pull.json"), indent(raw), 0644); err != nil {
+		ui.Errorf("Error writing pull request file, %s", err)
+		return err
+	}
+	ui.Printf("Success writing pull request #%d to %s", number, dir)
+	return nil
+}
+
+// listAll fetches every page of a REST list endpoint, undecoded.
+func listAll(ctx context.Context, client *gh.Client, u string) ([]json.RawMessage, error) {
+	opt := &gh.ListOptions{PerPage: 100}
+	all := []json.RawMessage{}
+	for {
+		var (
+			page []json.RawMessage
+			resp *gh.Response
+		)
+		err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+			var apiErr error
+			page, resp, apiErr = github.ListRaw(ctx, client, u, opt)
+			return apiErr
+		})
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if resp.NextPage == 0 {
+			return all, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+func writeJSON(p string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p, data, 0644)
+}
+
+func indent(raw json.RawMessage) []byte {
+	data, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return raw
+	}
+	return data
+}
+
+// lastUpdated returns the newest updated_at among the stored pull.json files,
+// or the zero time when nothing was synced yet. It only seeds the sync state
+// of a cache written before the state file existed.
+func lastUpdated(pullDir string) (time.Time, error) {
+	var last time.Time
+	entries, err := os.ReadDir(pullDir)
+	if err != nil {
+		return last, err
+	}
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), "#") {
+			continue
+		}
+		data, err := os.ReadFile(path.Join(pullDir, e.Name(), "pull.json"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return last, err
+		}
+		var pr struct {
+			UpdatedAt time.Time `json:"updated_at"`
+		}
+		if err := json.Unmarshal(data, &pr); err != nil {
+			return last, fmt.Errorf("parse %s/pull.json: %w", e.Name(), err)
+		}
+		if pr.UpdatedAt.After(last) {
+			last = pr.UpdatedAt
+		}
+	}
+	return last, nil
+}
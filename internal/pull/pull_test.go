@@ -0,0 +1,130 @@
+package pull
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wnarutou/gitrieve/internal/archive"
+	"github.com/wnarutou/gitrieve/internal/scm/github/githubtest"
+	"github.com/wnarutou/gitrieve/internal/typedef"
+)
+
+func TestSyncCancelledContextReturnsImmediately(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := Sync(ctx, typedef.Repository{URL: "github.com/test/repo"}, nil)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestSyncArchivesReviewHistoryIncrementally(t *testing.T) {
+	var fetched []string
+	pulls := `[{"number":2,"title":"Feature","state":"open","updated_at":"2026-08-17T02:00:00Z"},
+		{"number":1,"title":"Fix","state":"closed","updated_at":"2026-08-17T01:00:00Z","body":"Run:\n\n` + "```sh\\nmake\\n```" + `"}]`
+	host := githubtest.Serve(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		p := r.URL.Path
+		switch {
+		case p == "/repos/owner/repo/pulls":
+			fmt.Fprint(w, pulls)
+		case strings.HasSuffix(p, "/reviews"):
+			fetched = append(fetched, p)
+			fmt.Fprint(w, `[{"id":7,"state":"APPROVED","body":"LGTM","user":{"login":"bob"}}]`)
+		case strings.HasSuffix(p, "/comments"):
+			fmt.Fprint(w, `[{"id":10,"path":"main.go","line":3,"diff_hunk":"@@ -1 +1 @@","body":"nit"},
+				{"id":11,"in_reply_to_id":10,"path":"main.go","line":3,"body":"fixed"}]`)
+		case strings.HasSuffix(p, "/commits"):
+			fmt.Fprint(w, `[{"sha":"0123456789abcdef","commit":{"message":"fix it\n\nbody","author":{"name":"alice"}}}]`)
+		case p == "/repos/owner/repo/pulls/2" && strings.Contains(r.Header.Get("Accept"), "diff"):
+			w.WriteHeader(http.StatusNotAcceptable)
+			fmt.Fprint(w, `{"message":"Sorry, the diff exceeded the maximum number of files"}`)
+		case strings.HasPrefix(p, "/repos/owner/repo/pulls/"):
+			fmt.Fprint(w, "From 0123456789abcdef\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	repo := typedef.Repository{URL: host + "/owner/repo", UseCache: true}
+	dir := path.Join(".gitrieve", host, "owner", "repo", "pulls")
+
+	require.NoError(t, Sync(context.Background(), repo, nil))
+	assert.Equal(t, []string{"/repos/owner/repo/pulls/1/reviews", "/repos/owner/repo/pulls/2/reviews"}, fetched,
+		"oldest-updated first, so an interrupted sync cannot advance the cursor past skipped PRs")
+	for _, f := range []string{"pull.json", "reviews.json", "review_comments.json", "commits.json", "#1.patch", "#1.diff", "#1.md"} {
+		_, err := os.Stat(path.Join(dir, "#1", f))
+		assert.NoError(t, err, f)
+	}
+	_, err := os.Stat(path.Join(dir, "#2", "#2.diff"))
+	assert.True(t, os.IsNotExist(err), "a diff GitHub refuses to render is skipped, not fatal")
+
+	md, err := os.ReadFile(path.Join(dir, "#1", "#1.md"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(md), "---\nnumber: 1\ntitle: Fix\nstate: closed\n"), "front matter first")
+	assert.Contains(t, string(md), "# PullRequest #1: Fix\n\nRun:\n\n```sh\nmake\n```\n\n", "the body is kept as written")
+	assert.Contains(t, string(md), "### Review #7: APPROVED")
+	assert.Contains(t, string(md), "### main.go:3")
+	assert.Contains(t, string(md), "- 0123456 fix it (alice)")
+	assert.Equal(t, 1, strings.Count(string(md), "### main.go:3"), "a reply joins its thread")
+
+	// Nothing updated since: no PR is fetched again.
+	fetched = nil
+	require.NoError(t, Sync(context.Background(), repo, nil))
+	assert.Empty(t, fetched)
+
+	// PR 1 gets a new review: only it is fetched.
+	pulls = `[{"number":1,"title":"Fix","state":"closed","updated_at":"2026-08-17T03:00:00Z"},
+		{"number":2,"title":"Feature","state":"open","updated_at":"2026-08-17T02:00:00Z"}]`
+	require.NoError(t, Sync(context.Background(), repo, nil))
+	assert.Equal(t, []string{"/repos/owner/repo/pulls/1/reviews"}, fetched)
+}
+
+func TestSyncWithoutCacheResumesFromStoredState(t *testing.T) {
+	var fetched []string
+	pulls := `[{"number":1,"title":"Fix","state":"closed","updated_at":"2026-08-17T01:00:00Z"}]`
+	host := githubtest.Serve(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		p := r.URL.Path
+		switch {
+		case p == "/repos/owner/repo/pulls":
+			fmt.Fprint(w, pulls)
+		case strings.HasSuffix(p, "/reviews"):
+			fetched = append(fetched, p)
+			fmt.Fprint(w, `[]`)
+		case strings.HasSuffix(p, "/comments"), strings.HasSuffix(p, "/commits"):
+			fmt.Fprint(w, `[]`)
+		case strings.HasPrefix(p, "/repos/owner/repo/pulls/"):
+			fmt.Fprint(w, "From 0123456789abcdef\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	store := typedef.MultiStorage{Storage: typedef.Storage{Name: "local", Type: "file", Path: t.TempDir()}}
+	repo := typedef.Repository{URL: host + "/owner/repo"}
+
+	require.NoError(t, Sync(context.Background(), repo, []typedef.MultiStorage{store}))
+	pulls = `[{"number":2,"title":"Feature","state":"open","updated_at":"2026-08-18T01:00:00Z"},` + pulls[1:]
+	require.NoError(t, Sync(context.Background(), repo, []typedef.MultiStorage{store}))
+
+	assert.Equal(t, []string{"/repos/owner/repo/pulls/1/reviews", "/repos/owner/repo/pulls/2/reviews"}, fetched,
+		"the second run resumes from the stored cursor")
+	stored := path.Join(store.Path, host, "owner", "repo")
+	data, err := os.ReadFile(path.Join(stored, "pulls.state.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"cursor": "2026-08-18T01:00:00Z"`)
+
+	// The archive uploaded by the incremental run still holds PR 1.
+	data, err = os.ReadFile(path.Join(stored, "pulls.tar.gz"))
+	require.NoError(t, err)
+	out := t.TempDir()
+	require.NoError(t, archive.Extract(context.Background(), data, "pulls", out))
+	for _, f := range []string{"#1/pull.json", "#2/pull.json"} {
+		assert.FileExists(t, path.Join(out, f))
+	}
+}
@@ -0,0 +1,193 @@
+package release
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/wnarutou/gitrieve/internal/scm"
+	"github.com/wnarutou/gitrieve/internal/scm/github"
+	"github.com/wnarutou/gitrieve/internal/storage"
+	"github.com/wnarutou/gitrieve/internal/typedef"
+	"github.com/wnarutou/gitrieve/internal/ui"
+)
+
+// checksumSuffix names the sidecar that records the SHA-256 of a stored asset
+// next to it, in sha256sum format, e.g. v1/app.tar.gz.sha256.
+const checksumSuffix = ".sha256"
+
+// digest returns the hex SHA-256 GitHub publishes for asset, or "" when it
+// publishes none.
+func digest(asset *github.ReleaseAsset) string {
+	return strings.TrimPrefix(asset.Digest, "sha256:")
+}
+
+// staleAsset returns the storages whose copy of asset, stored as filename, is
+// missing, has the wrong size, or no longer hashes to GitHub's digest or, when
+// GitHub publishes none, to the checksum in its sidecar. A copy stored before
+// checksums were recorded gets its sidecar written.
+func staleAsset(storages []typedef.MultiStorage, r *scm.Repository, filename string, asset *github.ReleaseAsset) ([]typedef.MultiStorage, error) {
+	var stale []typedef.MultiStorage
+	missing, _, err := missingFrom(storages, r, filename, int64(asset.GetSize()))
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range storages {
+		if contains(missing, s) {
+			stale = append(stale, s)
+			continue
+		}
+		sum, recorded, err := storedChecksum(s, r, filename)
+		if err != nil {
+			return nil, err
+		}
+		if want := digest(asset); want != "" && sum != want {
+			ui.Printf("Checksum of stored %s does not match GitHub's digest, downloading it again", filename)
+			stale = append(stale, s)
+		} else if want == "" && recorded != "" && sum != recorded {
+			ui.Printf("Checksum of stored %s does not match its recorded checksum, downloading it again", filename)
+			stale = append(stale, s)
+		}
+	}
+	return stale, nil
+}
+
+func contains(storages []typedef.MultiStorage, s typedef.MultiStorage) bool {
+	for _, m := range storages {
+		if m.Name == s.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// storedChecksum hashes filename as stored in s and returns its SHA-256 and
+// the one its sidecar records. Without a sidecar, recorded is "" and the
+// sidecar is written.
+func storedChecksum(s typedef.MultiStorage, r *scm.Repository, filename string) (sum, recorded string, err error) {
+	backend, err := storage.GetStorage(s)
+	if err != nil {
+		return "", "", err
+	}
+	p, err := releasePath(s, r, filename)
+	if err != nil {
+		return "", "", err
+	}
+	rc, err := backend.OpenObject(p)
+	if err != nil {
+		return "", "", err
+	}
+	defer rc.Close()
+	if sum, _, err = hashReader(rc); err != nil {
+		return "", "", err
+	}
+
+	p, err = releasePath(s, r, filename+checksumSuffix)
+	if err != nil {
+		return "", "", err
+	}
+	obj, err := backend.GetObject(p)
+	if err == nil {
+		recorded, _, _ = strings.Cut(string(obj.Content), " ")
+		return sum, strings.TrimSpace(recorded), nil
+	}
+	if !errors.Is(err, storage.ErrNotExist) {
+		return "", "", err
+	}
+	if err := put([]typedef.MultiStorage{s}, r, filename+checksumSuffix, checksumFile(sum, filename)); err != nil {
+		return "", "", err
+	}
+	return sum, "", nil
+}
+
+// hashReader returns the hex SHA-256 of what src yields and its length,
+// streaming it rather than reading it into memory.
+func hashReader(src io.Reader) (string, int64, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, src)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// escapeGlob quotes the glob metacharacters of a path, e.g. in an asset name.
+func escapeGlob(p string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`).Replace(p)
+}
+
+func checksumFile(sum, filename string) []byte {
+	return []byte(sum + "  " + path.Base(filename) + "\n")
+}
+
+// downloadAsset downloads asset into a partial file under currentDir's
+// working directory, which survives an interrupted sync so the next one
+// resumes it, checks its size and GitHub's digest, and stores it as filename
+// with a checksum sidecar in storages. The asset is hashed as it downloads and
+// streamed from the partial file to storages, never held in memory.
+//
+// The partial file is named after the asset's ID and size, so an asset
+// replaced upstream under the same name is never resumed from the old one's
+// bytes; partial files of earlier versions are removed.
+func downloadAsset(ctx context.Context, c *github.Client, r *scm.Repository, currentDir, filename string, asset *github.ReleaseAsset,
+	storages []typedef.MultiStorage) error {
+	base := path.Join(currentDir, ".gitrieve", r.Host, r.Owner, r.Name, "release", filename)
+	part := fmt.Sprintf("%s.%d-%d.part", base, asset.GetID(), asset.GetSize())
+	if err := os.MkdirAll(path.Dir(part), 0755); err != nil {
+		return err
+	}
+	earlier, err := filepath.Glob(escapeGlob(base) + ".*.part")
+	if err != nil {
+		return err
+	}
+	for _, p := range append(earlier, base+".part") {
+		if p != part {
+			os.Remove(p)
+		}
+	}
+	got, size, err := c.DownloadAsset(ctx, r.Owner, r.Name, asset.GetID(), part)
+	if err != nil {
+		return err
+	}
+	if size != int64(asset.GetSize()) {
+		os.Remove(part)
+		return fmt.Errorf("asset %s: downloaded %d bytes, want %d", filename, size, asset.GetSize())
+	}
+	if want := digest(asset); want != "" && got != want {
+		os.Remove(part)
+		return fmt.Errorf("asset %s: downloaded sha256 %s, GitHub publishes %s", filename, got, want)
+	}
+	f, err := os.Open(part)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	for _, s := range storages {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		backend, err := storage.GetStorage(s)
+		if err != nil {
+			return err
+		}
+		p, err := releasePath(s, r, filename)
+		if err != nil {
+			return err
+		}
+		if err := backend.PutObjectFrom(p, f, size); err != nil {
+			return err
+		}
+	}
+	if err := put(storages, r, filename+checksumSuffix, checksumFile(got, filename)); err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(part)
+}
@@ -2,16 +2,18 @@ package release
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 	"path"
 	"path/filepath"
 	"sort"
+	"time"
 
 	gh "github.com/google/go-github/v56/github"
 	"github.com/wnarutou/gitrieve/internal/config"
 	"github.com/wnarutou/gitrieve/internal/lock"
+	"github.com/wnarutou/gitrieve/internal/render"
 	"github.com/wnarutou/gitrieve/internal/scm"
 	"github.com/wnarutou/gitrieve/internal/scm/github"
 	"github.com/wnarutou/gitrieve/internal/storage"
@@ -25,8 +27,11 @@ type ByPublishedAt []*gh.RepositoryRelease
 // Implement the Len method of sort.Interface interface
 func (r ByPublishedAt) Len() int { return len(r) }
 
-// Implement the Less method of sort.Interface interface
-func (r ByPublishedAt) Less(i, j int) bool { return r[i].PublishedAt.After(r[j].PublishedAt.Time) }
+// Implement the Less method of sort.Interface interface. Drafts, which are not
+// published yet, sort last.
+func (r ByPublishedAt) Less(i, j int) bool {
+	return r[i].GetPublishedAt().After(r[j].GetPublishedAt().Time)
+}
 
 // Implement the Swap method of the sort.Interface interface
 func (r ByPublishedAt) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
@@ -35,21 +40,28 @@ func DownloadAllAssets(ctx context.Context, repo typedef.Repository, storages []
 	if ctx.Err() != nil {
 		return ctx.Err()
 	}
-	releaseNumLimit := config.GetReleaseNumLimit()
-	releaseSizeLimit := config.GetReleaseSizeLimit()
+	policy := repo.Release
+	releaseNumLimit := policy.GetNumLimit(config.GetReleaseNumLimit())
+	releaseSizeLimit := policy.GetSizeLimit(config.GetReleaseSizeLimit())
+	cutoff, err := policy.Cutoff()
+	if err != nil {
+		return err
+	}
 	r, err := scm.NewRepository(repo.URL)
 	if err != nil {
 		return err
 	}
 
+	currentDir, _ := os.Getwd()
+
 	// Serialize concurrent syncs of the same repo's releases: they read-modify-
 	// write the same storage <tag>/<asset> paths and delete stale ones.
-	unlock, err := lock.Acquire(ctx, r, "release")
+	unlock, err := lock.Acquire(ctx, r, "release", currentDir)
 	if err != nil {
 		return err
 	}
 	defer unlock()
-	c, err := github.New()
+	c, err := github.New(r.Host)
 	if err != nil {
 		return err
 	}
@@ -58,6 +70,7 @@ func DownloadAllAssets(ctx context.Context, repo typedef.Repository, storages []
 	if err != nil {
 		return err
 	}
+	releases = selectReleases(releases, policy, cutoff)
 	sort.Sort(ByPublishedAt(releases))
 	if releaseNumLimit >= 0 {
 		if len(releases) < releaseNumLimit {
@@ -86,138 +99,93 @@ func DownloadAllAssets(ctx context.Context, repo typedef.Repository, storages []
 			return err
 		}
 		reserveTagName = append(reserveTagName, release.GetTagName())
+		size, err := storeMetadata(release, r, storages)
+		if err != nil {
+			return err
+		}
+		allReleaseSize += size
 		for _, asset := range assets {
-			if asset.GetState() != "uploaded" {
+			if asset.GetState() != "uploaded" || !policy.WantsAsset(asset.GetName()) {
 				continue
 			}
 			allReleaseSize = allReleaseSize + *asset.Size
 			filename := fmt.Sprintf("%s/%s", release.GetTagName(), asset.GetName())
-			var needDownloadStorage []typedef.MultiStorage
-			for _, s := range storages {
-				backend, err := storage.GetStorage(s)
-				if err != nil {
-					return err
-				}
-				var objectMetaInfo []storage.ObjectMetaInfo
-				if s.Type == storage.FileStorage {
-					if filepath.IsAbs(s.Path) {
-						objectMetaInfo, err = backend.ListObjectMetaInfo(path.Join(s.Path, r.Host, r.Owner, r.Name, "release", filename))
-						if err != nil {
-							needDownloadStorage = append(needDownloadStorage, s)
-							continue
-						}
-					} else {
-						currentDir, err := os.Getwd()
-						if err != nil {
-							return err
-						}
-						objectMetaInfo, err = backend.ListObjectMetaInfo(path.Join(currentDir, s.Path, r.Host, r.Owner, r.Name, "release", filename))
-						if err != nil {
-							needDownloadStorage = append(needDownloadStorage, s)
-							continue
-						}
-					}
-				} else {
-					objectMetaInfo, err = backend.ListObjectMetaInfo(path.Join(s.Path, r.Host, r.Owner, r.Name, "release", filename))
-					if err != nil {
-						needDownloadStorage = append(needDownloadStorage, s)
-						continue
-					}
-				}
-
-				if len(objectMetaInfo) == 0 {
-					needDownloadStorage = append(needDownloadStorage, s)
-					continue
-				}
-				if objectMetaInfo[0].Size != int64(asset.GetSize()) {
-					needDownloadStorage = append(needDownloadStorage, s)
-					continue
-				}
+			needDownloadStorage, err := staleAsset(storages, r, filename, asset)
+			if err != nil {
+				return err
 			}
 			if len(needDownloadStorage) == 0 {
 				continue
 			}
 			// download asset
 			ui.Printf("Downloading %s asset %s", *release.TagName, asset.GetName())
-			rc, err := c.DownloadAsset(ctx, r.Owner, r.Name, asset.GetID())
-			if err != nil {
+			if err := downloadAsset(ctx, c, r, currentDir, filename, asset, needDownloadStorage); err != nil {
 				return err
 			}
-			defer rc.Close()
-			// put rc to file
-			data, err := io.ReadAll(rc)
-			if err != nil {
-				return err
+			if ctx.Err() != nil {
+				return ctx.Err()
 			}
-			for _, s := range needDownloadStorage {
-				backend, err := storage.GetStorage(s)
+		}
+		if repo.DownloadSourceArchives {
+			for _, archive := range []struct{ name, url string }{
+				{"source.zip", release.GetZipballURL()},
+				{"source.tar.gz", release.GetTarballURL()},
+			} {
+				filename := fmt.Sprintf("%s/%s", release.GetTagName(), archive.name)
+				// GitHub does not report the size of source archives, so
+				// any stored copy counts as current.
+				needDownloadStorage, stored, err := missingFrom(storages, r, filename, -1)
 				if err != nil {
 					return err
 				}
-
-				if s.Type == storage.FileStorage {
-					if filepath.IsAbs(s.Path) {
-						err = backend.PutObject(path.Join(s.Path, r.Host, r.Owner, r.Name, "release", filename), data)
-						if err != nil {
-							return err
-						}
-					} else {
-						currentDir, err := os.Getwd()
-						if err != nil {
-							return err
-						}
-						err = backend.PutObject(path.Join(currentDir, s.Path, r.Host, r.Owner, r.Name, "release", filename), data)
-						if err != nil {
-							return err
-						}
-					}
-				} else {
-					err = backend.PutObject(path.Join(s.Path, r.Host, r.Owner, r.Name, "release", filename), data)
-					if err != nil {
-						return err
-					}
+				if len(needDownloadStorage) == 0 {
+					allReleaseSize += int(stored)
+					continue
+				}
+				ui.Printf("Downloading %s source archive %s", release.GetTagName(), archive.name)
+				data, err := c.DownloadArchive(ctx, archive.url)
+				if err != nil {
+					return err
+				}
+				allReleaseSize += len(data)
+				if err := put(needDownloadStorage, r, filename, data); err != nil {
+					return err
+				}
+				if ctx.Err() != nil {
+					return ctx.Err()
 				}
-			}
-			if ctx.Err() != nil {
-				return ctx.Err()
 			}
 		}
 	}
 	if ctx.Err() != nil {
 		return ctx.Err()
 	}
+	// Releases outside the kept window, whether deleted upstream or pushed out
+	// by newer ones, stay where they are, move under _retired/ or are deleted.
+	retention := policy.GetRetention()
+	if retention == typedef.ReleaseRetentionKeep {
+		return nil
+	}
 	for _, s := range storages {
 		backend, err := storage.GetStorage(s)
 		if err != nil {
 			return err
 		}
 
-		var objectMetaInfo []storage.ObjectMetaInfo
-		if s.Type == storage.FileStorage {
-			if filepath.IsAbs(s.Path) {
-				objectMetaInfo, err = backend.ListObjectMetaInfo(path.Join(s.Path, r.Host, r.Owner, r.Name, "release"))
-				if err != nil {
-					continue
-				}
-			} else {
-				currentDir, err := os.Getwd()
-				if err != nil {
-					return err
-				}
-				objectMetaInfo, err = backend.ListObjectMetaInfo(path.Join(currentDir, s.Path, r.Host, r.Owner, r.Name, "release"))
-				if err != nil {
-					continue
-				}
-			}
-		} else {
-			objectMetaInfo, err = backend.ListObjectMetaInfo(path.Join(s.Path, r.Host, r.Owner, r.Name, "release"))
-			if err != nil {
-				continue
-			}
+		releaseDir, err := releasePath(s, r, "")
+		if err != nil {
+			return err
+		}
+		objectMetaInfo, err := backend.ListObjectMetaInfo(releaseDir)
+		if err != nil {
+			continue
 		}
 
 		for _, dirInfo := range objectMetaInfo {
 			dirName := filepath.Base(dirInfo.Path)
+			if dirName == retiredDir {
+				continue
+			}
 			found := false
 			for _, tagName := range reserveTagName {
 				if tagName == dirName {
@@ -225,14 +193,198 @@ func DownloadAllAssets(ctx context.Context, repo typedef.Repository, storages []
 					break
 				}
 			}
-			if !found {
-				err = backend.DeleteObject(dirInfo.Path)
-				if err != nil {
+			if found {
+				continue
+			}
+			if retention == typedef.ReleaseRetentionRetire {
+				if err := retire(backend, releaseDir, dirName); err != nil {
 					return err
 				}
-				ui.Printf("Deleted directory %s", dirInfo.Path)
+				ui.Printf("Retired release %s to %s", dirName, path.Join(releaseDir, retiredDir, dirName))
+				continue
+			}
+			if err := removeDir(backend, dirInfo.Path); err != nil {
+				return err
 			}
+			ui.Printf("Deleted directory %s", dirInfo.Path)
 		}
 	}
 	return nil
 }
+
+// retiredDir is where the retire retention keeps releases that left the
+// kept window, as release/_retired/<tag>/.
+const retiredDir = "_retired"
+
+// retire moves the files of the tag directory under releaseDir to
+// releaseDir/_retired/<tag>/, replacing an earlier retired copy of the same
+// files, and removes the tag directory.
+func retire(backend storage.Storage, releaseDir, tag string) error {
+	files, err := backend.ListObjectMetaInfo(path.Join(releaseDir, tag))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := moveObject(backend, f, path.Join(releaseDir, retiredDir, tag, filepath.Base(f.Path))); err != nil {
+			return err
+		}
+	}
+	return backend.DeleteObject(path.Join(releaseDir, tag))
+}
+
+// moveObject moves f to target, streaming it: release assets can be large.
+func moveObject(backend storage.Storage, f storage.ObjectMetaInfo, target string) error {
+	src, err := backend.OpenObject(f.Path)
+	if err != nil {
+		return err
+	}
+	err = backend.PutObjectFrom(target, src, f.Size)
+	src.Close()
+	if err != nil {
+		return err
+	}
+	return backend.DeleteObject(f.Path)
+}
+
+// removeDir deletes the files of a tag directory and then the directory.
+func removeDir(backend storage.Storage, dir string) error {
+	files, err := backend.ListObjectMetaInfo(dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := backend.DeleteObject(f.Path); err != nil {
+			return err
+		}
+	}
+	return backend.DeleteObject(dir)
+}
+
+// selectReleases drops the releases the policy leaves out: drafts and
+// prereleases when asked to, and those published before cutoff. Drafts are
+// dated by their creation, as they are not published yet.
+func selectReleases(releases []*gh.RepositoryRelease, policy typedef.ReleasePolicy, cutoff time.Time) []*gh.RepositoryRelease {
+	var selected []*gh.RepositoryRelease
+	for _, release := range releases {
+		if policy.SkipDrafts && release.GetDraft() {
+			continue
+		}
+		if policy.SkipPrereleases && release.GetPrerelease() {
+			continue
+		}
+		date := release.GetPublishedAt().Time
+		if release.PublishedAt == nil {
+			date = release.GetCreatedAt().Time
+		}
+		if !cutoff.IsZero() && !date.After(cutoff) {
+			continue
+		}
+		selected = append(selected, release)
+	}
+	return selected
+}
+
+// releasePath returns where name, relative to the repository's release
+// directory, is stored in s.
+func releasePath(s typedef.MultiStorage, r *scm.Repository, name string) (string, error) {
+	return storage.ObjectPath(s, r.Host, r.Owner, r.Name, "release", name)
+}
+
+// missingFrom returns the storages that lack filename or hold it with a size
+// other than size (any size will do when size is negative), along with the
+// size of a stored copy.
+func missingFrom(storages []typedef.MultiStorage, r *scm.Repository, filename string, size int64) ([]typedef.MultiStorage, int64, error) {
+	var missing []typedef.MultiStorage
+	var stored int64
+	for _, s := range storages {
+		backend, err := storage.GetStorage(s)
+		if err != nil {
+			return nil, 0, err
+		}
+		p, err := releasePath(s, r, filename)
+		if err != nil {
+			return nil, 0, err
+		}
+		objectMetaInfo, err := backend.ListObjectMetaInfo(p)
+		if err != nil || len(objectMetaInfo) == 0 {
+			missing = append(missing, s)
+			continue
+		}
+		if size >= 0 && objectMetaInfo[0].Size != size {
+			missing = append(missing, s)
+			continue
+		}
+		stored = objectMetaInfo[0].Size
+	}
+	return missing, stored, nil
+}
+
+// put stores data as filename in every storage of storages.
+func put(storages []typedef.MultiStorage, r *scm.Repository, filename string, data []byte) error {
+	for _, s := range storages {
+		backend, err := storage.GetStorage(s)
+		if err != nil {
+			return err
+		}
+		p, err := releasePath(s, r, filename)
+		if err != nil {
+			return err
+		}
+		if err := backend.PutObject(p, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storeMetadata writes release.json, the release as GitHub reports it, and
+// notes.md, its name and body under YAML front matter, next to the release's
+// assets. They are rewritten on every sync, as releases can be edited. It
+// returns their total size.
+func storeMetadata(release *gh.RepositoryRelease, r *scm.Repository, storages []typedef.MultiStorage) (int, error) {
+	data, err := json.MarshalIndent(release, "", "  ")
+	if err != nil {
+		return 0, fmt.Errorf("encode release %s: %w", release.GetTagName(), err)
+	}
+	notes, err := renderNotes(release)
+	if err != nil {
+		return 0, fmt.Errorf("render release %s notes: %w", release.GetTagName(), err)
+	}
+	if err := put(storages, r, release.GetTagName()+"/release.json", data); err != nil {
+		return 0, err
+	}
+	if err := put(storages, r, release.GetTagName()+"/notes.md", []byte(notes)); err != nil {
+		return 0, err
+	}
+	return len(data) + len(notes), nil
+}
+
+func renderNotes(release *gh.RepositoryRelease) (string, error) {
+	meta := render.Meta{
+		{Key: "tag", Value: release.GetTagName()},
+		{Key: "name", Value: release.GetName()},
+		{Key: "author", Value: release.GetAuthor().GetLogin()},
+		{Key: "draft", Value: release.GetDraft()},
+		{Key: "prerelease", Value: release.GetPrerelease()},
+		{Key: "created_at", Value: release.GetCreatedAt().Time},
+	}
+	if release.PublishedAt != nil {
+		meta = append(meta, render.Field{Key: "published_at", Value: release.GetPublishedAt().Time})
+	}
+	if url := release.GetHTMLURL(); url != "" {
+		meta = append(meta, render.Field{Key: "url", Value: url})
+	}
+	fm, err := render.FrontMatter(meta)
+	if err != nil {
+		return "", err
+	}
+	title := release.GetName()
+	if title == "" {
+		title = release.GetTagName()
+	}
+	notes := fm + "\n# " + title + "\n"
+	if body := render.Body(release.GetBody()); body != "" {
+		notes += "\n" + body + "\n"
+	}
+	return notes, nil
+}
@@ -2,7 +2,11 @@ package release
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"os"
+	"path"
+	"strings"
 	"testing"
 	"time"
 
@@ -10,6 +14,8 @@ import (
 	"github.com/wnarutou/gitrieve/internal/config"
 	"github.com/wnarutou/gitrieve/internal/lock"
 	"github.com/wnarutou/gitrieve/internal/scm"
+	"github.com/wnarutou/gitrieve/internal/scm/github/githubtest"
+	"github.com/wnarutou/gitrieve/internal/storage"
 	"github.com/wnarutou/gitrieve/internal/typedef"
 )
 
@@ -47,3 +53,279 @@ func TestDownloadAllAssetsBlocksWhileLockHeld(t *testing.T) {
 	err = DownloadAllAssets(ctx, repo, nil)
 	require.Equal(t, context.DeadlineExceeded, err, "DownloadAllAssets must block on the held release lock")
 }
+
+func TestDownloadAllAssetsStoresNotesMetadataAndSourceArchives(t *testing.T) {
+	var archives []string
+	var base string
+	host := githubtest.ServeWithConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/owner/repo/releases":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `[{"id":1,"tag_name":"v1","published_at":"2026-08-01T00:00:00Z"},
+				{"id":2,"tag_name":"v2","name":"Second","body":"Fixes:\n\n`+"```"+`\nlog\n`+"```"+`","draft":false,"prerelease":true,
+				"author":{"login":"alice"},"created_at":"2026-08-17T00:00:00Z","published_at":"2026-08-17T01:00:00Z",
+				"zipball_url":"%[1]s/repos/owner/repo/zipball/v2","tarball_url":"%[1]s/repos/owner/repo/tarball/v2"}]`, base)
+		case "/repos/owner/repo/releases/2/assets":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `[]`)
+		case "/repos/owner/repo/zipball/v2", "/repos/owner/repo/tarball/v2":
+			http.Redirect(w, r, "/codeload"+r.URL.Path, http.StatusFound)
+		case "/codeload/repos/owner/repo/zipball/v2", "/codeload/repos/owner/repo/tarball/v2":
+			archives = append(archives, r.URL.Path)
+			fmt.Fprint(w, "archive of "+path.Base(path.Dir(r.URL.Path)))
+		default:
+			http.NotFound(w, r)
+		}
+	}), "releaseSizeLimit: 1\n")
+	base = "http://" + host
+	dir := t.TempDir()
+	storages := []typedef.MultiStorage{{Storage: typedef.Storage{Name: "local", Type: storage.FileStorage, Path: dir}}}
+	repo := typedef.Repository{URL: host + "/owner/repo", DownloadSourceArchives: true}
+	t.Cleanup(func() { os.RemoveAll(".gitrieve") })
+
+	require.NoError(t, DownloadAllAssets(context.Background(), repo, storages))
+	release := path.Join(dir, host, "owner", "repo", "release")
+	notes, err := os.ReadFile(path.Join(release, "v2", "notes.md"))
+	require.NoError(t, err)
+	require.Equal(t, "---\ntag: v2\nname: Second\nauthor: alice\ndraft: false\nprerelease: true\n"+
+		"created_at: 2026-08-17T00:00:00Z\npublished_at: 2026-08-17T01:00:00Z\n---\n\n# Second\n\nFixes:\n\n```\nlog\n```\n", string(notes))
+	data, err := os.ReadFile(path.Join(release, "v2", "release.json"))
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"tag_name": "v2"`)
+	data, err = os.ReadFile(path.Join(release, "v2", "source.zip"))
+	require.NoError(t, err)
+	require.Equal(t, "archive of zipball", string(data))
+	data, err = os.ReadFile(path.Join(release, "v2", "source.tar.gz"))
+	require.NoError(t, err)
+	require.Equal(t, "archive of tarball", string(data))
+	require.NoDirExists(t, path.Join(release, "v1"), "the size limit counts the notes, metadata and archives of v2")
+
+	require.NoError(t, DownloadAllAssets(context.Background(), repo, storages))
+	require.Len(t, archives, 2, "stored source archives are not downloaded again")
+}
+
+func TestDownloadAllAssetsAppliesRepositoryReleasePolicy(t *testing.T) {
+	var downloaded []string
+	host := githubtest.ServeWithConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/owner/repo/releases":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `[{"id":1,"tag_name":"v1","published_at":"2023-12-01T00:00:00Z"},
+				{"id":2,"tag_name":"v2","published_at":"2024-02-01T00:00:00Z"},
+				{"id":3,"tag_name":"v3-rc1","prerelease":true,"published_at":"2024-03-01T00:00:00Z"},
+				{"id":4,"tag_name":"v3","draft":true,"created_at":"2024-04-01T00:00:00Z"},
+				{"id":5,"tag_name":"v2.1","published_at":"2024-02-15T00:00:00Z"}]`)
+		case "/repos/owner/repo/releases/2/assets", "/repos/owner/repo/releases/5/assets":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `[{"id":20,"name":"app.tar.gz","state":"uploaded","size":3},
+				{"id":21,"name":"app-debug.tar.gz","state":"uploaded","size":3},
+				{"id":22,"name":"setup.exe","state":"uploaded","size":3}]`)
+		case "/repos/owner/repo/releases/assets/20":
+			downloaded = append(downloaded, r.URL.Path)
+			w.Header().Set("Content-Type", "application/octet-stream")
+			fmt.Fprint(w, "app")
+		default:
+			http.NotFound(w, r)
+		}
+	}), "releaseNumLimit: 1\nreleaseSizeLimit: -1\n")
+	dir := t.TempDir()
+	storages := []typedef.MultiStorage{{Storage: typedef.Storage{Name: "local", Type: storage.FileStorage, Path: dir}}}
+	repo := typedef.Repository{URL: host + "/owner/repo", Release: typedef.ReleasePolicy{
+		NumLimit:        2,
+		Include:         []string{"*.tar.gz"},
+		Exclude:         []string{"*-debug*"},
+		SkipPrereleases: true,
+		SkipDrafts:      true,
+		NewerThan:       "2024-01-01",
+	}}
+	t.Cleanup(func() { os.RemoveAll(".gitrieve") })
+
+	require.NoError(t, DownloadAllAssets(context.Background(), repo, storages))
+	release := path.Join(dir, host, "owner", "repo", "release")
+	entries, err := os.ReadDir(release)
+	require.NoError(t, err)
+	var tags []string
+	for _, e := range entries {
+		tags = append(tags, e.Name())
+	}
+	require.Equal(t, []string{"v2", "v2.1"}, tags, "the repository's numLimit overrides releaseNumLimit")
+	require.FileExists(t, path.Join(release, "v2.1", "app.tar.gz"))
+	require.NoFileExists(t, path.Join(release, "v2.1", "app-debug.tar.gz"))
+	require.NoFileExists(t, path.Join(release, "v2.1", "setup.exe"))
+	require.Len(t, downloaded, 2)
+}
+
+func TestDownloadAllAssetsRetention(t *testing.T) {
+	for _, tc := range []struct {
+		retention string
+		stale     bool // v0 is still in place
+		retired   bool // v0 is under _retired/
+	}{
+		{"", false, true},
+		{typedef.ReleaseRetentionRetire, false, true},
+		{typedef.ReleaseRetentionKeep, true, false},
+		{typedef.ReleaseRetentionPrune, false, false},
+	} {
+		t.Run(tc.retention, func(t *testing.T) {
+			host := githubtest.ServeWithConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				switch r.URL.Path {
+				case "/repos/owner/repo/releases":
+					fmt.Fprint(w, `[{"id":1,"tag_name":"v1","published_at":"2026-08-01T00:00:00Z"}]`)
+				case "/repos/owner/repo/releases/1/assets":
+					fmt.Fprint(w, `[]`)
+				default:
+					http.NotFound(w, r)
+				}
+			}), "releaseNumLimit: -1\nreleaseSizeLimit: -1\n")
+			dir := t.TempDir()
+			release := path.Join(dir, host, "owner", "repo", "release")
+			require.NoError(t, os.MkdirAll(path.Join(release, "v0"), 0755))
+			require.NoError(t, os.WriteFile(path.Join(release, "v0", "app.zip"), []byte("v0"), 0644))
+			storages := []typedef.MultiStorage{{Storage: typedef.Storage{Name: "local", Type: storage.FileStorage, Path: dir}}}
+			repo := typedef.Repository{URL: host + "/owner/repo", Release: typedef.ReleasePolicy{Retention: tc.retention}}
+			t.Cleanup(func() { os.RemoveAll(".gitrieve") })
+
+			// The second run must leave an already retired copy alone.
+			for i := 0; i < 2; i++ {
+				require.NoError(t, DownloadAllAssets(context.Background(), repo, storages))
+				require.FileExists(t, path.Join(release, "v1", "release.json"))
+				if tc.stale {
+					require.FileExists(t, path.Join(release, "v0", "app.zip"))
+				} else {
+					require.NoDirExists(t, path.Join(release, "v0"))
+				}
+				if tc.retired {
+					data, err := os.ReadFile(path.Join(release, "_retired", "v0", "app.zip"))
+					require.NoError(t, err)
+					require.Equal(t, "v0", string(data))
+				} else {
+					require.NoDirExists(t, path.Join(release, "_retired"))
+				}
+			}
+		})
+	}
+}
+
+// useFakeAssetHost serves release v1 with one asset app.bin holding content,
+// which the API redirects to a storage host that honours Range requests. It
+// records the Range header of every download.
+func useFakeAssetHost(t *testing.T, content, digest string) (host string, ranges *[]string) {
+	ranges = &[]string{}
+	host = githubtest.ServeWithConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/owner/repo/releases":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `[{"id":1,"tag_name":"v1","published_at":"2026-08-01T00:00:00Z"}]`)
+		case "/repos/owner/repo/releases/1/assets":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `[{"id":10,"name":"app.bin","state":"uploaded","size":%d,"digest":%q}]`, len(content), digest)
+		case "/repos/owner/repo/releases/assets/10":
+			http.Redirect(w, r, "/storage/app.bin", http.StatusFound)
+		case "/storage/app.bin":
+			*ranges = append(*ranges, r.Header.Get("Range"))
+			http.ServeContent(w, r, "app.bin", time.Time{}, strings.NewReader(content))
+		default:
+			http.NotFound(w, r)
+		}
+	}), "releaseNumLimit: -1\nreleaseSizeLimit: -1\n")
+	return host, ranges
+}
+
+func TestDownloadAllAssetsResumesPartialDownloadAndRecordsChecksum(t *testing.T) {
+	const content = "0123456789"
+	host, ranges := useFakeAssetHost(t, content, "")
+	dir := t.TempDir()
+	storages := []typedef.MultiStorage{{Storage: typedef.Storage{Name: "local", Type: storage.FileStorage, Path: dir}}}
+	repo := typedef.Repository{URL: host + "/owner/repo"}
+	t.Cleanup(func() { os.RemoveAll(".gitrieve") })
+	part := path.Join(".gitrieve", host, "owner", "repo", "release", "v1", "app.bin.10-10.part")
+	require.NoError(t, os.MkdirAll(path.Dir(part), 0755))
+	require.NoError(t, os.WriteFile(part, []byte(content[:4]), 0644))
+
+	require.NoError(t, DownloadAllAssets(context.Background(), repo, storages))
+	require.Equal(t, []string{"bytes=4-"}, *ranges)
+	release := path.Join(dir, host, "owner", "repo", "release", "v1")
+	data, err := os.ReadFile(path.Join(release, "app.bin"))
+	require.NoError(t, err)
+	require.Equal(t, content, string(data))
+	data, err = os.ReadFile(path.Join(release, "app.bin.sha256"))
+	require.NoError(t, err)
+	require.Equal(t, "84d89877f0d4041efb6bf91a16f0248f2fd573e6af05c19f96bedb9f882f7882  app.bin\n", string(data))
+	require.NoFileExists(t, part)
+
+	require.NoError(t, DownloadAllAssets(context.Background(), repo, storages))
+	require.Len(t, *ranges, 1, "a stored asset is not downloaded again")
+}
+
+func TestDownloadAllAssetsDiscardsPartialDownloadOfReplacedAsset(t *testing.T) {
+	const content = "0123456789"
+	host, ranges := useFakeAssetHost(t, content, "")
+	dir := t.TempDir()
+	storages := []typedef.MultiStorage{{Storage: typedef.Storage{Name: "local", Type: storage.FileStorage, Path: dir}}}
+	t.Cleanup(func() { os.RemoveAll(".gitrieve") })
+	// Left by an asset of the same name that was deleted and uploaded again.
+	stale := path.Join(".gitrieve", host, "owner", "repo", "release", "v1", "app.bin.9-10.part")
+	require.NoError(t, os.MkdirAll(path.Dir(stale), 0755))
+	require.NoError(t, os.WriteFile(stale, []byte("xxxx"), 0644))
+
+	require.NoError(t, DownloadAllAssets(context.Background(), typedef.Repository{URL: host + "/owner/repo"}, storages))
+	require.Equal(t, []string{""}, *ranges, "the old asset's bytes are not resumed")
+	data, err := os.ReadFile(path.Join(dir, host, "owner", "repo", "release", "v1", "app.bin"))
+	require.NoError(t, err)
+	require.Equal(t, content, string(data))
+	require.NoFileExists(t, stale)
+}
+
+func TestDownloadAllAssetsReplacesCorruptedAssetWithoutDigest(t *testing.T) {
+	const content = "0123456789"
+	host, ranges := useFakeAssetHost(t, content, "")
+	dir := t.TempDir()
+	storages := []typedef.MultiStorage{{Storage: typedef.Storage{Name: "local", Type: storage.FileStorage, Path: dir}}}
+	repo := typedef.Repository{URL: host + "/owner/repo"}
+	t.Cleanup(func() { os.RemoveAll(".gitrieve") })
+
+	require.NoError(t, DownloadAllAssets(context.Background(), repo, storages))
+	release := path.Join(dir, host, "owner", "repo", "release", "v1")
+	require.NoError(t, os.WriteFile(path.Join(release, "app.bin"), []byte("01234xxxxx"), 0644))
+
+	require.NoError(t, DownloadAllAssets(context.Background(), repo, storages))
+	require.Len(t, *ranges, 2, "a stored copy that no longer matches its sidecar is downloaded again")
+	data, err := os.ReadFile(path.Join(release, "app.bin"))
+	require.NoError(t, err)
+	require.Equal(t, content, string(data))
+}
+
+func TestDownloadAllAssetsVerifiesGitHubDigest(t *testing.T) {
+	const content = "0123456789"
+	const sum = "84d89877f0d4041efb6bf91a16f0248f2fd573e6af05c19f96bedb9f882f7882"
+	host, ranges := useFakeAssetHost(t, content, "sha256:"+sum)
+	dir := t.TempDir()
+	storages := []typedef.MultiStorage{{Storage: typedef.Storage{Name: "local", Type: storage.FileStorage, Path: dir}}}
+	repo := typedef.Repository{URL: host + "/owner/repo"}
+	t.Cleanup(func() { os.RemoveAll(".gitrieve") })
+	release := path.Join(dir, host, "owner", "repo", "release", "v1")
+	require.NoError(t, os.MkdirAll(release, 0755))
+	require.NoError(t, os.WriteFile(path.Join(release, "app.bin"), []byte("9876543210"), 0644))
+
+	require.NoError(t, DownloadAllAssets(context.Background(), repo, storages))
+	require.Len(t, *ranges, 1, "a stored copy of the right size but the wrong checksum is replaced")
+	data, err := os.ReadFile(path.Join(release, "app.bin"))
+	require.NoError(t, err)
+	require.Equal(t, content, string(data))
+	data, err = os.ReadFile(path.Join(release, "app.bin.sha256"))
+	require.NoError(t, err)
+	require.Equal(t, sum+"  app.bin\n", string(data))
+}
+
+func TestDownloadAllAssetsRejectsDigestMismatch(t *testing.T) {
+	host, _ := useFakeAssetHost(t, "0123456789", "sha256:"+strings.Repeat("0", 64))
+	dir := t.TempDir()
+	storages := []typedef.MultiStorage{{Storage: typedef.Storage{Name: "local", Type: storage.FileStorage, Path: dir}}}
+	t.Cleanup(func() { os.RemoveAll(".gitrieve") })
+
+	err := DownloadAllAssets(context.Background(), typedef.Repository{URL: host + "/owner/repo"}, storages)
+	require.ErrorContains(t, err, "GitHub publishes")
+	require.NoFileExists(t, path.Join(dir, host, "owner", "repo", "release", "v1", "app.bin"))
+	require.NoFileExists(t, path.Join(".gitrieve", host, "owner", "repo", "release", "v1", "app.bin.part"))
+}
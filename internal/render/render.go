@@ -0,0 +1,250 @@
+// Package render turns archived issues, pull requests and discussions into
+// markdown: YAML
+// front matter with the metadata, then the title, bodies and comments. Bodies
+// are embedded verbatim so their formatting survives, and fenced only when
+// they would otherwise break the document. The layout is a Go text/template;
+// the built-in one of each kind can be replaced by a file of the user's.
+package render
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The kinds of documents, each with a built-in layout templates/<kind>.tmpl.
+const (
+	Issue      = "issue"
+	Pull       = "pull"
+	Discussion = "discussion"
+)
+
+// TimeLayout is how the templates print times outside the front matter.
+const TimeLayout = "2006-01-02 15:04:05"
+
+// templatesFS holds the built-in layouts and common.tmpl, the named
+// templates ("comment", "edits") every layout, built-in or not, can call.
+//
+//go:embed templates/*.tmpl
+var templatesFS embed.FS
+
+// Field is one front matter entry. Meta keeps its fields in order, so the
+// front matter reads the same on every render.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Meta is an ordered YAML mapping.
+type Meta []Field
+
+// MarshalYAML encodes m as a mapping in field order.
+func (m Meta) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, f := range m {
+		key, value := &yaml.Node{}, &yaml.Node{}
+		if err := key.Encode(f.Key); err != nil {
+			return nil, err
+		}
+		if err := value.Encode(f.Value); err != nil {
+			return nil, fmt.Errorf("encode %s: %w", f.Key, err)
+		}
+		node.Content = append(node.Content, key, value)
+	}
+	return node, nil
+}
+
+// Document is what a layout renders: one issue, pull request or discussion.
+type Document struct {
+	// Kind is "Issue", "PullRequest" or "Discussion".
+	Kind   string
+	Number int
+	Title  string
+	// Meta becomes the front matter.
+	Meta Meta
+	// Notice is shown as a quote under the title, e.g. a deletion banner.
+	Notice   string
+	Body     string
+	Edits    []Edit
+	Poll     *Poll
+	Comments []Comment
+	// Timeline holds one preformatted line per event.
+	Timeline []string
+	// Commits, Reviews and Threads are the code review of a pull request.
+	Commits []Commit
+	Reviews []Review
+	Threads []Thread
+}
+
+// Comment is a comment, or with Kind "Reply" a reply to one.
+type Comment struct {
+	Kind      string
+	ID        int64
+	Author    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	// Notice is appended to the heading, e.g. "deleted upstream since ...".
+	Notice    string
+	Answer    bool
+	Reactions Meta
+	Edits     []Edit
+	Replies   []Comment
+}
+
+// Commit is one commit of a pull request.
+type Commit struct {
+	SHA    string // abbreviated
+	Title  string // first line of the message
+	Author string
+}
+
+// Review is one review verdict on a pull request.
+type Review struct {
+	ID          int64
+	State       string
+	Author      string
+	SubmittedAt time.Time
+	Body        string
+}
+
+// Thread is the review comments on one line of a pull request's diff, the
+// first one and its replies.
+type Thread struct {
+	Path     string
+	Line     int
+	DiffHunk string
+	Comments []Comment
+}
+
+// Edit is one revision of a body; Body is the text as it was before it.
+type Edit struct {
+	EditedAt time.Time
+	Editor   string
+	Body     string
+}
+
+// Poll is a discussion poll with the votes per option.
+type Poll struct {
+	Question string
+	Total    int
+	Options  []PollOption
+}
+
+// PollOption is one answer of a Poll.
+type PollOption struct {
+	Option string
+	Votes  int
+}
+
+var funcs = template.FuncMap{
+	"frontMatter": FrontMatter,
+	"body":        Body,
+	"fence":       Fence,
+	"fenceInfo":   FenceInfo,
+	"reactions":   Reactions,
+	"time":        func(t time.Time) string { return t.Format(TimeLayout) },
+}
+
+// Parse returns the layout of kind: the file at path, or the built-in one when
+// path is empty.
+func Parse(kind, path string) (*template.Template, error) {
+	tmpl, err := template.New("common.tmpl").Funcs(funcs).ParseFS(templatesFS, "templates/common.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return tmpl.ParseFS(templatesFS, "templates/"+kind+".tmpl")
+	}
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return tmpl.New(kind + ".tmpl").Parse(string(text))
+}
+
+// Render executes the layout tmpl (from Parse) on doc.
+func Render(tmpl *template.Template, kind string, doc Document) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, kind+".tmpl", doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// FrontMatter encodes meta as a YAML front matter block.
+func FrontMatter(meta Meta) (string, error) {
+	data, err := yaml.Marshal(meta)
+	if err != nil {
+		return "", err
+	}
+	return "---\n" + string(data) + "---\n", nil
+}
+
+var fenceLine = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})(.*)$")
+
+// Body returns a markdown body as it is, so it renders as written, unless it
+// leaves a code fence open, which would swallow the rest of the document:
+// then it is fenced as a whole.
+func Body(s string) string {
+	s = strings.TrimRight(s, "\r\n")
+	var open string
+	for _, line := range strings.Split(s, "\n") {
+		m := fenceLine.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+		switch {
+		case open == "":
+			// A backtick fence's info string cannot contain backticks.
+			if m[1][0] == '`' && strings.Contains(m[2], "`") {
+				continue
+			}
+			open = m[1]
+		case m[1][0] == open[0] && len(m[1]) >= len(open) && strings.TrimSpace(m[2]) == "":
+			open = ""
+		}
+	}
+	if open != "" {
+		return Fence(s)
+	}
+	return s
+}
+
+var backticks = regexp.MustCompile("`+")
+
+// Fence wraps s in a backtick fence longer than any backtick run in it, so
+// nothing inside can close it.
+func Fence(s string) string {
+	return FenceInfo("", s)
+}
+
+// FenceInfo is Fence with an info string, e.g. the language of s.
+func FenceInfo(info, s string) string {
+	n := 3
+	for _, run := range backticks.FindAllString(s, -1) {
+		if len(run) >= n {
+			n = len(run) + 1
+		}
+	}
+	fence := strings.Repeat("`", n)
+	return fence + info + "\n" + strings.TrimRight(s, "\r\n") + "\n" + fence
+}
+
+// Reactions formats reaction counts as "+1 2, heart 1", skipping zeros.
+func Reactions(meta Meta) string {
+	var parts []string
+	for _, f := range meta {
+		if n, ok := f.Value.(int); ok && n > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", f.Key, n))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,233 @@
+package render
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyKeepsMarkdownVerbatim(t *testing.T) {
+	body := "Steps:\n\n1. run\n\n```go\nfmt.Println(\"hi\")\n```\n\n~~~\nlog\n~~~\n"
+	require.Equal(t, "Steps:\n\n1. run\n\n```go\nfmt.Println(\"hi\")\n```\n\n~~~\nlog\n~~~", Body(body))
+}
+
+func TestBodyFencesAnUnclosedFence(t *testing.T) {
+	body := "trace:\n````\npanic: boom\n```\n"
+	require.Equal(t, "`````\ntrace:\n````\npanic: boom\n```\n`````", Body(body))
+}
+
+func TestFenceOutlastsBacktickRuns(t *testing.T) {
+	require.Equal(t, "```\nplain\n```", Fence("plain"))
+	require.Equal(t, "````\na ``` b\n````", Fence("a ``` b"))
+}
+
+func TestFrontMatterKeepsFieldOrder(t *testing.T) {
+	fm, err := FrontMatter(Meta{
+		{"number", 7},
+		{"title", "Bug: crash"},
+		{"labels", []string{"bug", "p1"}},
+		{"created_at", time.Date(2026, 8, 17, 1, 0, 0, 0, time.UTC)},
+		{"reactions", Meta{{"+1", 2}}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "---\nnumber: 7\ntitle: 'Bug: crash'\nlabels:\n    - bug\n    - p1\ncreated_at: 2026-08-17T01:00:00Z\nreactions:\n    \"+1\": 2\n---\n", fm)
+}
+
+func TestRenderBuiltInIssueLayout(t *testing.T) {
+	tmpl, err := Parse(Issue, "")
+	require.NoError(t, err)
+	at := time.Date(2026, 8, 17, 1, 0, 0, 0, time.UTC)
+	out, err := Render(tmpl, Issue, Document{
+		Kind:   "Issue",
+		Number: 1,
+		Title:  "Bug",
+		Meta:   Meta{{"number", 1}},
+		Notice: "Deleted upstream",
+		Body:   "It **breaks**.",
+		Comments: []Comment{
+			{Kind: "Comment", ID: 10, Author: "bob", CreatedAt: at, UpdatedAt: at, Body: "same", Reactions: Meta{{"+1", 1}, {"-1", 0}}},
+		},
+		Timeline: []string{"2026-08-17 01:00:00 bob closed"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, `---
+number: 1
+---
+
+# Issue #1: Bug
+
+> Deleted upstream
+
+It **breaks**.
+
+## Comments
+
+### Comment #10
+
+same
+
+- Author: bob
+- Created Time: 2026-08-17 01:00:00
+- Updated Time: 2026-08-17 01:00:00
+- Reactions: +1 1
+
+---
+
+## Timeline
+
+- 2026-08-17 01:00:00 bob closed
+`, out)
+}
+
+func TestRenderBuiltInDiscussionLayout(t *testing.T) {
+	tmpl, err := Parse(Discussion, "")
+	require.NoError(t, err)
+	at := time.Date(2026, 8, 17, 1, 0, 0, 0, time.UTC)
+	out, err := Render(tmpl, Discussion, Document{
+		Kind:   "Discussion",
+		Number: 2,
+		Title:  "Which?",
+		Meta:   Meta{{"number", 2}},
+		Body:   "Pick one",
+		Edits:  []Edit{{EditedAt: at, Editor: "alice", Body: "Pick"}},
+		Poll:   &Poll{Question: "Which?", Total: 1, Options: []PollOption{{"a", 1}, {"b", 0}}},
+		Comments: []Comment{{
+			Kind: "Comment", ID: 10, Author: "bob", CreatedAt: at, UpdatedAt: at, Body: "a", Answer: true,
+			Replies: []Comment{{Kind: "Reply", ID: 11, Author: "alice", CreatedAt: at, UpdatedAt: at, Body: "thanks"}},
+		}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, `---
+number: 2
+---
+
+# Discussion #2: Which?
+
+Pick one
+
+**Edit History**
+
+- 2026-08-17 01:00:00 alice
+
+`+"```\nPick\n```"+`
+
+## Poll
+
+Which?
+
+- a: 1
+- b: 0
+- Total Votes: 1
+
+## Comments
+
+### Comment #10
+
+a
+
+- Author: bob
+- Created Time: 2026-08-17 01:00:00
+- Updated Time: 2026-08-17 01:00:00
+- Answer: yes
+
+---
+
+#### Reply #11
+
+thanks
+
+- Author: alice
+- Created Time: 2026-08-17 01:00:00
+- Updated Time: 2026-08-17 01:00:00
+
+---
+
+`, out)
+}
+
+func TestRenderBuiltInPullLayout(t *testing.T) {
+	tmpl, err := Parse(Pull, "")
+	require.NoError(t, err)
+	at := time.Date(2026, 8, 17, 1, 0, 0, 0, time.UTC)
+	out, err := Render(tmpl, Pull, Document{
+		Kind:    "PullRequest",
+		Number:  3,
+		Title:   "Fix",
+		Meta:    Meta{{"number", 3}},
+		Body:    "Run:\n\n```sh\nmake\n```",
+		Commits: []Commit{{SHA: "0123456", Title: "fix it", Author: "alice"}},
+		Reviews: []Review{{ID: 7, State: "APPROVED", Author: "bob", SubmittedAt: at, Body: "LGTM"}},
+		Threads: []Thread{{Path: "main.go", Line: 3, DiffHunk: "@@ -1 +1 @@", Comments: []Comment{
+			{Kind: "Comment", ID: 10, Author: "bob", CreatedAt: at, UpdatedAt: at, Body: "nit"},
+			{Kind: "Comment", ID: 11, Author: "alice", CreatedAt: at, UpdatedAt: at, Body: "fixed"},
+		}}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, `---
+number: 3
+---
+
+# PullRequest #3: Fix
+
+Run:
+
+`+"```sh\nmake\n```"+`
+
+## Commits
+
+- 0123456 fix it (alice)
+
+## Reviews
+
+### Review #7: APPROVED
+
+- Author: bob
+- Submitted Time: 2026-08-17 01:00:00
+
+LGTM
+
+---
+
+## Review Comments
+
+### main.go:3
+
+`+"```diff\n@@ -1 +1 @@\n```"+`
+
+#### Comment #10
+
+nit
+
+- Author: bob
+- Created Time: 2026-08-17 01:00:00
+- Updated Time: 2026-08-17 01:00:00
+
+#### Comment #11
+
+fixed
+
+- Author: alice
+- Created Time: 2026-08-17 01:00:00
+- Updated Time: 2026-08-17 01:00:00
+
+---
+
+`, out)
+}
+
+func TestParseUserLayout(t *testing.T) {
+	file := path.Join(t.TempDir(), "issue.tmpl")
+	require.NoError(t, os.WriteFile(file, []byte(`{{.Title}}{{range .Comments}}|{{template "comment" .}}{{end}}`), 0644))
+	tmpl, err := Parse(Issue, file)
+	require.NoError(t, err)
+	out, err := Render(tmpl, Issue, Document{Title: "T", Comments: []Comment{{Kind: "Comment", ID: 3}}})
+	require.NoError(t, err)
+	require.Contains(t, out, "T|### Comment #3\n")
+
+	require.NoError(t, os.WriteFile(file, []byte(`{{.Title`), 0644))
+	_, err = Parse(Issue, file)
+	require.Error(t, err)
+}
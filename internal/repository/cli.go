@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/wnarutou/gitrieve/internal/scm"
+	"github.com/wnarutou/gitrieve/internal/typedef"
+	"github.com/wnarutou/gitrieve/internal/ui"
+)
+
+// gitBinary is the executable the CLI backend runs. A package-level seam so
+// tests can point it at a stub.
+var gitBinary = "git"
+
+// runGit runs one git command in dir and returns its stdout. stderr, when
+// non-nil, additionally receives git's stderr so --progress output streams into
+// the log sink. ctx bounds the process: a cancellation kills git and runGit
+// returns ctx.Err() rather than the "signal: killed" exit error.
+func runGit(ctx context.Context, dir string, stderr io.Writer, args ...string) ([]byte, error) {
+	return runGitAuth(ctx, dir, gitAuth{}, stderr, args...)
+}
+
+// runGitAuth is runGit for commands that talk to the remote with auth.
+func runGitAuth(ctx context.Context, dir string, auth gitAuth, stderr io.Writer, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, gitBinary, args...)
+	cmd.Dir = dir
+	// Never block on a credential prompt: a daemon has no terminal to answer
+	// it, so an auth failure must fail the command instead of hanging it.
+	cmd.Env = append(append(os.Environ(), "GIT_TERMINAL_PROMPT=0"), auth.env()...)
+	var stdout, errBuf bytes.Buffer
+	cmd.Stdout = &stdout
+	if stderr != nil {
+		cmd.Stderr = io.MultiWriter(stderr, &errBuf)
+	} else {
+		cmd.Stderr = &errBuf
+	}
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(errBuf.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// syncMirror keeps gitDir as a bare mirror of remoteURL using the system git:
+// the first run does a `clone --mirror`, later runs `fetch` into it. It reports
+// whether any ref changed.
+//
+// The mirror fetches every ref (+refs/*:refs/*), so allBranches has no effect
+// on this backend. Fetch never prunes, so refs deleted upstream stay in the
+// mirror (deletion-safe, matching the go-git backend).
+//
+// reference, when set, is a fork network's shared object store: the mirror
+// borrows objects from it through git alternates instead of keeping its own.
+func syncMirror(ctx context.Context, remoteURL string, auth gitAuth, gitDir string, depth int, reference string, progress io.Writer) (bool, error) {
+	if _, err := os.Stat(path.Join(gitDir, "HEAD")); err != nil {
+		args := []string{"clone", "--mirror", "--progress"}
+		if depth > 0 {
+			args = append(args, "--depth", strconv.Itoa(depth))
+		}
+		if reference != "" {
+			args = append(args, "--reference", reference)
+		}
+		args = append(args, remoteURL, gitDir)
+		if _, err := runGitAuth(ctx, "", auth, progress, args...); err != nil {
+			// Remove the partial mirror so the next sync retries cleanly; it
+			// holds no previously-fetched data.
+			os.RemoveAll(gitDir)
+			return false, err
+		}
+		return true, nil
+	}
+
+	if reference != "" {
+		// A mirror cloned before the repo joined a fork network starts
+		// borrowing now; its existing objects stay where they are.
+		if err := linkAlternates(gitDir, reference); err != nil {
+			return false, err
+		}
+	}
+	before, err := listRefs(ctx, gitDir)
+	if err != nil {
+		return false, err
+	}
+	args := []string{"fetch", "--progress", "origin"}
+	if depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(depth))
+	}
+	if _, err := runGitAuth(ctx, gitDir, auth, progress, args...); err != nil {
+		return false, err
+	}
+	after, err := listRefs(ctx, gitDir)
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(before, after), nil
+}
+
+// listRefs returns every ref of the repository at gitDir with its object id,
+// one per line in refname order, for before/after comparison.
+func listRefs(ctx context.Context, gitDir string) ([]byte, error) {
+	return runGit(ctx, gitDir, nil, "for-each-ref", "--format=%(objectname) %(refname)")
+}
+
+// createBundle packs every ref of the mirror at gitDir into a single git
+// bundle, which can be restored with `git clone <file>`.
+func createBundle(ctx context.Context, gitDir string) ([]byte, error) {
+	return runGit(ctx, gitDir, nil, "bundle", "create", "-", "--all")
+}
+
+// syncCLI is the `gitBackend: cli` counterpart of the go-git path in Sync. It
+// runs with the repo+component lock already held and under Sync's bounded
+// context, and stores a <targetName>.bundle instead of a working-tree tarball.
+// store is the fork network's shared object store, or "" when not sharing.
+func syncCLI(ctx context.Context, r *scm.Repository, remoteURL string, auth gitAuth, gitDir, targetName string, repo typedef.Repository, store string, progress io.Writer, storages []typedef.MultiStorage) error {
+	updated, err := syncMirror(ctx, remoteURL, auth, gitDir, repo.Depth, store, progress)
+	if err != nil {
+		ui.Errorf("Error syncing mirror, %s", err)
+		return err
+	}
+
+	if updated {
+		data, err := createBundle(ctx, gitDir)
+		if err != nil {
+			ui.Errorf("Error creating bundle, %s", err)
+			return err
+		}
+		if err := storeObject(storages, r, targetName+".bundle", data); err != nil {
+			return err
+		}
+	} else {
+		ui.Printf("All is uptodate, no need to restore")
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	// cleanup
+	if !repo.UseCache {
+		if err := os.RemoveAll(gitDir); err != nil {
+			ui.Errorf("Error cleaning up working directory, %s", err)
+			return err
+		}
+	}
+	return nil
+}
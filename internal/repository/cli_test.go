@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSourceRepo creates a non-bare repository with one commit and returns its
+// path together with a helper that adds another commit.
+func newSourceRepo(t *testing.T) (string, func()) {
+	t.Helper()
+	if _, err := exec.LookPath(gitBinary); err != nil {
+		t.Skip("git binary not available")
+	}
+	dir := t.TempDir()
+	commit := func() {
+		_, err := runGit(context.Background(), dir, nil,
+			"-c", "user.name=test", "-c", "user.email=test@example.com",
+			"commit", "--allow-empty", "-q", "-m", "commit")
+		require.NoError(t, err)
+	}
+	_, err := runGit(context.Background(), dir, nil, "init", "-q")
+	require.NoError(t, err)
+	commit()
+	return dir, commit
+}
+
+func TestSyncMirrorDetectsChanges(t *testing.T) {
+	src, commit := newSourceRepo(t)
+	mirror := filepath.Join(t.TempDir(), "code.git")
+	ctx := context.Background()
+
+	updated, err := syncMirror(ctx, "file://"+src, gitAuth{}, mirror, 0, "", &progressWriter{})
+	require.NoError(t, err)
+	assert.True(t, updated, "the initial clone is an update")
+
+	updated, err = syncMirror(ctx, "file://"+src, gitAuth{}, mirror, 0, "", &progressWriter{})
+	require.NoError(t, err)
+	assert.False(t, updated, "a fetch with no new refs is not an update")
+
+	commit()
+	updated, err = syncMirror(ctx, "file://"+src, gitAuth{}, mirror, 0, "", &progressWriter{})
+	require.NoError(t, err)
+	assert.True(t, updated, "a new upstream commit is an update")
+
+	bundle, err := createBundle(ctx, mirror)
+	require.NoError(t, err)
+	bundlePath := filepath.Join(t.TempDir(), "repo.bundle")
+	require.NoError(t, os.WriteFile(bundlePath, bundle, 0o644))
+	_, err = runGit(ctx, mirror, nil, "bundle", "verify", bundlePath)
+	require.NoError(t, err, "the stored bundle must be restorable")
+}
+
+func TestSyncMirrorCancelledCloneCleansUp(t *testing.T) {
+	src, _ := newSourceRepo(t)
+	mirror := filepath.Join(t.TempDir(), "code.git")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := syncMirror(ctx, "file://"+src, gitAuth{}, mirror, 0, "", nil)
+	require.ErrorIs(t, err, context.Canceled)
+	_, statErr := os.Stat(mirror)
+	assert.True(t, os.IsNotExist(statErr), "a cancelled clone must not leave a partial mirror behind")
+}
@@ -20,7 +20,9 @@ func (f *fakeRepoLister) GetRepos(name, accountType string) ([]string, error) {
 func TestExpand(t *testing.T) {
 	old := newGithubClient
 	t.Cleanup(func() { newGithubClient = old })
-	newGithubClient = func() (repoLister, error) {
+	var gotHost string
+	newGithubClient = func(host string) (repoLister, error) {
+		gotHost = host
 		return &fakeRepoLister{repos: []string{"github.com/acme/alpha", "github.com/acme/beta"}}, nil
 	}
 
@@ -45,6 +47,13 @@ func TestExpand(t *testing.T) {
 		assert.Equal(t, "0 2 * * *", got[0].Cron) // 继承父条目配置
 		assert.True(t, got[0].AllBranches)
 		assert.Equal(t, "beta", got[1].Name)
+		assert.Equal(t, "github.com", gotHost)
+	})
+
+	t.Run("enterprise org lists repos on its own host", func(t *testing.T) {
+		org := typedef.Repository{Name: "corp", Type: typedef.TypeOrg, OrgName: "corp", Host: "github.corp.example"}
+		require.Len(t, Expand(org), 2)
+		assert.Equal(t, "github.corp.example", gotHost)
 	})
 
 	t.Run("invalid type yields nothing", func(t *testing.T) {
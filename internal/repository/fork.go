@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/wnarutou/gitrieve/internal/lock"
+	"github.com/wnarutou/gitrieve/internal/scm"
+	"github.com/wnarutou/gitrieve/internal/scm/github"
+	"github.com/wnarutou/gitrieve/internal/typedef"
+	"github.com/wnarutou/gitrieve/internal/ui"
+)
+
+// forkSource looks up the root of a repository's fork network. A package-level
+// seam so tests can avoid the GitHub API.
+var forkSource = func(ctx context.Context, host, owner, name string) (string, error) {
+	c, err := github.New(host)
+	if err != nil {
+		return "", err
+	}
+	return c.GetForkSource(ctx, owner, name)
+}
+
+// forkNetworkRoot returns the root of the fork network whose shared object
+// store repo borrows from, or nil when repo does not share objects. An explicit
+// forkOf wins; otherwise detectForks asks the GitHub API, and a repository that
+// is not a fork roots its own network so its forks can borrow from it.
+func forkNetworkRoot(ctx context.Context, repo typedef.Repository, r *scm.Repository) (*scm.Repository, error) {
+	if repo.ForkOf != "" {
+		return scm.NewRepository(typedef.NormalizeURL(repo.ForkOf))
+	}
+	if !repo.DetectForks {
+		return nil, nil
+	}
+	source, err := forkSource(ctx, r.Host, r.Owner, r.Name)
+	if err != nil {
+		return nil, err
+	}
+	if source == "" {
+		return scm.NewRepository(typedef.NormalizeURL(repo.URL))
+	}
+	return scm.NewRepository(typedef.NormalizeURL(source))
+}
+
+// networkDir returns the shared object store of the fork network rooted at
+// root. It lives under .gitrieve/networks, outside any one repository's cache
+// directory, so no member's cleanup can remove it.
+func networkDir(baseDir string, root *scm.Repository) string {
+	return filepath.Join(baseDir, ".gitrieve", "networks", root.Host, root.Owner, root.Name+".git")
+}
+
+// fetchIntoNetwork fetches every ref of r into the shared object store of the
+// fork network rooted at root and returns the store's path. Refs are namespaced
+// under refs/forks/<owner>/<name>/ so members never overwrite each other, and
+// the store is never pruned: members borrow its objects through git alternates,
+// so an object must outlive even an upstream force-push that orphans it.
+//
+// The store has its own lock, taken only for this fetch. Callers already hold
+// r's own (repo, component) lock, and the two are always taken in that order.
+func fetchIntoNetwork(ctx context.Context, baseDir string, root, r *scm.Repository, remoteURL string, auth gitAuth, progress io.Writer) (string, error) {
+	unlock, err := lock.Acquire(ctx, root, "network", baseDir)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	store := networkDir(baseDir, root)
+	if _, err := os.Stat(filepath.Join(store, "HEAD")); err != nil {
+		if _, err := runGit(ctx, "", nil, "init", "--bare", "--quiet", store); err != nil {
+			return "", err
+		}
+		for _, kv := range [][2]string{{"gc.auto", "0"}, {"gc.pruneExpire", "never"}} {
+			if _, err := runGit(ctx, store, nil, "config", kv[0], kv[1]); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	refspec := fmt.Sprintf("+refs/*:refs/forks/%s/%s/*", r.Owner, r.Name)
+	if _, err := runGitAuth(ctx, store, auth, progress, "fetch", "--progress", "--no-tags", remoteURL, refspec); err != nil {
+		return "", err
+	}
+	return store, nil
+}
+
+// linkAlternates makes the repository at gitDir borrow objects from store by
+// listing store's object directory in objects/info/alternates. Bundles created
+// from gitDir still contain every object, so stored archives stay
+// self-contained.
+func linkAlternates(gitDir, store string) error {
+	objects, err := filepath.Abs(filepath.Join(store, "objects"))
+	if err != nil {
+		return err
+	}
+	p := filepath.Join(gitDir, "objects", "info", "alternates")
+	if data, err := os.ReadFile(p); err == nil && string(data) == objects+"\n" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(p, []byte(objects+"\n"), 0o644)
+}
+
+// shareForkObjects fetches the code of a repo that belongs to a fork network
+// into the network's shared object store and returns the store for the mirror
+// to borrow from. It returns "" when the repo does not share objects: no fork
+// network, the wiki component, a shallow sync (alternates and shallow clones
+// do not mix) or useCache off (the store is a cache in its own right).
+func shareForkObjects(ctx context.Context, repo typedef.Repository, r *scm.Repository, iswiki bool, baseDir, remoteURL string, auth gitAuth, progress io.Writer) (string, error) {
+	if iswiki || (repo.ForkOf == "" && !repo.DetectForks) {
+		return "", nil
+	}
+	if !repo.UseCache || repo.Depth > 0 {
+		ui.Printf("Fork object sharing needs useCache and depth 0, syncing %s without it", repo.Name)
+		return "", nil
+	}
+	root, err := forkNetworkRoot(ctx, repo, r)
+	if err != nil {
+		ui.Errorf("Error resolving fork network of %s, %s", repo.Name, err)
+		return "", err
+	}
+	ui.Printf("Sharing objects of %s with fork network %s/%s/%s", repo.Name, root.Host, root.Owner, root.Name)
+	store, err := fetchIntoNetwork(ctx, baseDir, root, r, remoteURL, auth, progress)
+	if err != nil {
+		ui.Errorf("Error fetching into fork network store, %s", err)
+		return "", err
+	}
+	return store, nil
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wnarutou/gitrieve/internal/scm"
+	"github.com/wnarutou/gitrieve/internal/typedef"
+)
+
+func TestForkNetworkRoot(t *testing.T) {
+	old := forkSource
+	t.Cleanup(func() { forkSource = old })
+	forkSource = func(ctx context.Context, host, owner, name string) (string, error) {
+		if owner == "fork" {
+			return "github.com/Upstream/Repo", nil
+		}
+		return "", nil
+	}
+	ctx := context.Background()
+	r := &scm.Repository{Host: "github.com", Owner: "fork", Name: "repo"}
+
+	root, err := forkNetworkRoot(ctx, typedef.Repository{URL: "github.com/fork/repo"}, r)
+	require.NoError(t, err)
+	assert.Nil(t, root, "no hint and no detection means no sharing")
+
+	root, err = forkNetworkRoot(ctx, typedef.Repository{URL: "github.com/fork/repo", ForkOf: "https://github.com/hint/repo.git"}, r)
+	require.NoError(t, err)
+	assert.Equal(t, &scm.Repository{Host: "github.com", Owner: "hint", Name: "repo"}, root, "forkOf wins over detection")
+
+	root, err = forkNetworkRoot(ctx, typedef.Repository{URL: "github.com/fork/repo", DetectForks: true}, r)
+	require.NoError(t, err)
+	assert.Equal(t, &scm.Repository{Host: "github.com", Owner: "upstream", Name: "repo"}, root)
+
+	up := &scm.Repository{Host: "github.com", Owner: "upstream", Name: "repo"}
+	root, err = forkNetworkRoot(ctx, typedef.Repository{URL: "github.com/upstream/repo", DetectForks: true}, up)
+	require.NoError(t, err)
+	assert.Equal(t, up, root, "a repository that is not a fork roots its own network")
+}
+
+func TestForkMirrorsShareObjectStore(t *testing.T) {
+	upstream, _ := newSourceRepo(t)
+	fork := filepath.Join(t.TempDir(), "fork")
+	ctx := context.Background()
+	_, err := runGit(ctx, "", nil, "clone", "-q", upstream, fork)
+	require.NoError(t, err)
+	_, err = runGit(ctx, fork, nil,
+		"-c", "user.name=test", "-c", "user.email=test@example.com",
+		"commit", "--allow-empty", "-q", "-m", "fork commit")
+	require.NoError(t, err)
+
+	base := t.TempDir()
+	root := &scm.Repository{Host: "github.com", Owner: "upstream", Name: "repo"}
+	upRepo := root
+	forkRepo := &scm.Repository{Host: "github.com", Owner: "fork", Name: "repo"}
+
+	store, err := fetchIntoNetwork(ctx, base, root, upRepo, "file://"+upstream, gitAuth{}, nil)
+	require.NoError(t, err)
+	_, err = syncMirror(ctx, "file://"+upstream, gitAuth{}, filepath.Join(base, "up.git"), 0, store, nil)
+	require.NoError(t, err)
+
+	store2, err := fetchIntoNetwork(ctx, base, root, forkRepo, "file://"+fork, gitAuth{}, nil)
+	require.NoError(t, err)
+	require.Equal(t, store, store2, "every member of a network uses the same store")
+	forkMirror := filepath.Join(base, "fork.git")
+	_, err = syncMirror(ctx, "file://"+fork, gitAuth{}, forkMirror, 0, store, nil)
+	require.NoError(t, err)
+
+	// Both members' refs live side by side in the store.
+	refs, err := listRefs(ctx, store)
+	require.NoError(t, err)
+	assert.Contains(t, string(refs), "refs/forks/upstream/repo/heads/")
+	assert.Contains(t, string(refs), "refs/forks/fork/repo/heads/")
+
+	// The fork's mirror borrows from the store instead of copying objects.
+	alternates, err := os.ReadFile(filepath.Join(forkMirror, "objects", "info", "alternates"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(store, "objects"), strings.TrimSpace(string(alternates)))
+
+	// The stored bundle is still self-contained: it restores without the store.
+	bundle, err := createBundle(ctx, forkMirror)
+	require.NoError(t, err)
+	bundlePath := filepath.Join(t.TempDir(), "fork.bundle")
+	require.NoError(t, os.WriteFile(bundlePath, bundle, 0o644))
+	restored := filepath.Join(t.TempDir(), "restored")
+	_, err = runGit(ctx, "", nil, "clone", "-q", bundlePath, restored)
+	require.NoError(t, err)
+	count, err := runGit(ctx, restored, nil, "rev-list", "--count", "HEAD")
+	require.NoError(t, err)
+	assert.Equal(t, "2", strings.TrimSpace(string(count)))
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/wnarutou/gitrieve/internal/scm"
+	"github.com/wnarutou/gitrieve/internal/typedef"
+)
+
+// ErrUnchanged is returned by Sync when the remote advertises exactly the refs
+// recorded after the last successful sync, so nothing was fetched or stored.
+// It is an outcome, not a failure: callers report the skip and carry on.
+var ErrUnchanged = errors.New("remote refs unchanged since last sync")
+
+// ErrNoWiki is returned by a wiki sync when the repository has no wiki: it is
+// disabled, or enabled without a page ever being written, in which case
+// GitHub serves no .wiki.git remote at all. Like ErrUnchanged it is an
+// outcome, not a failure.
+var ErrNoWiki = errors.New("repository has no wiki")
+
+// remoteMissing reports whether err from listing a remote's refs with auth
+// means there is no repository to clone (as opposed to a network or credential
+// problem). GitHub asks an anonymous client to authenticate rather than admit
+// a repository does not exist, so without a credential that counts as missing
+// too.
+func remoteMissing(err error, auth gitAuth) bool {
+	if auth.token == "" && errors.Is(err, transport.ErrAuthenticationRequired) {
+		return true
+	}
+	return errors.Is(err, transport.ErrRepositoryNotFound) || errors.Is(err, transport.ErrEmptyRemoteRepository)
+}
+
+// refState is what the last successful sync of a repository component ran
+// against: the remote's refs and the options that shaped what it stored. A
+// sync is skipped only when both are the same again.
+type refState struct {
+	Options string            `json:"options"`
+	Refs    map[string]string `json:"refs"`
+}
+
+// syncOptions describes the options a stored copy depends on: which branches
+// and how much history are fetched, the backend and fork sharing, and the
+// storages it is written to. Changing any of them makes the next sync a full
+// one, so the change takes effect without waiting for an upstream push.
+func syncOptions(repo typedef.Repository, storages []typedef.MultiStorage) string {
+	targets := make([]string, 0, len(storages))
+	for _, s := range storages {
+		targets = append(targets, s.Type+":"+s.Name+":"+s.Path)
+	}
+	sort.Strings(targets)
+	data, _ := json.Marshal(struct {
+		AllBranches bool
+		Depth       int
+		GitBackend  string
+		ForkOf      string
+		DetectForks bool
+		Storages    []string
+	}{repo.AllBranches, repo.Depth, repo.GetGitBackend(), repo.ForkOf, repo.DetectForks, targets})
+	return string(data)
+}
+
+// refStatePath returns where the ref state of the last successful sync of
+// (r, component) is kept. It lives under .gitrieve/refs rather than in the
+// per-run working directory so it also survives useCache: false runs.
+func refStatePath(baseDir string, r *scm.Repository, component string) string {
+	return filepath.Join(baseDir, ".gitrieve", "refs", r.Host, r.Owner, r.Name, component+".json")
+}
+
+// loadRefState returns the recorded state, with nil Refs when there is none
+// (first sync) or it cannot be read, e.g. one written before the options were
+// recorded — all simply mean "do a full sync".
+func loadRefState(p string) refState {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return refState{}
+	}
+	var st refState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return refState{}
+	}
+	return st
+}
+
+// saveRefState records st as the state of the last successful sync.
+func saveRefState(p string, st refState) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(p, data, 0o644)
+}
+
+// listRemoteRefs returns the remote's ref advertisement as refname -> object id
+// (symbolic refs such as HEAD map to "ref: <target>"). Only HEAD and branches
+// are kept unless all is set: the go-git backend fetches nothing else, whereas
+// the CLI mirror fetches every ref.
+func listRemoteRefs(ctx context.Context, url string, auth gitAuth, all bool) (map[string]string, error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{url},
+	})
+	advertised, err := remote.ListContext(ctx, &git.ListOptions{Auth: auth.method()})
+	if err != nil {
+		return nil, err
+	}
+	refs := make(map[string]string, len(advertised))
+	for _, ref := range advertised {
+		name := ref.Name().String()
+		if !all && name != "HEAD" && !ref.Name().IsBranch() {
+			continue
+		}
+		if ref.Type() == plumbing.SymbolicReference {
+			refs[name] = "ref: " + ref.Target().String()
+		} else {
+			refs[name] = ref.Hash().String()
+		}
+	}
+	return refs, nil
+}
+
+// refsUnchanged reports whether a recorded state equals the current one. A
+// missing record never counts as unchanged.
+func refsUnchanged(recorded, current refState) bool {
+	return recorded.Refs != nil && recorded.Options == current.Options && maps.Equal(recorded.Refs, current.Refs)
+}
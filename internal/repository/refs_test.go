@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wnarutou/gitrieve/internal/scm"
+	"github.com/wnarutou/gitrieve/internal/typedef"
+)
+
+func TestRefStateRoundTrip(t *testing.T) {
+	r := &scm.Repository{Host: "github.com", Owner: "test", Name: "repo"}
+	p := refStatePath(t.TempDir(), r, "code")
+	repo := typedef.Repository{URL: "github.com/test/repo"}
+	storages := []typedef.MultiStorage{{Storage: typedef.Storage{Name: "local", Type: "file", Path: "/backup"}}}
+	options := syncOptions(repo, storages)
+
+	assert.Nil(t, loadRefState(p).Refs, "no record before the first sync")
+	assert.False(t, refsUnchanged(loadRefState(p), refState{Options: options, Refs: map[string]string{}}), "a missing record is never unchanged")
+
+	refs := map[string]string{
+		"HEAD":            "ref: refs/heads/main",
+		"refs/heads/main": "1111111111111111111111111111111111111111",
+	}
+	require.NoError(t, saveRefState(p, refState{Options: options, Refs: refs}))
+	assert.True(t, refsUnchanged(loadRefState(p), refState{Options: options, Refs: refs}))
+
+	moved := map[string]string{
+		"HEAD":            "ref: refs/heads/main",
+		"refs/heads/main": "2222222222222222222222222222222222222222",
+	}
+	assert.False(t, refsUnchanged(loadRefState(p), refState{Options: options, Refs: moved}))
+
+	// Changed options need a full sync although upstream did not move.
+	repo.AllBranches = true
+	assert.False(t, refsUnchanged(loadRefState(p), refState{Options: syncOptions(repo, storages), Refs: refs}))
+	repo.AllBranches = false
+	storages = append(storages, typedef.MultiStorage{Storage: typedef.Storage{Name: "s3", Type: "s3", Path: "bucket"}})
+	assert.False(t, refsUnchanged(loadRefState(p), refState{Options: syncOptions(repo, storages), Refs: refs}))
+	assert.Equal(t, syncOptions(repo, storages), syncOptions(repo, []typedef.MultiStorage{storages[1], storages[0]}),
+		"the order of the storages does not matter")
+
+	// A record from before the options were recorded is ignored.
+	require.NoError(t, os.WriteFile(p, []byte(`{"HEAD":"ref: refs/heads/main"}`), 0o644))
+	assert.Nil(t, loadRefState(p).Refs)
+}
+
+func TestListRemoteRefsFiltersToBranches(t *testing.T) {
+	src, commit := newSourceRepo(t)
+	_, err := runGit(context.Background(), src, nil, "tag", "v1")
+	require.NoError(t, err)
+
+	branches, err := listRemoteRefs(context.Background(), "file://"+src, gitAuth{}, false)
+	require.NoError(t, err)
+	assert.Contains(t, branches, "HEAD")
+	assert.NotContains(t, branches, "refs/tags/v1", "the go-git backend fetches branches only")
+
+	all, err := listRemoteRefs(context.Background(), "file://"+src, gitAuth{}, true)
+	require.NoError(t, err)
+	assert.Contains(t, all, "refs/tags/v1", "the CLI mirror fetches every ref")
+
+	commit()
+	again, err := listRemoteRefs(context.Background(), "file://"+src, gitAuth{}, false)
+	require.NoError(t, err)
+	assert.False(t, refsUnchanged(refState{Refs: branches}, refState{Refs: again}), "a new commit changes the advertisement")
+}
+
+func TestListRemoteRefsOfMissingRemote(t *testing.T) {
+	_, err := listRemoteRefs(context.Background(), "file://"+filepath.Join(t.TempDir(), "repo.wiki.git"), gitAuth{}, false)
+	assert.True(t, remoteMissing(err, gitAuth{}), "a wiki without pages has no remote: %v", err)
+
+	empty := t.TempDir()
+	_, err = git.PlainInit(empty, true)
+	require.NoError(t, err)
+	_, err = listRemoteRefs(context.Background(), "file://"+empty, gitAuth{}, false)
+	assert.True(t, remoteMissing(err, gitAuth{}), "an empty remote has nothing to clone: %v", err)
+}
+
+func TestListRemoteRefsOfMissingRemoteWithoutCredential(t *testing.T) {
+	// GitHub's answer to an anonymous ls-remote of a missing .wiki.git.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="GitHub"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	_, err := listRemoteRefs(context.Background(), srv.URL+"/owner/repo.wiki.git", gitAuth{}, false)
+	assert.True(t, remoteMissing(err, gitAuth{}), "an anonymous client is asked to authenticate: %v", err)
+	auth := gitAuth{host: strings.TrimPrefix(srv.URL, "http://"), token: "secret"}
+	_, err = listRemoteRefs(context.Background(), srv.URL+"/owner/repo.wiki.git", auth, false)
+	assert.False(t, remoteMissing(err, auth), "a rejected credential is a failure: %v", err)
+}
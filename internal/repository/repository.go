@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/go-git/go-git/v5"
@@ -26,7 +27,8 @@ type repoLister interface {
 }
 
 // newGithubClient 是可替换的包级 seam：生产用真客户端，测试注入 fake。
-var newGithubClient = func() (repoLister, error) { return github.New() }
+// host 为条目所在的 GitHub 实例（github.com 或 GHES）。
+var newGithubClient = func(host string) (repoLister, error) { return github.New(host) }
 
 func GetRepositories(name string) []typedef.Repository {
 	repositories := make([]typedef.Repository, 0)
@@ -50,8 +52,9 @@ func addRepo(repo typedef.Repository, ret []typedef.Repository) []typedef.Reposi
 	case typedef.TypeRepo:
 		ret = append(ret, repo)
 	case typedef.TypeUser, typedef.TypeOrg:
-		// get repos
-		client, err := newGithubClient()
+		// get repos from the instance the entry lives on
+		host, _, _ := strings.Cut(repo.Key(), "/")
+		client, err := newGithubClient(host)
 		if err != nil {
 			ui.Errorf("Error creating github client, %s", err)
 			return ret
@@ -63,18 +66,32 @@ func addRepo(repo typedef.Repository, ret []typedef.Repository) []typedef.Reposi
 		}
 		for _, r := range repos {
 			ret = append(ret, typedef.Repository{
-				Name:               path.Base(r),
-				URL:                r,
-				Cron:               repo.Cron,
-				Storage:            repo.Storage,
-				UseCache:           repo.UseCache,
-				Type:               typedef.TypeRepo,
-				AllBranches:        repo.AllBranches,
-				Depth:              repo.Depth,
-				DownloadReleases:   repo.DownloadReleases,
-				DownloadIssues:     repo.DownloadIssues,
-				DownloadWiki:       repo.DownloadWiki,
-				DownloadDiscussion: repo.DownloadDiscussion,
+				Name:                   path.Base(r),
+				URL:                    r,
+				Cron:                   repo.Cron,
+				Storage:                repo.Storage,
+				UseCache:               repo.UseCache,
+				Type:                   typedef.TypeRepo,
+				AllBranches:            repo.AllBranches,
+				Depth:                  repo.Depth,
+				DownloadReleases:       repo.DownloadReleases,
+				DownloadSourceArchives: repo.DownloadSourceArchives,
+				DownloadIssues:         repo.DownloadIssues,
+				DownloadPullRequests:   repo.DownloadPullRequests,
+				DownloadWiki:           repo.DownloadWiki,
+				DownloadDiscussion:     repo.DownloadDiscussion,
+				DownloadActions:        repo.DownloadActions,
+				DownloadMetadata:       repo.DownloadMetadata,
+				DownloadProjects:       repo.DownloadProjects,
+				DownloadSecurity:       repo.DownloadSecurity,
+				GitBackend:             repo.GitBackend,
+				DetectForks:            repo.DetectForks,
+				RewriteAttachmentLinks: repo.RewriteAttachmentLinks,
+				IssueAPI:               repo.IssueAPI,
+				Release:                repo.Release,
+				Actions:                repo.Actions,
+				Projects:               repo.Projects,
+				Wiki:                   repo.Wiki,
 			})
 		}
 	default:
@@ -86,6 +103,10 @@ func addRepo(repo typedef.Repository, ret []typedef.Repository) []typedef.Reposi
 // Sync archives a repository's code (or wiki when iswiki is set). ctx bounds
 // every go-git network operation: a caller cancellation (e.g. a user cancelling
 // a job) or the internal 30-minute timeout fails the sync instead of hanging.
+// repo.GitBackend selects go-git (the default) or the system git binary. When
+// the remote refs have not moved since the last successful sync it returns
+// ErrUnchanged without fetching, and for a wiki whose remote does not exist or
+// is empty it returns ErrNoWiki without cloning.
 func Sync(ctx context.Context, repo typedef.Repository, iswiki bool, storages []typedef.MultiStorage) error {
 	useCache := repo.UseCache
 	depth := repo.Depth
@@ -169,11 +190,76 @@ func Sync(ctx context.Context, repo typedef.Repository, iswiki bool, storages []
 	// continuous for the whole sync.
 	progress := &progressWriter{}
 
+	var targetDir string
+	if iswiki {
+		targetDir = r.Name + "_wiki"
+	} else {
+		targetDir = r.Name
+	}
+
+	// The CLI backend keeps a bare mirror next to (not inside) the go-git
+	// working tree, so switching backends never mixes the two layouts.
+	cli := repo.GetGitBackend() == typedef.GitBackendCLI
+	mirrorDir := path.Join(workingDir, r.Host, r.Owner, repoName, component+".git")
+	cachePresent := exist
+	if cli {
+		_, statErr := os.Stat(path.Join(mirrorDir, "HEAD"))
+		cachePresent = statErr == nil
+	}
+
+	// Cheap pre-check: most cron ticks are no-ops, so compare the remote's ref
+	// advertisement (one ls-remote round trip) with the one recorded after the
+	// last successful sync and skip the fetch and archive entirely when nothing
+	// moved. With useCache the local cache must also still exist, otherwise the
+	// full sync below has to rebuild it.
+	auth, err := newGitAuth(r.Host)
+	if err != nil {
+		ui.Errorf("Error getting git credentials, %s", err)
+		return err
+	}
+	statePath := refStatePath(currentDir, r, component)
+	options := syncOptions(repo, storages)
+	advertised, err := listRemoteRefs(syncCtx, "https://"+gitUrl, auth, cli)
+	if iswiki && (remoteMissing(err, auth) || (err == nil && len(advertised) == 0)) {
+		// A wiki that was enabled but never written has no remote: cloning it
+		// would fail on every tick.
+		ui.Printf("%s has no wiki pages, skipping", repo.Name)
+		return ErrNoWiki
+	}
+	if err != nil {
+		if syncCtx.Err() != nil {
+			return syncCtx.Err()
+		}
+		// Not fatal: the full sync below reports the real failure, if any.
+		ui.Printf("Could not list remote refs, doing a full sync: %s", err)
+		advertised = nil
+	} else if refsUnchanged(loadRefState(statePath), refState{Options: options, Refs: advertised}) && (cachePresent || !useCache) {
+		ui.Printf("Remote refs of %s unchanged since last sync, skipping", repo.Name)
+		return ErrUnchanged
+	}
+
+	if cli {
+		store, err := shareForkObjects(syncCtx, repo, r, iswiki, currentDir, "https://"+gitUrl, auth, progress)
+		if err != nil {
+			return err
+		}
+		if err := syncCLI(syncCtx, r, "https://"+gitUrl, auth, mirrorDir, targetDir, repo, store, progress, storages); err != nil {
+			return err
+		}
+		recordRefs(statePath, options, advertised)
+		return nil
+	}
+
+	if repo.ForkOf != "" || repo.DetectForks {
+		ui.Printf("Fork object sharing needs gitBackend: cli, syncing %s without it", repo.Name)
+	}
+
 	// clone the repo if it does not exist, otherwise pull
 	if !exist {
 		isUpdated = true
 		_, err = git.PlainCloneContext(syncCtx, gitDir, false, &git.CloneOptions{
 			URL:      "https://" + gitUrl,
+			Auth:     auth.method(),
 			Progress: progress,
 			Depth:    depth,
 		})
@@ -203,6 +289,7 @@ func Sync(ctx context.Context, repo typedef.Repository, iswiki bool, storages []
 			config.RefSpec("+refs/heads/*:refs/remotes/origin/*"),
 		},
 		Force:    true,
+		Auth:     auth.method(),
 		Progress: progress,
 	})
 	if err != nil && err != git.NoErrAlreadyUpToDate {
@@ -233,7 +320,7 @@ func Sync(ctx context.Context, repo typedef.Repository, iswiki bool, storages []
 		ui.Errorf("Error get remote, %s", err)
 		return err
 	}
-	remoteRefs, err := remote.ListContext(syncCtx, &git.ListOptions{})
+	remoteRefs, err := remote.ListContext(syncCtx, &git.ListOptions{Auth: auth.method()})
 	if err != nil {
 		// The default branch cannot be determined without this listing, and a
 		// cancellation must stop the sync here rather than falling through.
@@ -247,6 +334,10 @@ func Sync(ctx context.Context, repo typedef.Repository, iswiki bool, storages []
 		}
 	}
 
+	// A branch that fails to pull is logged and the others carry on, but the
+	// refs are then not recorded, so the next sync retries it.
+	pullFailed := false
+
 	// find all remote branches
 	if err := refs.ForEach(func(ref *plumbing.Reference) error {
 		if ref.Name().IsRemote() {
@@ -323,12 +414,14 @@ func Sync(ctx context.Context, repo typedef.Repository, iswiki bool, storages []
 				ReferenceName: branchRef,
 				// pull all commits, not only the latest
 				Depth:    depth,
+				Auth:     auth.method(),
 				Progress: progress,
 			})
 			if err == git.NoErrAlreadyUpToDate {
 				ui.Printf("local branch %s already up to date. \n", localBranchName)
 			} else if err != nil {
 				ui.Errorf("Error pulling local branch %s, %s", localBranchName, err)
+				pullFailed = true
 				if syncCtx.Err() != nil {
 					// Cancelled — stop the whole sync instead of continuing to
 					// the remaining branches. The fetched objects stay in the
@@ -364,13 +457,6 @@ func Sync(ctx context.Context, repo typedef.Repository, iswiki bool, storages []
 			// fetched objects are already preserved in the local cache.
 			return syncCtx.Err()
 		}
-		var targetDir string
-		if iswiki {
-			targetDir = r.Name + "_wiki"
-		} else {
-			targetDir = r.Name
-		}
-
 		// Archive the working tree directly from gitDir. Create takes an
 		// absolute path and never changes the process cwd, so it is safe to
 		// run from concurrent job goroutines.
@@ -385,19 +471,8 @@ func Sync(ctx context.Context, repo typedef.Repository, iswiki bool, storages []
 		// replaced directly.
 		base := targetDir + ".tar.gz"
 
-		// handle storages
-		for _, s := range storages {
-			backend, err := storage.GetStorage(s)
-			if err != nil {
-				ui.Errorf("Error getting backend, %s", err)
-				return err
-			}
-			err = backend.PutObject(path.Join(s.Path, r.Host, r.Owner, r.Name, base), buf.Bytes())
-			if err != nil {
-				ui.Errorf("Error storing file, %s", err)
-				return err
-			}
-			ui.Printf("File %s stored", path.Join(s.Path, r.Host, r.Owner, r.Name, base))
+		if err := storeObject(storages, r, base, buf.Bytes()); err != nil {
+			return err
 		}
 	} else {
 		ui.Printf("All is uptodate, no need to restore")
@@ -416,6 +491,43 @@ func Sync(ctx context.Context, repo typedef.Repository, iswiki bool, storages []
 			return err
 		}
 	}
+	if pullFailed {
+		ui.Printf("Not recording the remote refs of %s, so the next sync retries the failed branches", repo.Name)
+		return nil
+	}
+	recordRefs(statePath, options, advertised)
+	return nil
+}
+
+// recordRefs saves the advertisement and options a sync just completed
+// against so the next sync can skip when neither changed. Call it only after
+// a fully successful sync. A nil advertisement (the ls-remote failed) records
+// nothing. Failing to record is not fatal: the next sync is merely a full one.
+func recordRefs(statePath, options string, advertised map[string]string) {
+	if advertised == nil {
+		return
+	}
+	if err := saveRefState(statePath, refState{Options: options, Refs: advertised}); err != nil {
+		ui.Errorf("Error recording remote refs, %s", err)
+	}
+}
+
+// storeObject writes data to <storage path>/<host>/<owner>/<name>/<base> on
+// every storage backend.
+func storeObject(storages []typedef.MultiStorage, r *scm.Repository, base string, data []byte) error {
+	for _, s := range storages {
+		backend, err := storage.GetStorage(s)
+		if err != nil {
+			ui.Errorf("Error getting backend, %s", err)
+			return err
+		}
+		err = backend.PutObject(path.Join(s.Path, r.Host, r.Owner, r.Name, base), data)
+		if err != nil {
+			ui.Errorf("Error storing file, %s", err)
+			return err
+		}
+		ui.Printf("File %s stored", path.Join(s.Path, r.Host, r.Owner, r.Name, base))
+	}
 	return nil
 }
 
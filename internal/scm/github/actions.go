@@ -0,0 +1,193 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/google/go-github/v56/github"
+	"github.com/wnarutou/gitrieve/internal/config"
+	"github.com/wnarutou/gitrieve/internal/retry"
+)
+
+// GetWorkflows lists every workflow of a repository.
+func (c *Client) GetWorkflows(ctx context.Context, owner, repo string) ([]*github.Workflow, error) {
+	var all []*github.Workflow
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		var (
+			list *github.Workflows
+			resp *github.Response
+		)
+		err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+			var apiErr error
+			list, resp, apiErr = c.c.Actions.ListWorkflows(ctx, owner, repo, opts)
+			return apiErr
+		})
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, list.Workflows...)
+		if resp.NextPage == 0 {
+			return all, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// GetWorkflowFile returns the contents of the workflow definition at p (e.g.
+// .github/workflows/ci.yml) on the default branch.
+func (c *Client) GetWorkflowFile(ctx context.Context, owner, repo, p string) ([]byte, error) {
+	var data []byte
+	err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+		var apiErr error
+		data, apiErr = GetMedia(ctx, c.c, fmt.Sprintf("repos/%v/%v/contents/%v", owner, repo, p), "application/vnd.github.raw")
+		return apiErr
+	})
+	return data, err
+}
+
+// GetWorkflowRuns lists the completed runs of a workflow, newest first: those
+// created on or after since when it is not empty (a YYYY-MM-DD date), else
+// the latest limit.
+func (c *Client) GetWorkflowRuns(ctx context.Context, owner, repo string, workflowID int64, since string, limit int) ([]*github.WorkflowRun, error) {
+	var all []*github.WorkflowRun
+	opts := &github.ListWorkflowRunsOptions{Status: "completed", ListOptions: github.ListOptions{PerPage: 100}}
+	if since != "" {
+		opts.Created = ">=" + since
+	}
+	for {
+		var (
+			list *github.WorkflowRuns
+			resp *github.Response
+		)
+		err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+			var apiErr error
+			list, resp, apiErr = c.c.Actions.ListWorkflowRunsByID(ctx, owner, repo, workflowID, opts)
+			return apiErr
+		})
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, list.WorkflowRuns...)
+		if since == "" && len(all) >= limit {
+			return all[:limit], nil
+		}
+		if resp.NextPage == 0 {
+			return all, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// GetWorkflowJobs lists the jobs of a run, from every attempt.
+func (c *Client) GetWorkflowJobs(ctx context.Context, owner, repo string, runID int64) ([]*github.WorkflowJob, error) {
+	var all []*github.WorkflowJob
+	opts := &github.ListWorkflowJobsOptions{Filter: "all", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		var (
+			list *github.Jobs
+			resp *github.Response
+		)
+		err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+			var apiErr error
+			list, resp, apiErr = c.c.Actions.ListWorkflowJobs(ctx, owner, repo, runID, opts)
+			return apiErr
+		})
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, list.Jobs...)
+		if resp.NextPage == 0 {
+			return all, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// GetRunArtifacts lists the artifacts a run uploaded, expired ones included.
+func (c *Client) GetRunArtifacts(ctx context.Context, owner, repo string, runID int64) ([]*github.Artifact, error) {
+	var all []*github.Artifact
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		var (
+			list *github.ArtifactList
+			resp *github.Response
+		)
+		err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+			var apiErr error
+			list, resp, apiErr = c.c.Actions.ListWorkflowRunArtifacts(ctx, owner, repo, runID, opts)
+			return apiErr
+		})
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, list.Artifacts...)
+		if resp.NextPage == 0 {
+			return all, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// DownloadRunLogs downloads the zip of a run's logs into file and returns its
+// size. GitHub answers 404 or 410 once they have expired.
+func (c *Client) DownloadRunLogs(ctx context.Context, owner, repo string, runID int64, file string) (int64, error) {
+	return c.downloadRedirect(ctx, file, func() (*url.URL, *github.Response, error) {
+		return c.c.Actions.GetWorkflowRunLogs(ctx, owner, repo, runID, 0)
+	})
+}
+
+// DownloadArtifact downloads the zip of an artifact into file and returns its
+// size.
+func (c *Client) DownloadArtifact(ctx context.Context, owner, repo string, artifactID int64, file string) (int64, error) {
+	return c.downloadRedirect(ctx, file, func() (*url.URL, *github.Response, error) {
+		return c.c.Actions.DownloadArtifact(ctx, owner, repo, artifactID, 0)
+	})
+}
+
+// downloadRedirect asks the API for a short-lived download URL and streams it
+// into file, returning its size. Both happen in one attempt, as the URL
+// expires within minutes; each attempt starts file over.
+func (c *Client) downloadRedirect(ctx context.Context, file string, locate func() (*url.URL, *github.Response, error)) (int64, error) {
+	var size int64
+	err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+		u, resp, err := locate()
+		if err != nil {
+			// go-github reports anything but a redirect as a bare error;
+			// recover the API error so retry and NotFound can classify it.
+			if resp != nil {
+				if respErr := github.CheckResponse(resp.Response); respErr != nil {
+					return respErr
+				}
+			}
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+		if err != nil {
+			return err
+		}
+		body, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer body.Body.Close()
+		if body.StatusCode != http.StatusOK {
+			return fmt.Errorf("download %s: %s", u.Redacted(), body.Status)
+		}
+		f, err := os.Create(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		size, err = io.Copy(f, body.Body)
+		if err != nil {
+			return &url.Error{Op: "Get", URL: u.Redacted(), Err: err}
+		}
+		return f.Close()
+	})
+	return size, err
+}
@@ -0,0 +1,147 @@
+package github
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/wnarutou/gitrieve/internal/typedef"
+	"golang.org/x/oauth2"
+)
+
+// tokenRefreshMargin is how long before expiry an installation token is
+// replaced. Installation tokens live one hour; refreshing early keeps a token
+// from expiring in the middle of a long paginated sync.
+const tokenRefreshMargin = 5 * time.Minute
+
+// appTokenSource mints GitHub App installation access tokens: it signs a
+// short-lived JWT with the App's private key and exchanges it at
+// POST /app/installations/{id}/access_tokens. Wrap it in
+// oauth2.ReuseTokenSourceWithExpiry so tokens are cached until shortly before
+// they expire.
+type appTokenSource struct {
+	appID          int64
+	installationID int64
+	key            *rsa.PrivateKey
+	baseURL        string // REST API root with a trailing slash
+	httpClient     *http.Client
+	now            func() time.Time
+}
+
+// newAppTokenSource parses the App's private key and returns a caching token
+// source for its installation tokens.
+func newAppTokenSource(app typedef.GitHubApp, baseURL string) (oauth2.TokenSource, error) {
+	pemData := []byte(app.PrivateKey)
+	if len(pemData) == 0 {
+		var err error
+		pemData, err = os.ReadFile(app.PrivateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("read githubApp private key: %w", err)
+		}
+	}
+	key, err := parsePrivateKey(pemData)
+	if err != nil {
+		return nil, err
+	}
+	src := &appTokenSource{
+		appID:          app.AppID,
+		installationID: app.InstallationID,
+		key:            key,
+		baseURL:        baseURL,
+		httpClient:     &http.Client{Timeout: 30 * time.Second},
+		now:            time.Now,
+	}
+	return oauth2.ReuseTokenSourceWithExpiry(nil, src, tokenRefreshMargin), nil
+}
+
+// parsePrivateKey accepts the PKCS#1 key GitHub hands out as well as PKCS#8.
+func parsePrivateKey(pemData []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("githubApp private key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse githubApp private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("githubApp private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// jwt returns the RS256-signed App JWT used to request installation tokens.
+// iat is backdated a minute to absorb clock drift; GitHub caps exp at ten
+// minutes.
+func (s *appTokenSource) jwt() (string, error) {
+	now := s.now()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(struct {
+		IssuedAt  int64  `json:"iat"`
+		ExpiresAt int64  `json:"exp"`
+		Issuer    string `json:"iss"`
+	}{
+		IssuedAt:  now.Add(-time.Minute).Unix(),
+		ExpiresAt: now.Add(9 * time.Minute).Unix(),
+		Issuer:    fmt.Sprint(s.appID),
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// Token implements oauth2.TokenSource by minting a fresh installation token.
+func (s *appTokenSource) Token() (*oauth2.Token, error) {
+	jwt, err := s.jwt()
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%sapp/installations/%d/access_tokens", s.baseURL, s.installationID)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(nil))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		var body bytes.Buffer
+		_, _ = body.ReadFrom(resp.Body)
+		return nil, fmt.Errorf("create installation token: %s: %s", resp.Status, strings.TrimSpace(body.String()))
+	}
+	var out struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode installation token: %w", err)
+	}
+	return &oauth2.Token{AccessToken: out.Token, TokenType: "Bearer", Expiry: out.ExpiresAt}, nil
+}
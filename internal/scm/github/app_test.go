@@ -0,0 +1,86 @@
+package github
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wnarutou/gitrieve/internal/typedef"
+)
+
+func TestAppTokenSource(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	var minted atomic.Int32
+	expiry := time.Now().Add(time.Hour)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/app/installations/42/access_tokens", r.URL.Path)
+
+		// The bearer must be an RS256 JWT signed by the App key, issued by the App.
+		jwt := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		parts := strings.Split(jwt, ".")
+		require.Len(t, parts, 3)
+		sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+		require.NoError(t, err)
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		assert.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig))
+		claims, err := base64.RawURLEncoding.DecodeString(parts[1])
+		require.NoError(t, err)
+		var c struct {
+			Iss string `json:"iss"`
+			Iat int64  `json:"iat"`
+			Exp int64  `json:"exp"`
+		}
+		require.NoError(t, json.Unmarshal(claims, &c))
+		assert.Equal(t, "7", c.Iss)
+		assert.LessOrEqual(t, c.Exp-c.Iat, int64(600), "GitHub rejects App JWTs living over ten minutes")
+
+		n := minted.Add(1)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token":"ghs_%d","expires_at":%q}`, n, expiry.Format(time.RFC3339))
+	}))
+	defer srv.Close()
+
+	src, err := newAppTokenSource(typedef.GitHubApp{AppID: 7, InstallationID: 42, PrivateKey: string(keyPEM)}, srv.URL+"/")
+	require.NoError(t, err)
+
+	tok, err := src.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "ghs_1", tok.AccessToken)
+	tok, err = src.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "ghs_1", tok.AccessToken, "a valid token is reused")
+	assert.EqualValues(t, 1, minted.Load())
+
+	// A token within the refresh margin of its expiry is replaced.
+	expiry = time.Now().Add(tokenRefreshMargin / 2)
+	src, err = newAppTokenSource(typedef.GitHubApp{AppID: 7, InstallationID: 42, PrivateKey: string(keyPEM)}, srv.URL+"/")
+	require.NoError(t, err)
+	_, err = src.Token()
+	require.NoError(t, err)
+	tok, err = src.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "ghs_3", tok.AccessToken)
+}
+
+func TestParsePrivateKeyRejectsGarbage(t *testing.T) {
+	_, err := parsePrivateKey([]byte("not a key"))
+	require.Error(t, err)
+}
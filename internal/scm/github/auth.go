@@ -0,0 +1,249 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v56/github"
+	"github.com/shurcooL/githubv4"
+	"github.com/wnarutou/gitrieve/internal/config"
+	"github.com/wnarutou/gitrieve/internal/typedef"
+	"golang.org/x/oauth2"
+)
+
+// github.com endpoints.
+const (
+	defaultBaseURL    = "https://api.github.com/"
+	defaultUploadURL  = "https://uploads.github.com/"
+	defaultGraphQLURL = "https://api.github.com/graphql"
+)
+
+// GitUsername is the HTTPS username paired with a token for git transfers. It
+// works for personal tokens and App installation tokens alike.
+const GitUsername = "x-access-token"
+
+// endpoint is a GitHub instance resolved for one host: where its REST, upload
+// and GraphQL APIs live and the credential requests to it carry. Static tokens
+// go through a pool (possibly of one) that tracks their quota; a GitHub App
+// uses source instead. Neither set means anonymous access.
+type endpoint struct {
+	baseURL    string
+	uploadURL  string
+	graphQLURL string
+	source     oauth2.TokenSource
+	pool       *tokenPool
+	config     string // the settings it was built from, see hostConfig
+}
+
+var (
+	endpointsMu sync.Mutex
+	endpoints   = map[string]*endpoint{}
+)
+
+// resolveHost fills in the endpoints of host. A githubHosts entry wins;
+// github.com otherwise uses the public endpoints, and any other host is taken
+// to be a GitHub Enterprise Server with the default /api layout.
+func resolveHost(cfg *config.Config, host string) typedef.GitHubHost {
+	h := typedef.GitHubHost{Host: host}
+	for _, configured := range cfg.GitHubHosts {
+		if strings.EqualFold(configured.Host, host) {
+			h = configured
+			break
+		}
+	}
+	if host == typedef.DefaultGitHubHost {
+		if h.APIURL == "" {
+			h.APIURL = defaultBaseURL
+		}
+		if h.UploadURL == "" {
+			h.UploadURL = defaultUploadURL
+		}
+		if h.GraphQLURL == "" {
+			h.GraphQLURL = defaultGraphQLURL
+		}
+		return h
+	}
+	if h.APIURL == "" {
+		h.APIURL = "https://" + host + "/api/v3/"
+	}
+	if h.UploadURL == "" {
+		h.UploadURL = "https://" + host + "/api/uploads/"
+	}
+	if h.GraphQLURL == "" {
+		h.GraphQLURL = "https://" + host + "/api/graphql"
+	}
+	return h
+}
+
+// endpointFor returns the resolved endpoint of host, building it on first use
+// and again whenever the host's settings change, e.g. a token replaced
+// through the API. Credentials are scoped to their host: githubApp, githubToken and
+// githubTokens apply to github.com only (an App wins over tokens), a
+// githubHosts entry carries its own token, and a host that is not configured
+// gets none. The App source refreshes its token by itself before expiry.
+func endpointFor(host string) (*endpoint, error) {
+	host = strings.ToLower(host)
+	if host == "" {
+		host = typedef.DefaultGitHubHost
+	}
+	cfg := config.GetIns()
+	if cfg == nil {
+		// Config not loaded (unit tests): anonymous, and not cached.
+		h := resolveHost(&config.Config{}, host)
+		return &endpoint{baseURL: h.APIURL, uploadURL: h.UploadURL, graphQLURL: h.GraphQLURL}, nil
+	}
+
+	endpointsMu.Lock()
+	defer endpointsMu.Unlock()
+	current := hostConfig(cfg, host)
+	if ep, ok := endpoints[host]; ok && ep.config == current {
+		return ep, nil
+	}
+	ep, err := newEndpoint(cfg, host)
+	if err != nil {
+		return nil, err
+	}
+	ep.config = current
+	endpoints[host] = ep
+	return ep, nil
+}
+
+// hostConfig returns the settings of cfg that newEndpoint builds host's
+// endpoint from, as a comparable string.
+func hostConfig(cfg *config.Config, host string) string {
+	settings := []interface{}{resolveHost(cfg, host)}
+	if host == typedef.DefaultGitHubHost {
+		settings = append(settings, cfg.GitHubApp, cfg.GitHubToken, cfg.GitHubTokens)
+	}
+	return fmt.Sprintf("%#v", settings)
+}
+
+// newEndpoint resolves host and its credential from cfg. Token lists that
+// hold only empty entries, e.g. an unset environment variable, leave the
+// host anonymous.
+func newEndpoint(cfg *config.Config, host string) (*endpoint, error) {
+	h := resolveHost(cfg, host)
+	ep := &endpoint{baseURL: h.APIURL, uploadURL: h.UploadURL, graphQLURL: h.GraphQLURL}
+	switch {
+	case h.Token != "":
+		ep.pool = newTokenPool([]string{h.Token})
+	case host != typedef.DefaultGitHubHost:
+		// githubApp and githubToken are github.com credentials.
+	case cfg.GitHubApp.Configured():
+		src, err := newAppTokenSource(cfg.GitHubApp, h.APIURL)
+		if err != nil {
+			return nil, err
+		}
+		ep.source = src
+	default:
+		if pool := newTokenPool(append([]string{cfg.GitHubToken}, cfg.GitHubTokens...)); len(pool.tokens) > 0 {
+			ep.pool = pool
+		}
+	}
+	return ep, nil
+}
+
+// httpClient returns the http.Client that API requests to ep go through. It
+// attaches the current credential to every request.
+func (ep *endpoint) httpClient() *http.Client {
+	switch {
+	case ep.pool != nil:
+		return &http.Client{Transport: ep.pool}
+	case ep.source != nil:
+		return oauth2.NewClient(context.Background(), ep.source)
+	default:
+		return &http.Client{}
+	}
+}
+
+// NewRESTClient returns an authenticated go-github client for host.
+func NewRESTClient(host string) (*github.Client, error) {
+	ep, err := endpointFor(host)
+	if err != nil {
+		return nil, err
+	}
+	c := github.NewClient(ep.httpClient())
+	// Set the roots verbatim rather than through WithEnterpriseURLs, which
+	// appends /api/v3/ to anything that does not already end in it.
+	if c.BaseURL, err = apiRoot(ep.baseURL); err != nil {
+		return nil, err
+	}
+	if c.UploadURL, err = apiRoot(ep.uploadURL); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// apiRoot parses a REST root; go-github requires the trailing slash.
+func apiRoot(raw string) (*url.URL, error) {
+	if !strings.HasSuffix(raw, "/") {
+		raw += "/"
+	}
+	return url.Parse(raw)
+}
+
+// NewGraphQLClient returns an authenticated GitHub GraphQL (v4) client for
+// host.
+func NewGraphQLClient(host string) (*githubv4.Client, error) {
+	ep, err := endpointFor(host)
+	if err != nil {
+		return nil, err
+	}
+	return githubv4.NewEnterpriseClient(ep.graphQLURL, ep.httpClient()), nil
+}
+
+// GitToken returns the token HTTPS git transfers to host authenticate with
+// (use it as the password, with GitUsername). It is "" when host has no
+// credential, so a token is never sent to a host it was not configured for.
+func GitToken(host string) (string, error) {
+	ep, err := endpointFor(host)
+	if err != nil {
+		return "", err
+	}
+	if ep.pool != nil {
+		return ep.pool.gitToken(), nil
+	}
+	if ep.source == nil {
+		return "", nil
+	}
+	tok, err := ep.source.Token()
+	if err != nil {
+		return "", err
+	}
+	return tok.AccessToken, nil
+}
+
+// TokenQuotas reports the quota of every pooled token of the hosts used so
+// far, github.com first. Tokens are masked.
+func TokenQuotas() []TokenQuota {
+	if _, err := endpointFor(typedef.DefaultGitHubHost); err != nil {
+		return []TokenQuota{}
+	}
+	endpointsMu.Lock()
+	hosts := make([]string, 0, len(endpoints))
+	for host, ep := range endpoints {
+		if ep.pool != nil && host != typedef.DefaultGitHubHost {
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts)
+	if ep := endpoints[typedef.DefaultGitHubHost]; ep != nil && ep.pool != nil {
+		hosts = append([]string{typedef.DefaultGitHubHost}, hosts...)
+	}
+	pools := make([]*tokenPool, len(hosts))
+	for i, host := range hosts {
+		pools[i] = endpoints[host].pool
+	}
+	endpointsMu.Unlock()
+
+	out := []TokenQuota{}
+	for i, p := range pools {
+		out = append(out, p.quotas(hosts[i])...)
+	}
+	return out
+}
@@ -0,0 +1,59 @@
+package github
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wnarutou/gitrieve/internal/config"
+	"github.com/wnarutou/gitrieve/internal/scm/github/githubtest"
+	"github.com/wnarutou/gitrieve/internal/typedef"
+)
+
+func TestResolveHost(t *testing.T) {
+	cfg := &config.Config{GitHubHosts: []typedef.GitHubHost{
+		{Host: "GitHub.Corp.Example", Token: "corp-token"},
+		{Host: "ghe.example", APIURL: "https://api.ghe.example/", GraphQLURL: "https://api.ghe.example/graphql"},
+	}}
+
+	h := resolveHost(cfg, "github.com")
+	assert.Equal(t, defaultBaseURL, h.APIURL)
+	assert.Equal(t, defaultUploadURL, h.UploadURL)
+	assert.Equal(t, defaultGraphQLURL, h.GraphQLURL)
+
+	h = resolveHost(cfg, "github.corp.example")
+	assert.Equal(t, "https://github.corp.example/api/v3/", h.APIURL)
+	assert.Equal(t, "https://github.corp.example/api/uploads/", h.UploadURL)
+	assert.Equal(t, "https://github.corp.example/api/graphql", h.GraphQLURL)
+	assert.Equal(t, "corp-token", h.Token)
+
+	h = resolveHost(cfg, "ghe.example")
+	assert.Equal(t, "https://api.ghe.example/", h.APIURL, "configured endpoints are used verbatim")
+	assert.Equal(t, "https://ghe.example/api/uploads/", h.UploadURL)
+	assert.Equal(t, "https://api.ghe.example/graphql", h.GraphQLURL)
+}
+
+func TestNewRESTClientUsesHostEndpoints(t *testing.T) {
+	c, err := NewRESTClient("github.com")
+	assert.NoError(t, err)
+	assert.Equal(t, defaultBaseURL, c.BaseURL.String())
+
+	c, err = NewRESTClient("github.corp.example")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://github.corp.example/api/v3/", c.BaseURL.String())
+	assert.Equal(t, "https://github.corp.example/api/uploads/", c.UploadURL.String())
+}
+
+func TestEndpointRebuiltWhenHostConfigChanges(t *testing.T) {
+	host := githubtest.Serve(t, http.NotFoundHandler())
+	token, err := GitToken(host)
+	require.NoError(t, err)
+	assert.Equal(t, "", token)
+
+	// As the API does when a host's token is set.
+	config.GetIns().GitHubHosts[0].Token = "new-token"
+	token, err = GitToken(host)
+	require.NoError(t, err)
+	assert.Equal(t, "new-token", token)
+}
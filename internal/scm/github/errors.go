@@ -0,0 +1,28 @@
+package github
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/go-github/v56/github"
+)
+
+// NotFound reports whether err is GitHub answering 404 or 410, as it does for
+// missing resources, features disabled for a repository and expired logs.
+func NotFound(err error) bool {
+	var respErr *github.ErrorResponse
+	if !errors.As(err, &respErr) || respErr.Response == nil {
+		return false
+	}
+	return respErr.Response.StatusCode == http.StatusNotFound || respErr.Response.StatusCode == http.StatusGone
+}
+
+// Forbidden reports whether err is GitHub answering 403 other than for a rate
+// limit, as it does when the token lacks a permission or a feature is off.
+func Forbidden(err error) bool {
+	var respErr *github.ErrorResponse
+	if !errors.As(err, &respErr) || respErr.Response == nil {
+		return false
+	}
+	return respErr.Response.StatusCode == http.StatusForbidden
+}
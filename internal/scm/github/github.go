@@ -1,10 +1,16 @@
 package github
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"strings"
 	"sync"
 
 	"github.com/google/go-github/v56/github"
@@ -17,20 +23,26 @@ type Client struct {
 	c *github.Client
 }
 
-var once sync.Once
-var client *Client
+var (
+	clientsMu sync.Mutex
+	clients   = map[string]*Client{}
+)
 
-func New() (*Client, error) {
-	once.Do(func() {
-		cfg := config.GetIns()
-		client = &Client{
-			c: github.NewClient(nil).WithAuthToken(cfg.GitHubToken),
-		}
-		if cfg.GitHubToken != "" {
-			client.c = client.c.WithAuthToken(cfg.GitHubToken)
-		}
-	})
-	return client, nil
+// New returns the shared client for the GitHub instance at host (github.com
+// or a GitHub Enterprise Server configured under githubHosts).
+func New(host string) (*Client, error) {
+	host = strings.ToLower(host)
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if c, ok := clients[host]; ok {
+		return c, nil
+	}
+	c, err := NewRESTClient(host)
+	if err != nil {
+		return nil, err
+	}
+	clients[host] = &Client{c: c}
+	return clients[host], nil
 }
 
 func (c *Client) GetRepos(name string, accountType string) ([]string, error) {
@@ -58,6 +70,28 @@ func (c *Client) GetRepos(name string, accountType string) ([]string, error) {
 	return repos, nil
 }
 
+// GetForkSource returns the root of owner/repo's fork network as
+// "host/owner/name", or "" when the repository is not a fork.
+func (c *Client) GetForkSource(ctx context.Context, owner, repo string) (string, error) {
+	var r *github.Repository
+	err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+		var apiErr error
+		r, _, apiErr = c.c.Repositories.Get(ctx, owner, repo)
+		return apiErr
+	})
+	if err != nil {
+		return "", err
+	}
+	if !r.GetFork() || r.GetSource() == nil {
+		return "", nil
+	}
+	URL, err := url.Parse(r.GetSource().GetHTMLURL())
+	if err != nil {
+		return "", err
+	}
+	return URL.Hostname() + URL.Path, nil
+}
+
 func (c *Client) GetReleases(ctx context.Context, owner, repo string) ([]*github.RepositoryRelease, error) {
 	var (
 		list []*github.RepositoryRelease
@@ -74,14 +108,24 @@ func (c *Client) GetReleases(ctx context.Context, owner, repo string) ([]*github
 	return list, nil
 }
 
-func (c *Client) GetReleaseAssets(ctx context.Context, owner, repo string, id int64) ([]*github.ReleaseAsset, error) {
+// ReleaseAsset is a release asset with the digest GitHub publishes for it
+// ("sha256:<hex>"), which go-github does not model. Older assets have none.
+type ReleaseAsset struct {
+	*github.ReleaseAsset
+	Digest string `json:"digest,omitempty"`
+}
+
+func (c *Client) GetReleaseAssets(ctx context.Context, owner, repo string, id int64) ([]*ReleaseAsset, error) {
 	var (
-		list []*github.ReleaseAsset
+		list []*ReleaseAsset
 		err  error
 	)
 	err = retry.Do(ctx, config.GetRetryConfig(), func() error {
-		var apiErr error
-		list, _, apiErr = c.c.Repositories.ListReleaseAssets(ctx, owner, repo, id, nil)
+		req, apiErr := c.c.NewRequest("GET", fmt.Sprintf("repos/%v/%v/releases/%d/assets", owner, repo, id), nil)
+		if apiErr != nil {
+			return apiErr
+		}
+		_, apiErr = c.c.Do(ctx, req, &list)
 		return apiErr
 	})
 	if err != nil {
@@ -90,15 +134,109 @@ func (c *Client) GetReleaseAssets(ctx context.Context, owner, repo string, id in
 	return list, nil
 }
 
-func (c *Client) DownloadAsset(ctx context.Context, owner, repo string, id int64) (io.ReadCloser, error) {
-	var rc io.ReadCloser
+// DownloadAsset downloads a release asset into file and returns the hex
+// SHA-256 and size of what file then holds, hashed as it is written. What an
+// interrupted download left in file is kept and the rest requested with an
+// HTTP Range header, unless the server ignores it and sends the whole asset
+// again. Dropped connections are retried, each attempt resuming where the last
+// one stopped.
+func (c *Client) DownloadAsset(ctx context.Context, owner, repo string, id int64, file string) (string, int64, error) {
+	var (
+		sum  string
+		size int64
+	)
 	err := retry.Do(ctx, config.GetRetryConfig(), func() error {
-		var apiErr error
-		rc, _, apiErr = c.c.Repositories.DownloadReleaseAsset(ctx, owner, repo, id, http.DefaultClient)
-		return apiErr
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_RDWR, 0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		offset, err := f.Seek(0, io.SeekEnd)
+		if err != nil {
+			return err
+		}
+		// Without a redirect-following client go-github hands back the
+		// storage URL GitHub redirects to, which we can send Range to.
+		rc, redirect, err := c.c.Repositories.DownloadReleaseAsset(ctx, owner, repo, id, nil)
+		if err != nil {
+			return err
+		}
+		if rc == nil {
+			req, err := http.NewRequestWithContext(ctx, "GET", redirect, nil)
+			if err != nil {
+				return err
+			}
+			if offset > 0 {
+				req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return err
+			}
+			switch resp.StatusCode {
+			case http.StatusPartialContent:
+			case http.StatusOK:
+				offset = 0
+			case http.StatusRequestedRangeNotSatisfiable:
+				// file already holds the whole asset.
+				resp.Body.Close()
+				rc = io.NopCloser(strings.NewReader(""))
+			default:
+				resp.Body.Close()
+				return fmt.Errorf("download asset %d: %s", id, resp.Status)
+			}
+			if rc == nil {
+				rc = resp.Body
+			}
+		} else {
+			offset = 0
+		}
+		defer rc.Close()
+		// Hash what is kept of file, then the rest as it arrives.
+		h := sha256.New()
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if offset == 0 {
+			if err := f.Truncate(0); err != nil {
+				return err
+			}
+		} else if _, err := io.CopyN(h, f, offset); err != nil {
+			return err
+		}
+		n, err := io.Copy(io.MultiWriter(f, h), rc)
+		if err != nil {
+			// Report a dropped connection as the network error it is, so
+			// that it is retried.
+			return &url.Error{Op: "Get", URL: redirect, Err: err}
+		}
+		sum, size = hex.EncodeToString(h.Sum(nil)), offset+n
+		return nil
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	return sum, size, nil
+}
+
+// DownloadArchive downloads a source archive of a release from u, its
+// zipball_url or tarball_url, following the redirect to the archive host.
+func (c *Client) DownloadArchive(ctx context.Context, u string) ([]byte, error) {
+	var data []byte
+	err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+		req, err := c.c.NewRequest("GET", u, nil)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if _, err := c.c.Do(ctx, req, &buf); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+		return nil
 	})
 	if err != nil {
 		return nil, err
 	}
-	return rc, nil
+	return data, nil
 }
@@ -0,0 +1,43 @@
+// Package githubtest points the GitHub clients at a fake API in tests.
+package githubtest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wnarutou/gitrieve/internal/config"
+)
+
+// Serve points the package-global config at a githubHosts entry whose REST
+// and GraphQL APIs are served by handler, and returns the host to use in
+// repository URLs. The config is unloaded, and the .gitrieve working
+// directory a sync leaves behind removed, when the test ends.
+func Serve(t testing.TB, handler http.Handler) string {
+	t.Helper()
+	return ServeWithConfig(t, handler, "")
+}
+
+// ServeWithConfig is Serve with extra appended to the config file.
+func ServeWithConfig(t testing.TB, handler http.Handler, extra string) string {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	tmp, err := os.CreateTemp(t.TempDir(), "config-*.yaml")
+	require.NoError(t, err)
+	_, err = fmt.Fprintf(tmp, "githubHosts:\n  - host: %q\n    apiURL: %q\n    graphqlURL: %q\n%s",
+		host, server.URL+"/", server.URL+"/graphql", extra)
+	require.NoError(t, err)
+	require.NoError(t, tmp.Close())
+	config.Path = tmp.Name()
+	config.Init()
+	t.Cleanup(func() { config.Path = "" })
+	t.Cleanup(func() { os.RemoveAll(".gitrieve") })
+	return host
+}
@@ -0,0 +1,120 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/google/go-github/v56/github"
+	"github.com/wnarutou/gitrieve/internal/config"
+	"github.com/wnarutou/gitrieve/internal/retry"
+)
+
+// GetRepository returns the repository as GitHub reports it. Renamed and
+// transferred repositories are followed to their new name.
+func (c *Client) GetRepository(ctx context.Context, owner, repo string) (*github.Repository, error) {
+	var r *github.Repository
+	err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+		var apiErr error
+		r, _, apiErr = c.c.Repositories.Get(ctx, owner, repo)
+		return apiErr
+	})
+	return r, err
+}
+
+// GetLabels lists every label of a repository.
+func (c *Client) GetLabels(ctx context.Context, owner, repo string) ([]*github.Label, error) {
+	var all []*github.Label
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		var (
+			list []*github.Label
+			resp *github.Response
+		)
+		err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+			var apiErr error
+			list, resp, apiErr = c.c.Issues.ListLabels(ctx, owner, repo, opts)
+			return apiErr
+		})
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, list...)
+		if resp.NextPage == 0 {
+			return all, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// GetMilestones lists every milestone of a repository, open and closed.
+func (c *Client) GetMilestones(ctx context.Context, owner, repo string) ([]*github.Milestone, error) {
+	var all []*github.Milestone
+	opts := &github.MilestoneListOptions{State: "all", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		var (
+			list []*github.Milestone
+			resp *github.Response
+		)
+		err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+			var apiErr error
+			list, resp, apiErr = c.c.Issues.ListMilestones(ctx, owner, repo, opts)
+			return apiErr
+		})
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, list...)
+		if resp.NextPage == 0 {
+			return all, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// GetBranchProtections returns the protection settings of every protected
+// branch by name. Reading them takes admin rights; without those a branch
+// maps to nil, which still records that it is protected.
+func (c *Client) GetBranchProtections(ctx context.Context, owner, repo string) (map[string]*github.Protection, error) {
+	protections := make(map[string]*github.Protection)
+	opts := &github.BranchListOptions{Protected: github.Bool(true), ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		var (
+			list []*github.Branch
+			resp *github.Response
+		)
+		err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+			var apiErr error
+			list, resp, apiErr = c.c.Repositories.ListBranches(ctx, owner, repo, opts)
+			return apiErr
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, branch := range list {
+			var protection *github.Protection
+			err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+				var apiErr error
+				protection, _, apiErr = c.c.Repositories.GetBranchProtection(ctx, owner, repo, branch.GetName())
+				return apiErr
+			})
+			if errors.Is(err, github.ErrBranchNotProtected) {
+				// unprotected since it was listed
+				continue
+			}
+			var respErr *github.ErrorResponse
+			if errors.As(err, &respErr) && respErr.Response != nil &&
+				(respErr.Response.StatusCode == http.StatusForbidden || respErr.Response.StatusCode == http.StatusNotFound) {
+				protection, err = nil, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			protections[branch.GetName()] = protection
+		}
+		if resp.NextPage == 0 {
+			return protections, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
@@ -0,0 +1,254 @@
+package github
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// unknownQuota ranks a token whose quota has not been observed yet (or whose
+// window has reset) above any observed one, so fresh tokens are tried first.
+const unknownQuota = math.MaxInt32
+
+// tokenPool is an http.RoundTripper that spreads requests over several tokens
+// of one GitHub host. Every response's X-RateLimit-* headers update the quota
+// of the token that made it, each request goes out with the token that has
+// the most remaining quota for its resource (core, search, graphql), and a
+// request rejected because its token ran dry is replayed with the next token.
+//
+// Responses are returned with the rate headers of the best token rather than
+// the one used, so go-github's client-side limiter and retry.Do only see the
+// pool as exhausted, and sleep until the earliest reset, once every token is.
+type tokenPool struct {
+	mu     sync.Mutex
+	tokens []*pooledToken
+	base   http.RoundTripper
+	now    func() time.Time
+}
+
+type pooledToken struct {
+	token string
+	quota map[string]rateState // by X-RateLimit-Resource
+}
+
+type rateState struct {
+	limit     int
+	remaining int
+	reset     time.Time
+}
+
+func newTokenPool(tokens []string) *tokenPool {
+	p := &tokenPool{base: http.DefaultTransport, now: time.Now}
+	seen := make(map[string]bool, len(tokens))
+	for _, t := range tokens {
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		p.tokens = append(p.tokens, &pooledToken{token: t, quota: map[string]rateState{}})
+	}
+	return p
+}
+
+// requestResource guesses the rate-limit resource a request is charged to.
+func requestResource(req *http.Request) string {
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/graphql"):
+		return "graphql"
+	case strings.Contains(req.URL.Path, "/search/"):
+		return "search"
+	default:
+		return "core"
+	}
+}
+
+// remaining returns the quota t has left for resource as of now.
+func (t *pooledToken) remaining(resource string, now time.Time) int {
+	s, ok := t.quota[resource]
+	if !ok || !now.Before(s.reset) {
+		return unknownQuota
+	}
+	return s.remaining
+}
+
+// pick returns the index of the token to use for resource, skipping tried
+// ones: the one with the most remaining quota, or when every candidate is dry
+// the one whose window resets first. It returns -1 when all were tried.
+func (p *tokenPool) pick(resource string, tried map[int]bool) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	best := -1
+	for i, t := range p.tokens {
+		if tried[i] {
+			continue
+		}
+		if best < 0 {
+			best = i
+			continue
+		}
+		r, b := t.remaining(resource, now), p.tokens[best].remaining(resource, now)
+		switch {
+		case r > b:
+			best = i
+		case r == 0 && b == 0 && t.quota[resource].reset.Before(p.tokens[best].quota[resource].reset):
+			best = i
+		}
+	}
+	return best
+}
+
+// observe records the rate headers of a response made with token i.
+func (p *tokenPool) observe(i int, h http.Header) {
+	resource := h.Get("X-RateLimit-Resource")
+	remaining, err1 := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	reset, err2 := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	if resource == "" || err1 != nil || err2 != nil {
+		return
+	}
+	limit, _ := strconv.Atoi(h.Get("X-RateLimit-Limit"))
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tokens[i].quota[resource] = rateState{limit: limit, remaining: remaining, reset: time.Unix(reset, 0)}
+}
+
+// hasQuota reports whether token i may have quota left for resource.
+func (p *tokenPool) hasQuota(i int, resource string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.tokens[i].remaining(resource, p.now()) > 0
+}
+
+// present rewrites the rate headers of resp to the quota of the token the next
+// request for resource would use. When even that token is dry the whole pool
+// is, and the real headers are left for the caller to wait on.
+func (p *tokenPool) present(resource string, h http.Header) {
+	i := p.pick(resource, nil)
+	if i < 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	t := p.tokens[i]
+	if t.remaining(resource, now) == 0 {
+		return
+	}
+	s, ok := t.quota[resource]
+	if !ok || !now.Before(s.reset) {
+		// Unused, or its window has reset: quota left, exact figure unknown.
+		h.Del("X-RateLimit-Remaining")
+		h.Del("X-RateLimit-Reset")
+		return
+	}
+	h.Set("X-RateLimit-Limit", strconv.Itoa(s.limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(s.remaining))
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(s.reset.Unix(), 10))
+}
+
+// exhausted reports whether resp rejected the request because its token's
+// primary rate limit ran out.
+func exhausted(resp *http.Response) bool {
+	return (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests) &&
+		resp.Header.Get("X-RateLimit-Remaining") == "0"
+}
+
+// RoundTrip implements http.RoundTripper.
+func (p *tokenPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	resource := requestResource(req)
+	tried := make(map[int]bool, len(p.tokens))
+	for {
+		i := p.pick(resource, tried)
+		if i < 0 {
+			// No token at all: go out anonymously rather than fail.
+			return p.base.RoundTrip(req)
+		}
+		tried[i] = true
+		r := req.Clone(req.Context())
+		r.Header.Set("Authorization", "Bearer "+p.tokens[i].token)
+		if len(tried) > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r.Body = body
+		}
+		resp, err := p.base.RoundTrip(r)
+		if err != nil {
+			return nil, err
+		}
+		p.observe(i, resp.Header)
+		replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+		if exhausted(resp) && replayable && len(tried) < len(p.tokens) {
+			if p.hasQuota(p.pick(resource, tried), resource) {
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+				continue
+			}
+		}
+		p.present(resource, resp.Header)
+		return resp, nil
+	}
+}
+
+// gitToken returns the token with the most core quota left, for git transfers,
+// or "" when the pool is empty.
+func (p *tokenPool) gitToken() string {
+	i := p.pick("core", nil)
+	if i < 0 {
+		return ""
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.tokens[i].token
+}
+
+// TokenQuota is the last rate-limit state GitHub reported for one pooled
+// token. Resources is empty until the token has been used.
+type TokenQuota struct {
+	Host      string          `json:"host"`
+	Token     string          `json:"token"` // masked
+	Resources []ResourceQuota `json:"resources"`
+}
+
+// ResourceQuota is a token's quota for one rate-limit resource.
+type ResourceQuota struct {
+	Resource  string    `json:"resource"`
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+// quotas snapshots the pool for the API.
+func (p *tokenPool) quotas(host string) []TokenQuota {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	out := make([]TokenQuota, 0, len(p.tokens))
+	for _, t := range p.tokens {
+		q := TokenQuota{Host: host, Token: maskToken(t.token), Resources: []ResourceQuota{}}
+		for resource, s := range t.quota {
+			rq := ResourceQuota{Resource: resource, Limit: s.limit, Remaining: s.remaining, Reset: s.reset}
+			if !now.Before(s.reset) {
+				rq.Remaining = s.limit // the window has reset since
+			}
+			q.Resources = append(q.Resources, rq)
+		}
+		sort.Slice(q.Resources, func(a, b int) bool { return q.Resources[a].Resource < q.Resources[b].Resource })
+		out = append(out, q)
+	}
+	return out
+}
+
+// maskToken keeps just enough of a token to tell pool members apart.
+func maskToken(t string) string {
+	if len(t) <= 8 {
+		return "****"
+	}
+	return t[:4] + "…" + t[len(t)-4:]
+}
@@ -0,0 +1,131 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v56/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wnarutou/gitrieve/internal/config"
+)
+
+// quotaServer answers every request with the quota left on the caller's token,
+// rejecting it with 403 once that token is dry.
+type quotaServer struct {
+	mu    sync.Mutex
+	quota map[string]int
+	seen  []string
+	reset time.Time
+}
+
+func (s *quotaServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	s.seen = append(s.seen, token)
+	w.Header().Set("X-RateLimit-Resource", "core")
+	w.Header().Set("X-RateLimit-Limit", "5000")
+	w.Header().Set("X-RateLimit-Reset", fmt.Sprint(s.reset.Unix()))
+	if s.quota[token] == 0 {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"message":"API rate limit exceeded"}`)
+		return
+	}
+	s.quota[token]--
+	w.Header().Set("X-RateLimit-Remaining", fmt.Sprint(s.quota[token]))
+	fmt.Fprint(w, `{"login":"octocat"}`)
+}
+
+func newPoolClient(t *testing.T, srv *httptest.Server, p *tokenPool) *github.Client {
+	t.Helper()
+	c := github.NewClient(&http.Client{Transport: p})
+	base, err := url.Parse(srv.URL + "/")
+	require.NoError(t, err)
+	c.BaseURL = base
+	return c
+}
+
+func TestTokenPoolSwitchesInsteadOfWaiting(t *testing.T) {
+	qs := &quotaServer{quota: map[string]int{"token-aaaa-1": 1, "token-bbbb-2": 3}, reset: time.Now().Add(time.Hour)}
+	srv := httptest.NewServer(qs)
+	defer srv.Close()
+	p := newTokenPool([]string{"token-aaaa-1", "token-bbbb-2"})
+	c := newPoolClient(t, srv, p)
+	ctx := context.Background()
+
+	// The first request drains token A. go-github would refuse further
+	// requests client-side on a "remaining: 0" response; the pool must
+	// present B's quota instead.
+	_, _, err := c.Users.Get(ctx, "octocat")
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, _, err = c.Users.Get(ctx, "octocat")
+		require.NoError(t, err, "request %d", i)
+	}
+	assert.Equal(t, []string{"token-aaaa-1", "token-bbbb-2", "token-bbbb-2", "token-bbbb-2"}, qs.seen)
+
+	// Every token is dry now: the rate-limit error surfaces for retry.Do to
+	// wait on.
+	_, _, err = c.Users.Get(ctx, "octocat")
+	var rateErr *github.RateLimitError
+	require.ErrorAs(t, err, &rateErr)
+
+	quotas := p.quotas("github.com")
+	require.Len(t, quotas, 2)
+	assert.Equal(t, "toke…aa-1", quotas[0].Token)
+	require.Len(t, quotas[0].Resources, 1)
+	assert.Equal(t, ResourceQuota{Resource: "core", Limit: 5000, Remaining: 0, Reset: time.Unix(qs.reset.Unix(), 0)}, quotas[0].Resources[0])
+}
+
+func TestTokenPoolReplaysRejectedRequest(t *testing.T) {
+	// A is dry but the pool does not know yet: the 403 is replayed with B.
+	qs := &quotaServer{quota: map[string]int{"token-aaaa-1": 0, "token-bbbb-2": 5}, reset: time.Now().Add(time.Hour)}
+	srv := httptest.NewServer(qs)
+	defer srv.Close()
+	p := newTokenPool([]string{"token-aaaa-1", "token-bbbb-2"})
+	c := newPoolClient(t, srv, p)
+
+	u, _, err := c.Users.Get(context.Background(), "octocat")
+	require.NoError(t, err)
+	assert.Equal(t, "octocat", u.GetLogin())
+	assert.Equal(t, []string{"token-aaaa-1", "token-bbbb-2"}, qs.seen)
+	assert.Equal(t, "token-bbbb-2", p.gitToken(), "git transfers use the token with quota left")
+}
+
+func TestNewTokenPoolDropsEmptyAndDuplicates(t *testing.T) {
+	p := newTokenPool([]string{"", "a", "b", "a"})
+	require.Len(t, p.tokens, 2)
+	assert.Equal(t, "a", p.tokens[0].token)
+	assert.Equal(t, "b", p.tokens[1].token)
+}
+
+func TestEmptyTokenListIsAnonymous(t *testing.T) {
+	// githubTokens: [""], e.g. from an unset environment variable.
+	for _, cfg := range []*config.Config{
+		{GitHubTokens: []string{""}},
+		{GitHubToken: "", GitHubTokens: []string{"", ""}},
+	} {
+		ep, err := newEndpoint(cfg, "github.com")
+		require.NoError(t, err)
+		assert.Nil(t, ep.pool)
+	}
+
+	// An empty pool never panics: requests go out without a token.
+	qs := &quotaServer{quota: map[string]int{"": 1}, reset: time.Now().Add(time.Hour)}
+	srv := httptest.NewServer(qs)
+	defer srv.Close()
+	p := newTokenPool([]string{""})
+	_, _, err := newPoolClient(t, srv, p).Users.Get(context.Background(), "octocat")
+	require.NoError(t, err)
+	assert.Equal(t, []string{""}, qs.seen)
+	assert.Equal(t, "", p.gitToken())
+}
@@ -0,0 +1,50 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/google/go-github/v56/github"
+	"github.com/google/go-querystring/query"
+)
+
+// ListRaw GETs one page of a REST list endpoint (u is relative to the API
+// root) and returns its elements undecoded, exactly as GitHub sent them. opt
+// is encoded the way go-github encodes list options.
+func ListRaw(ctx context.Context, client *github.Client, u string, opt interface{}) ([]json.RawMessage, *github.Response, error) {
+	if opt != nil {
+		v, err := query.Values(opt)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(v) > 0 {
+			u += "?" + v.Encode()
+		}
+	}
+	req, err := client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	var raw []json.RawMessage
+	resp, err := client.Do(ctx, req, &raw)
+	if err != nil {
+		return nil, resp, err
+	}
+	return raw, resp, nil
+}
+
+// GetMedia GETs u with the given media type (e.g. application/vnd.github.diff)
+// and returns the response body.
+func GetMedia(ctx context.Context, client *github.Client, u, mediaType string) ([]byte, error) {
+	req, err := client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", mediaType)
+	var buf bytes.Buffer
+	if _, err := client.Do(ctx, req, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
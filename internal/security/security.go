@@ -0,0 +1,314 @@
+package security
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	gh "github.com/google/go-github/v56/github"
+	"github.com/wnarutou/gitrieve/internal/config"
+	"github.com/wnarutou/gitrieve/internal/lock"
+	"github.com/wnarutou/gitrieve/internal/retry"
+	"github.com/wnarutou/gitrieve/internal/scm"
+	"github.com/wnarutou/gitrieve/internal/scm/github"
+	"github.com/wnarutou/gitrieve/internal/storage"
+	"github.com/wnarutou/gitrieve/internal/typedef"
+	"github.com/wnarutou/gitrieve/internal/ui"
+)
+
+// Version is the schema version of the state file. A state with another
+// version is ignored, which costs one full sync.
+const Version = 1
+
+const (
+	stateFile   = "state.json"
+	historyFile = "history.json"
+)
+
+// kind is one list of security records: where GitHub serves it, the
+// directory its records are stored in and how a record is identified.
+type kind struct {
+	Name     string
+	Endpoint string // relative to repos/<owner>/<name>/
+	ID       func(record) string
+}
+
+var kinds = []kind{
+	{Name: "advisories", Endpoint: "security-advisories", ID: func(r record) string { return r.GHSAID }},
+	{Name: "code-scanning", Endpoint: "code-scanning/alerts", ID: func(r record) string { return fmt.Sprint(r.Number) }},
+	{Name: "dependabot", Endpoint: "dependabot/alerts", ID: func(r record) string { return fmt.Sprint(r.Number) }},
+}
+
+// record holds the fields of an advisory or alert the sync itself needs; it
+// is stored as GitHub sent it.
+type record struct {
+	GHSAID    string    `json:"ghsa_id"`
+	Number    int       `json:"number"`
+	State     string    `json:"state"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// State is the sync state of a repository's security records, per kind.
+type State struct {
+	Version int                   `json:"version"`
+	Kinds   map[string]*KindState `json:"kinds"`
+}
+
+// KindState is the sync state of one kind of security records.
+type KindState struct {
+	// Cursor is the newest updated_at among the synced records.
+	Cursor time.Time `json:"cursor"`
+	// States is the last seen state of each record by ID.
+	States map[string]string `json:"states"`
+}
+
+// Change is one element of history.json: a record first seen, or seen in a
+// new state. From is empty for a record first seen.
+type Change struct {
+	Time time.Time `json:"time"` // the record's updated_at
+	Kind string    `json:"kind"`
+	ID   string    `json:"id"`
+	From string    `json:"from"`
+	To   string    `json:"to"`
+}
+
+type listOptions struct {
+	Sort      string `url:"sort,omitempty"`
+	Direction string `url:"direction,omitempty"`
+	PerPage   int    `url:"per_page,omitempty"`
+	Page      int    `url:"page,omitempty"`
+	After     string `url:"after,omitempty"`
+}
+
+// Sync archives a repository's security advisories, published and draft, and
+// its code scanning and Dependabot alerts under security/: one
+// <kind>/<id>.json per record, exactly as GitHub sent it, and history.json,
+// which logs every state a record was seen in. Kinds the token may not read,
+// or that are disabled for the repository, are skipped. Only records updated
+// since the last sync are fetched, newest first.
+func Sync(ctx context.Context, repo typedef.Repository, storages []typedef.MultiStorage) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	r, err := scm.NewRepository(repo.URL)
+	if err != nil {
+		return err
+	}
+
+	// Serialize concurrent syncs of the same repo's security records: they
+	// read-modify-write the same state and history files.
+	unlock, err := lock.Acquire(ctx, r, "security")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	client, err := github.NewRESTClient(r.Host)
+	if err != nil {
+		ui.Errorf("Error creating github client, %s", err)
+		return err
+	}
+
+	// Each storage keeps its own state, so one added since the last sync is
+	// filled from scratch; storages in the same state share one fetch.
+	targets, err := load(r, storages)
+	if err != nil {
+		ui.Errorf("Error loading security sync state: %s", err)
+		return err
+	}
+	for _, t := range targets {
+		if err := syncTarget(ctx, client, r, repo, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// target is a group of storages holding the same sync state and history.
+type target struct {
+	storages []typedef.MultiStorage
+	state    *State
+	history  []Change
+}
+
+// syncTarget brings the storages of t up to date from t's state.
+func syncTarget(ctx context.Context, client *gh.Client, r *scm.Repository, repo typedef.Repository, t *target) error {
+	state, history := t.state, t.history
+	isUpdated := false
+	for _, k := range kinds {
+		// Work on a copy, so a kind skipped halfway keeps its old state.
+		ks := &KindState{States: make(map[string]string)}
+		if prev := state.Kinds[k.Name]; prev != nil {
+			ks.Cursor = prev.Cursor
+			for id, st := range prev.States {
+				ks.States[id] = st
+			}
+		}
+		updated, changes, err := syncKind(ctx, client, r, k, ks, t.storages)
+		if github.Forbidden(err) || github.NotFound(err) {
+			ui.Printf("Skipping %s of %s: not readable with this token or not enabled", k.Name, repo.Name)
+			continue
+		}
+		if err != nil {
+			ui.Errorf("Error syncing %s: %s", k.Name, err)
+			return err
+		}
+		if updated {
+			isUpdated = true
+			state.Kinds[k.Name] = ks
+			history = append(history, changes...)
+		}
+	}
+	if !isUpdated {
+		ui.Printf("All is up to date, no need to restore")
+		return nil
+	}
+	// The state goes last: it must never be ahead of the records it describes.
+	if err := putJSON(t.storages, r, historyFile, history); err != nil {
+		return err
+	}
+	state.Version = Version
+	return putJSON(t.storages, r, stateFile, state)
+}
+
+// syncKind stores the records of kind k updated since ks.Cursor, advancing
+// ks, and returns whether there were any and the state changes among them.
+func syncKind(ctx context.Context, client *gh.Client, r *scm.Repository, k kind, ks *KindState, storages []typedef.MultiStorage) (bool, []Change, error) {
+	opt := &listOptions{Sort: "updated", Direction: "desc", PerPage: 100}
+	var changes []Change
+	updated := false
+	cursor := ks.Cursor
+	for {
+		var (
+			raws []json.RawMessage
+			resp *gh.Response
+		)
+		err := retry.Do(ctx, config.GetRetryConfig(), func() error {
+			var apiErr error
+			raws, resp, apiErr = github.ListRaw(ctx, client, fmt.Sprintf("repos/%v/%v/%v", r.Owner, r.Name, k.Endpoint), opt)
+			return apiErr
+		})
+		if err != nil {
+			return false, nil, err
+		}
+		for _, raw := range raws {
+			var rec record
+			if err := json.Unmarshal(raw, &rec); err != nil {
+				return false, nil, fmt.Errorf("decode %s: %w", k.Name, err)
+			}
+			if !rec.UpdatedAt.After(cursor) {
+				// Everything after this one is older still.
+				return updated, changes, nil
+			}
+			id := k.ID(rec)
+			if err := put(storages, r, fmt.Sprintf("%s/%s.json", k.Name, id), raw); err != nil {
+				return false, nil, err
+			}
+			if prev := ks.States[id]; prev != rec.State {
+				changes = append(changes, Change{Time: rec.UpdatedAt, Kind: k.Name, ID: id, From: prev, To: rec.State})
+				ks.States[id] = rec.State
+			}
+			if rec.UpdatedAt.After(ks.Cursor) {
+				ks.Cursor = rec.UpdatedAt
+			}
+			updated = true
+		}
+		if ctx.Err() != nil {
+			return false, nil, ctx.Err()
+		}
+		switch {
+		case resp.After != "":
+			opt.After = resp.After
+		case resp.NextPage != 0:
+			opt.Page = resp.NextPage
+		default:
+			return updated, changes, nil
+		}
+	}
+}
+
+// load reads the sync state and history of every storage and groups the
+// storages holding the same ones. A storage without a usable state gets an
+// empty one, keeping whatever history it has.
+func load(r *scm.Repository, storages []typedef.MultiStorage) ([]*target, error) {
+	var targets []*target
+	byKey := make(map[string]*target)
+	for _, s := range storages {
+		backend, err := storage.GetStorage(s)
+		if err != nil {
+			return nil, err
+		}
+		dir := path.Join(s.Path, r.Host, r.Owner, r.Name, "security")
+		stateData, err := getObject(backend, path.Join(dir, stateFile))
+		if err != nil {
+			return nil, err
+		}
+		historyData, err := getObject(backend, path.Join(dir, historyFile))
+		if err != nil {
+			return nil, err
+		}
+		state := State{}
+		if stateData != nil {
+			if err := json.Unmarshal(stateData, &state); err != nil || state.Version != Version {
+				ui.Printf("Ignoring security sync state in storage %s", s.Name)
+				state, stateData = State{}, nil
+			}
+		}
+		key := string(stateData) + "\x00" + string(historyData)
+		if t, ok := byKey[key]; ok {
+			t.storages = append(t.storages, s)
+			continue
+		}
+		var history []Change
+		if historyData != nil {
+			if err := json.Unmarshal(historyData, &history); err != nil {
+				return nil, fmt.Errorf("parse %s: %w", historyFile, err)
+			}
+		}
+		if state.Kinds == nil {
+			state.Kinds = make(map[string]*KindState)
+		}
+		t := &target{storages: []typedef.MultiStorage{s}, state: &state, history: history}
+		byKey[key] = t
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// getObject returns the content of identifier, or nil when there is none.
+func getObject(backend storage.Storage, identifier string) ([]byte, error) {
+	obj, err := backend.GetObject(identifier)
+	if errors.Is(err, storage.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return obj.Content, nil
+}
+
+// put stores data as filename, relative to the repository's security
+// directory, in every storage of storages.
+func put(storages []typedef.MultiStorage, r *scm.Repository, filename string, data []byte) error {
+	for _, s := range storages {
+		backend, err := storage.GetStorage(s)
+		if err != nil {
+			return err
+		}
+		if err := backend.PutObject(path.Join(s.Path, r.Host, r.Owner, r.Name, "security", filename), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putJSON stores v, indented, as filename in every storage of storages.
+func putJSON(storages []typedef.MultiStorage, r *scm.Repository, filename string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", filename, err)
+	}
+	return put(storages, r, filename, data)
+}
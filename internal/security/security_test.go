@@ -0,0 +1,139 @@
+package security
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wnarutou/gitrieve/internal/lock"
+	"github.com/wnarutou/gitrieve/internal/scm"
+	"github.com/wnarutou/gitrieve/internal/scm/github/githubtest"
+	"github.com/wnarutou/gitrieve/internal/storage"
+	"github.com/wnarutou/gitrieve/internal/typedef"
+)
+
+func TestSyncCancelledContextReturnsImmediately(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := Sync(ctx, typedef.Repository{URL: "github.com/test/repo"}, nil)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestSyncBlocksWhileLockHeld(t *testing.T) {
+	repo := typedef.Repository{URL: "github.com/test/repo", UseCache: true}
+	r, err := scm.NewRepository(repo.URL)
+	require.NoError(t, err)
+	release, err := lock.Acquire(context.Background(), r, "security")
+	require.NoError(t, err)
+	defer release()
+	t.Cleanup(func() { os.RemoveAll(".gitrieve") })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	err = Sync(ctx, repo, nil)
+	require.Equal(t, context.DeadlineExceeded, err, "security Sync must block on the held security lock")
+}
+
+func TestSyncStoresRecordsAndStateChanges(t *testing.T) {
+	alert := `[{"number":1,"state":"%s","updated_at":"%s"}]`
+	alertState, alertUpdated := "open", "2026-08-15T00:00:00Z"
+	var secondPages int
+	host := githubtest.Serve(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/repos/owner/repo/security-advisories":
+			require.Equal(t, "updated", r.URL.Query().Get("sort"))
+			require.Equal(t, "desc", r.URL.Query().Get("direction"))
+			if r.URL.Query().Get("after") == "c1" {
+				secondPages++
+				fmt.Fprint(w, `[{"ghsa_id":"GHSA-aaaa","state":"draft","updated_at":"2026-08-10T00:00:00Z"}]`)
+				return
+			}
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s/repos/owner/repo/security-advisories?after=c1>; rel="next"`, r.Host))
+			fmt.Fprint(w, `[{"ghsa_id":"GHSA-bbbb","state":"published","updated_at":"2026-08-17T00:00:00Z","summary":"RCE"}]`)
+		case "/repos/owner/repo/code-scanning/alerts":
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"message":"Resource not accessible by integration"}`)
+		case "/repos/owner/repo/dependabot/alerts":
+			fmt.Fprintf(w, alert, alertState, alertUpdated)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	dir := t.TempDir()
+	storages := []typedef.MultiStorage{{Storage: typedef.Storage{Name: "local", Type: storage.FileStorage, Path: dir}}}
+	repo := typedef.Repository{URL: host + "/owner/repo"}
+	base := path.Join(dir, host, "owner", "repo", "security")
+	readHistory := func() []Change {
+		data, err := os.ReadFile(path.Join(base, "history.json"))
+		require.NoError(t, err)
+		var history []Change
+		require.NoError(t, json.Unmarshal(data, &history))
+		return history
+	}
+
+	require.NoError(t, Sync(context.Background(), repo, storages))
+	data, err := os.ReadFile(path.Join(base, "advisories", "GHSA-bbbb.json"))
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"summary":"RCE"`, "records are stored as sent")
+	require.FileExists(t, path.Join(base, "advisories", "GHSA-aaaa.json"))
+	require.FileExists(t, path.Join(base, "dependabot", "1.json"))
+	require.NoDirExists(t, path.Join(base, "code-scanning"))
+	require.Len(t, readHistory(), 3)
+	require.Equal(t, 1, secondPages)
+
+	// Nothing was updated: the first record is already known, so the listing
+	// stops there.
+	require.NoError(t, Sync(context.Background(), repo, storages))
+	require.Equal(t, 1, secondPages)
+	require.Len(t, readHistory(), 3)
+
+	alertState, alertUpdated = "fixed", "2026-08-18T00:00:00Z"
+	require.NoError(t, Sync(context.Background(), repo, storages))
+	history := readHistory()
+	require.Len(t, history, 4)
+	require.Equal(t, Change{
+		Time: time.Date(2026, 8, 18, 0, 0, 0, 0, time.UTC), Kind: "dependabot", ID: "1", From: "open", To: "fixed",
+	}, history[3])
+}
+
+func TestSyncBackfillsAddedStorage(t *testing.T) {
+	var listings int
+	host := githubtest.Serve(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/repos/owner/repo/dependabot/alerts":
+			listings++
+			fmt.Fprint(w, `[{"number":1,"state":"open","updated_at":"2026-08-15T00:00:00Z"}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	first := typedef.MultiStorage{Storage: typedef.Storage{Name: "first", Type: storage.FileStorage, Path: t.TempDir()}}
+	added := typedef.MultiStorage{Storage: typedef.Storage{Name: "added", Type: storage.FileStorage, Path: t.TempDir()}}
+	repo := typedef.Repository{URL: host + "/owner/repo"}
+
+	require.NoError(t, Sync(context.Background(), repo, []typedef.MultiStorage{first}))
+	require.Equal(t, 1, listings)
+	require.NoError(t, Sync(context.Background(), repo, []typedef.MultiStorage{first, added}))
+	require.Equal(t, 3, listings, "the storages in different states are synced separately")
+
+	base := path.Join(added.Path, host, "owner", "repo", "security")
+	require.FileExists(t, path.Join(base, "dependabot", "1.json"), "a storage added later is filled from scratch")
+	data, err := os.ReadFile(path.Join(base, "history.json"))
+	require.NoError(t, err)
+	var history []Change
+	require.NoError(t, json.Unmarshal(data, &history))
+	require.Len(t, history, 1)
+
+	// Both now hold the same state and share one listing again.
+	require.NoError(t, Sync(context.Background(), repo, []typedef.MultiStorage{first, added}))
+	require.Equal(t, 4, listings)
+}
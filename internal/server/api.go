@@ -15,6 +15,7 @@ import (
 	"github.com/wnarutou/gitrieve/internal/config"
 	"github.com/wnarutou/gitrieve/internal/db"
 	"github.com/wnarutou/gitrieve/internal/executor"
+	"github.com/wnarutou/gitrieve/internal/scm/github"
 	"github.com/wnarutou/gitrieve/internal/typedef"
 )
 
@@ -107,7 +108,7 @@ func (a *API) GetJobs(c *gin.Context) {
 		limit = 20
 	}
 
-	const jobSelect = "SELECT id, job_name, repo_key, start_time, end_time, status, error_message FROM executions"
+	const jobSelect = "SELECT id, job_name, repo_key, start_time, end_time, status, error_message, skipped, no_wiki FROM executions"
 
 	// Build query
 	query := jobSelect + " WHERE 1=1"
@@ -158,7 +159,7 @@ func (a *API) GetJobs(c *gin.Context) {
 		var errorMessage *string
 
 		var repoKey string
-		err := rows.Scan(&job.ID, &job.Name, &repoKey, &startTime, &endTime, &job.Status, &errorMessage)
+		err := rows.Scan(&job.ID, &job.Name, &repoKey, &startTime, &endTime, &job.Status, &errorMessage, &job.Skipped, &job.NoWiki)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, Response{
 				Code:    500,
@@ -300,6 +301,7 @@ type runStats struct {
 	Total   int64
 	Success int64
 	Failed  int64
+	Skipped int64
 }
 
 // lookupStats 返回配置条目的运行统计。type=repo 直接取自身键；type=org/user
@@ -321,6 +323,7 @@ func lookupStats(stats map[string]runStats, repo typedef.Repository) runStats {
 			sum.Total += s.Total
 			sum.Success += s.Success
 			sum.Failed += s.Failed
+			sum.Skipped += s.Skipped
 			if s.LastRun != nil && (sum.LastRun == nil || s.LastRun.After(*sum.LastRun)) {
 				t := *s.LastRun
 				sum.LastRun = &t
@@ -359,7 +362,8 @@ func (a *API) GetRepositories(c *gin.Context) {
 		       start_time AS last_run,
 		       COUNT(*)        AS total,
 		       COALESCE(SUM(status = 'completed'), 0) AS success,
-		       COALESCE(SUM(status = 'failed'), 0)    AS failed
+		       COALESCE(SUM(status = 'failed'), 0)    AS failed,
+		       COALESCE(SUM(skipped), 0)              AS skipped
 		FROM executions
 		GROUP BY repo_key
 		HAVING start_time = MAX(start_time)`)
@@ -373,7 +377,7 @@ func (a *API) GetRepositories(c *gin.Context) {
 		var key string
 		var lastRun sql.NullTime
 		var a runStats
-		if err := rows.Scan(&key, &lastRun, &a.Total, &a.Success, &a.Failed); err != nil {
+		if err := rows.Scan(&key, &lastRun, &a.Total, &a.Success, &a.Failed, &a.Skipped); err != nil {
 			c.JSON(http.StatusInternalServerError, Response{Code: 500, Message: "Failed to scan repository stats: " + err.Error()})
 			return
 		}
@@ -416,7 +420,7 @@ func (a *API) GetRepositories(c *gin.Context) {
 	overviews := make([]RepositoryOverview, 0, end-start)
 	for _, repo := range filtered[start:end] {
 		// Serve the effective URL (type=user/org with empty URL and an orgName
-		// synthesizes https://github.com/<orgName>). The frontend keys rows off
+		// synthesizes https://<host>/<orgName>). The frontend keys rows off
 		// r.URL, so a raw config entry without `url` would otherwise come back
 		// with URL=="" and its row buttons would no-op / 404. repo is a loop copy,
 		// so this neither mutates nor persists the config.
@@ -429,6 +433,7 @@ func (a *API) GetRepositories(c *gin.Context) {
 			TotalRuns:   s.Total,
 			SuccessRuns: s.Success,
 			FailedRuns:  s.Failed,
+			SkippedRuns: s.Skipped,
 		})
 	}
 
@@ -468,6 +473,13 @@ func (a *API) CreateRepository(c *gin.Context) {
 		})
 		return
 	}
+	if err := config.ValidateRepository(repo); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "Invalid repository: " + err.Error(),
+		})
+		return
+	}
 
 	// 判重按身份键（URL），name 允许重复。
 	for _, existing := range a.config.Repository {
@@ -577,6 +589,13 @@ func (a *API) UpdateRepository(c *gin.Context) {
 		})
 		return
 	}
+	if err := config.ValidateRepository(updated); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Message: "Invalid repository: " + err.Error(),
+		})
+		return
+	}
 	for i, other := range a.config.Repository {
 		if i != idx && other.Key() == updated.Key() {
 			c.JSON(http.StatusConflict, Response{
@@ -815,3 +834,12 @@ func (a *API) DeleteStorage(c *gin.Context) {
 		Message: msg,
 	})
 }
+
+// GetGitHubQuota returns the last known rate-limit quota of every configured
+// GitHub token (githubToken, githubTokens and githubHosts tokens), masked.
+func (a *API) GetGitHubQuota(c *gin.Context) {
+	c.JSON(http.StatusOK, Response{
+		Code: 200,
+		Data: github.TokenQuotas(),
+	})
+}
@@ -8,6 +8,8 @@ package server
 
 import (
 	"net/http"
+	"os"
+	"testing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/wnarutou/gitrieve/internal/config"
@@ -17,6 +19,22 @@ import (
 	"github.com/wnarutou/gitrieve/internal/typedef"
 )
 
+// TestMain runs the package's tests from a scratch directory: the jobs they
+// start sync into ./.gitrieve and lock there, possibly after the test that
+// started them returned, and must not leave that behind in the source tree.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "gitrieve-server-test")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 // TestServer wraps a gin.Engine for testing.
 type TestServer struct {
 	router *gin.Engine
@@ -561,3 +561,81 @@ func TestGetRepositoriesOrgPrefixAggregation(t *testing.T) {
 	solo := byName["solo"]
 	assert.Equal(t, int64(1), solo.TotalRuns)
 }
+
+func TestGetRepositoriesCountsSkippedRuns(t *testing.T) {
+	testDB, err := db.Initialize(":memory:")
+	require.NoError(t, err)
+	defer testDB.Close()
+
+	cfg := &config.Config{
+		Repository: []typedef.Repository{
+			{Name: "repo-a", URL: "github.com/a/a"},
+		},
+	}
+
+	// Two completed runs, one of which skipped the code sync (refs unchanged).
+	now := time.Now()
+	testDB.Exec(`INSERT INTO executions (id, job_name, repo_key, start_time, end_time, status, error_message, skipped) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		"e1", "repo-a", "github.com/a/a", now.Add(-2*time.Minute), now.Add(-time.Minute), "completed", "", false)
+	testDB.Exec(`INSERT INTO executions (id, job_name, repo_key, start_time, end_time, status, error_message, skipped) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		"e2", "repo-a", "github.com/a/a", now, now.Add(time.Minute), "completed", "", true)
+
+	s := server.NewRepoTestServer(cfg, testDB)
+
+	req, _ := http.NewRequest("GET", "/api/repositories", nil)
+	resp := httptest.NewRecorder()
+	s.ServeHTTP(resp, req)
+	var response struct {
+		Code int `json:"code"`
+		Data struct {
+			Repositories []struct {
+				TotalRuns   int64 `json:"total_runs"`
+				SuccessRuns int64 `json:"success_runs"`
+				SkippedRuns int64 `json:"skipped_runs"`
+			} `json:"repositories"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &response))
+	require.Len(t, response.Data.Repositories, 1)
+	ra := response.Data.Repositories[0]
+	assert.Equal(t, int64(2), ra.TotalRuns)
+	assert.Equal(t, int64(2), ra.SuccessRuns, "a skipped run still completed")
+	assert.Equal(t, int64(1), ra.SkippedRuns)
+}
+
+func TestRepositoryOptionsValidated(t *testing.T) {
+	testDB, err := db.Initialize(":memory:")
+	require.NoError(t, err)
+	defer testDB.Close()
+
+	cfg := &config.Config{
+		Repository: []typedef.Repository{
+			{Name: "a", URL: "github.com/a/a"},
+		},
+	}
+	s := server.NewRepoTestServer(cfg, testDB)
+
+	// The checks of the config file apply to the API too: a negative run
+	// limit would crash the actions sync.
+	for _, body := range []map[string]interface{}{
+		{"name": "b", "url": "github.com/b/b", "Actions": map[string]interface{}{"RunLimit": -1}},
+		{"name": "b", "url": "github.com/b/b", "GitBackend": "svn"},
+		{"name": "b", "url": "github.com/b/b", "Release": map[string]interface{}{"Include": []string{"["}}},
+	} {
+		b, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", "/api/repositories", bytes.NewBuffer(b))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		s.ServeHTTP(resp, req)
+		assert.Equal(t, 400, resp.Code, "body %v", body)
+	}
+
+	b, _ := json.Marshal(map[string]interface{}{"IssueAPI": "soap"})
+	req, _ := http.NewRequest("PUT", "/api/repositories/github.com/a/a", bytes.NewBuffer(b))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	s.ServeHTTP(resp, req)
+	assert.Equal(t, 400, resp.Code)
+	assert.Equal(t, "", cfg.Repository[0].IssueAPI, "a rejected update leaves the entry alone")
+	assert.Len(t, cfg.Repository, 1)
+}
@@ -20,6 +20,8 @@ type Job struct {
 	StartTime    *time.Time `json:"start_time"`
 	EndTime      *time.Time `json:"end_time"`
 	ErrorMessage string     `json:"error_message"`
+	Skipped      bool       `json:"skipped"` // code sync skipped: remote refs unchanged
+	NoWiki       bool       `json:"no_wiki"` // wiki sync found no wiki to archive
 }
 
 type CreateJobRequest struct {
@@ -72,6 +74,7 @@ type RepositoryOverview struct {
 	TotalRuns   int64      `json:"total_runs"`
 	SuccessRuns int64      `json:"success_runs"`
 	FailedRuns  int64      `json:"failed_runs"`
+	SkippedRuns int64      `json:"skipped_runs"`
 }
 
 type ListRepositoriesResponse struct {
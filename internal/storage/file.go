@@ -2,6 +2,8 @@ package storage
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
@@ -92,7 +94,7 @@ func (f File) GetObject(identifier string) (Object, error) {
 	info, err := os.Stat(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return Object{}, errors.New("invalid identifier: file does not exist")
+			return Object{}, fmt.Errorf("invalid identifier: file %w", ErrNotExist)
 		}
 		return Object{}, err
 	}
@@ -115,6 +117,20 @@ func (f File) GetObject(identifier string) (Object, error) {
 	}, nil
 }
 
+func (f File) OpenObject(identifier string) (io.ReadCloser, error) {
+	if identifier == "" {
+		return nil, errors.New("invalid identifier: identifier cannot be empty")
+	}
+	file, err := os.Open(filepath.Clean(identifier))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("invalid identifier: file %w", ErrNotExist)
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
 func (f File) DeleteObject(identifier string) error {
 	if identifier == "" {
 		return errors.New("invalid identifier: identifier cannot be empty")
@@ -165,3 +181,21 @@ func (f File) PutObject(identifier string, data []byte) error {
 	}
 	return os.WriteFile(identifier, data, 0664)
 }
+
+func (f File) PutObjectFrom(identifier string, r io.Reader, size int64) error {
+	if err := CreateDirIfNotExist(path.Dir(identifier)); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(identifier, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0664)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(file, r)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && n != size {
+		err = fmt.Errorf("write %s: wrote %d bytes, want %d", identifier, n, size)
+	}
+	return err
+}
@@ -3,6 +3,10 @@ package storage
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
@@ -41,19 +45,97 @@ func New(endpoint, bucket, region, accessKeyID, secretAccessKey string) (*S3, er
 	return s3, nil
 }
 
+// ListObjectMetaInfo returns the object named prefix, or else the objects and
+// "directories" (common key prefixes) right under it, like File does.
 func (s S3) ListObjectMetaInfo(prefix string) ([]ObjectMetaInfo, error) {
-	// TODO implement me
-	panic("implement me")
+	if prefix == "" {
+		return nil, errors.New("invalid prefix: prefix cannot be empty")
+	}
+	ctx := context.Background()
+	info, err := s.client.StatObject(ctx, s.Bucket, prefix, minio.StatObjectOptions{})
+	if err == nil {
+		return []ObjectMetaInfo{{Path: prefix, Size: info.Size, LastModified: info.LastModified}}, nil
+	}
+	if minio.ToErrorResponse(err).Code != "NoSuchKey" {
+		return nil, err
+	}
+	var objects []ObjectMetaInfo
+	for obj := range s.client.ListObjects(ctx, s.Bucket, minio.ListObjectsOptions{Prefix: strings.TrimSuffix(prefix, "/") + "/"}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		objects = append(objects, ObjectMetaInfo{
+			Path:         strings.TrimSuffix(obj.Key, "/"),
+			Size:         obj.Size,
+			LastModified: obj.LastModified,
+		})
+	}
+	if len(objects) == 0 {
+		return nil, errors.New("invalid prefix: does not exist")
+	}
+	return objects, nil
 }
 
 func (s S3) ListObject(prefix string) ([]Object, error) {
-	// TODO implement me
-	panic("implement me")
+	metaInfos, err := s.ListObjectMetaInfo(prefix)
+	if err != nil {
+		return nil, err
+	}
+	var objects []Object
+	for _, meta := range metaInfos {
+		obj, err := s.GetObject(meta.Path)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
 }
 
 func (s S3) GetObject(identifier string) (Object, error) {
-	// TODO implement me
-	panic("implement me")
+	obj, err := s.client.GetObject(context.Background(), s.Bucket, identifier, minio.GetObjectOptions{})
+	if err != nil {
+		return Object{}, err
+	}
+	defer obj.Close()
+	// The request is only sent on the first read, so a missing key shows up
+	// here rather than from GetObject.
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return Object{}, fmt.Errorf("invalid identifier: object %w", ErrNotExist)
+		}
+		return Object{}, err
+	}
+	info, err := obj.Stat()
+	if err != nil {
+		return Object{}, err
+	}
+	return Object{
+		MetaInfo: ObjectMetaInfo{
+			Path:         identifier,
+			Size:         info.Size,
+			LastModified: info.LastModified,
+		},
+		Content: data,
+	}, nil
+}
+
+func (s S3) OpenObject(identifier string) (io.ReadCloser, error) {
+	obj, err := s.client.GetObject(context.Background(), s.Bucket, identifier, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// Stat sends the request, so a missing key shows up here rather than on
+	// the caller's first read.
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, fmt.Errorf("invalid identifier: object %w", ErrNotExist)
+		}
+		return nil, err
+	}
+	return obj, nil
 }
 
 func (s S3) PutObject(identifier string, data []byte) error {
@@ -66,7 +148,13 @@ func (s S3) PutObject(identifier string, data []byte) error {
 	return nil
 }
 
+func (s S3) PutObjectFrom(identifier string, r io.Reader, size int64) error {
+	_, err := s.client.PutObject(context.Background(), s.Bucket, identifier, r, size, minio.PutObjectOptions{})
+	return err
+}
+
+// DeleteObject deletes the object identifier. S3 has no directories, so
+// deleting one, or an object that does not exist, does nothing.
 func (s S3) DeleteObject(identifier string) error {
-	// TODO implement me
-	panic("implement me")
+	return s.client.RemoveObject(context.Background(), s.Bucket, identifier, minio.RemoveObjectOptions{})
 }
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3ListsAndDeletesObjects(t *testing.T) {
+	var deleted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodHead && r.URL.Path == "/bucket/release/v1/app.zip":
+			w.Header().Set("Content-Length", "3")
+			w.Header().Set("Last-Modified", "Mon, 17 Aug 2026 01:00:00 GMT")
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+			require.Equal(t, "release/", r.URL.Query().Get("prefix"))
+			require.Equal(t, "/", r.URL.Query().Get("delimiter"))
+			w.Header().Set("Content-Type", "application/xml")
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?>
+<ListBucketResult><Name>bucket</Name><Prefix>release/</Prefix><KeyCount>2</KeyCount><IsTruncated>false</IsTruncated>
+<Contents><Key>release/notes.md</Key><Size>5</Size><LastModified>2026-08-17T01:00:00.000Z</LastModified></Contents>
+<CommonPrefixes><Prefix>release/v1/</Prefix></CommonPrefixes></ListBucketResult>`)
+		case r.Method == http.MethodDelete:
+			deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/bucket/"))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	}))
+	defer srv.Close()
+	client, err := minio.New(strings.TrimPrefix(srv.URL, "http://"), &minio.Options{
+		Creds:  credentials.NewStaticV4("id", "secret", ""),
+		Region: "us-east-1",
+	})
+	require.NoError(t, err)
+	s := S3{Bucket: "bucket", client: client}
+
+	objects, err := s.ListObjectMetaInfo("release/v1/app.zip")
+	require.NoError(t, err)
+	require.Equal(t, []ObjectMetaInfo{{Path: "release/v1/app.zip", Size: 3, LastModified: objects[0].LastModified}}, objects)
+
+	objects, err = s.ListObjectMetaInfo("release")
+	require.NoError(t, err)
+	require.Len(t, objects, 2)
+	require.Equal(t, "release/notes.md", objects[0].Path)
+	require.Equal(t, int64(5), objects[0].Size)
+	require.Equal(t, "release/v1", objects[1].Path, "a common prefix is listed as a directory")
+
+	require.NoError(t, s.DeleteObject("release/v1/app.zip"))
+	require.Equal(t, []string{"release/v1/app.zip"}, deleted)
+}
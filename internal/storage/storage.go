@@ -2,6 +2,10 @@ package storage
 
 import (
 	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
 	"time"
 
 	"github.com/wnarutou/gitrieve/internal/typedef"
@@ -12,6 +16,10 @@ const (
 	S3Storage   = "s3"
 )
 
+// ErrNotExist is returned (wrapped) by GetObject when no object has the given
+// identifier.
+var ErrNotExist = errors.New("does not exist")
+
 type ObjectMetaInfo struct {
 	Path         string
 	Size         int64
@@ -31,6 +39,13 @@ type Storage interface {
 	GetObject(identifier string) (Object, error)
 	// PutObject stores the data in the storage backend identified by the given identifier.
 	PutObject(identifier string, data []byte) error
+	// OpenObject returns a reader over the object identified by the given
+	// identifier, for objects too large to read into memory. The caller must
+	// close it.
+	OpenObject(identifier string) (io.ReadCloser, error)
+	// PutObjectFrom stores size bytes read from r in the storage backend
+	// identified by the given identifier.
+	PutObjectFrom(identifier string, r io.Reader, size int64) error
 	// DeleteObject deletes the object identified by the given identifier.
 	DeleteObject(identifier string) error
 }
@@ -50,3 +65,17 @@ func GetStorage(storage typedef.MultiStorage) (Storage, error) {
 	}
 	return backend, err
 }
+
+// ObjectPath joins elem under the path of s. A file storage's relative path is
+// resolved against the current working directory.
+func ObjectPath(s typedef.MultiStorage, elem ...string) (string, error) {
+	base := s.Path
+	if s.Type == FileStorage && !filepath.IsAbs(s.Path) {
+		currentDir, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		base = path.Join(currentDir, s.Path)
+	}
+	return path.Join(append([]string{base}, elem...)...), nil
+}
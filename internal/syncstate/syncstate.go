@@ -0,0 +1,136 @@
+// Package syncstate persists the incremental sync state of the API-backed
+// components (issues, pull requests, discussions, projects): the cursor, the last reconciliation and
+// the schema version. It lives next to the component's archive, in the cache
+// and in every storage, so a run without a cache can resume from storage.
+package syncstate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/wnarutou/gitrieve/internal/archive"
+	"github.com/wnarutou/gitrieve/internal/scm"
+	"github.com/wnarutou/gitrieve/internal/storage"
+	"github.com/wnarutou/gitrieve/internal/typedef"
+	"github.com/wnarutou/gitrieve/internal/ui"
+)
+
+// Version is the schema version of the state file. A state with another
+// version is ignored, which costs one full sync.
+const Version = 1
+
+// State is the sync state of one component of one repository.
+type State struct {
+	Version int `json:"version"`
+	// Cursor is the newest updated_at among the synced items; zero means
+	// nothing was synced yet.
+	Cursor time.Time `json:"cursor"`
+	// Reconciled is when the last full pass for upstream deletions ran.
+	Reconciled time.Time `json:"reconciled"`
+}
+
+// Component names the files of one component: Name.state.json and
+// Name.tar.gz next to each other, the archive rooted at Dir.
+type Component struct {
+	Name string
+	Dir  string
+}
+
+var (
+	Issues      = Component{Name: "issues", Dir: "issues"}
+	Pulls       = Component{Name: "pulls", Dir: "pulls"}
+	Discussions = Component{Name: "discussions", Dir: "discussion"}
+	Projects    = Component{Name: "projects", Dir: "projects"}
+)
+
+func (c Component) stateFile() string   { return c.Name + ".state.json" }
+func (c Component) archiveFile() string { return c.Name + ".tar.gz" }
+
+// Load returns the state of component c of r, and whether there was one. The
+// copy in repoDir (the repository's cache dir) wins. Without one, e.g. with
+// useCache: false, it is pulled from the first storage that has it, along with
+// the archive it describes, which is extracted into workDir: the archive
+// uploaded at the end of an incremental sync must still hold every item.
+func Load(ctx context.Context, r *scm.Repository, c Component, repoDir, workDir string, storages []typedef.MultiStorage) (State, bool, error) {
+	data, err := os.ReadFile(path.Join(repoDir, c.stateFile()))
+	if err == nil {
+		st, err := decode(data)
+		if err != nil {
+			return State{}, false, fmt.Errorf("parse %s: %w", c.stateFile(), err)
+		}
+		return st, st.Version == Version, nil
+	}
+	if !os.IsNotExist(err) {
+		return State{}, false, err
+	}
+
+	for _, s := range storages {
+		backend, err := storage.GetStorage(s)
+		if err != nil {
+			return State{}, false, err
+		}
+		dir := path.Join(s.Path, r.Host, r.Owner, r.Name)
+		obj, err := backend.GetObject(path.Join(dir, c.stateFile()))
+		if errors.Is(err, storage.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return State{}, false, err
+		}
+		st, err := decode(obj.Content)
+		if err != nil || st.Version != Version {
+			ui.Printf("Ignoring sync state %s in storage %s", c.stateFile(), s.Name)
+			continue
+		}
+		obj, err = backend.GetObject(path.Join(dir, c.archiveFile()))
+		if errors.Is(err, storage.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return State{}, false, err
+		}
+		if err := archive.Extract(ctx, obj.Content, c.Dir, workDir); err != nil {
+			return State{}, false, err
+		}
+		ui.Printf("Restored %s and its sync state from storage %s", c.archiveFile(), s.Name)
+		return st, true, nil
+	}
+	return State{}, false, nil
+}
+
+func decode(data []byte) (State, error) {
+	var st State
+	err := json.Unmarshal(data, &st)
+	return st, err
+}
+
+// Save writes st to repoDir, unless it is empty (no cache), and to every
+// storage. Call it only after the archive was stored: the state must never be
+// ahead of the archive it describes.
+func Save(r *scm.Repository, c Component, repoDir string, st State, storages []typedef.MultiStorage) error {
+	st.Version = Version
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	if repoDir != "" {
+		if err := os.WriteFile(path.Join(repoDir, c.stateFile()), data, 0644); err != nil {
+			return err
+		}
+	}
+	for _, s := range storages {
+		backend, err := storage.GetStorage(s)
+		if err != nil {
+			return err
+		}
+		if err := backend.PutObject(path.Join(s.Path, r.Host, r.Owner, r.Name, c.stateFile()), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
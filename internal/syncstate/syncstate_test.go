@@ -0,0 +1,80 @@
+package syncstate
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wnarutou/gitrieve/internal/archive"
+	"github.com/wnarutou/gitrieve/internal/scm"
+	"github.com/wnarutou/gitrieve/internal/typedef"
+)
+
+func fileStorage(t *testing.T) typedef.MultiStorage {
+	return typedef.MultiStorage{Storage: typedef.Storage{Name: "local", Type: "file", Path: t.TempDir()}}
+}
+
+func TestLoadWithoutStateStartsFresh(t *testing.T) {
+	r, err := scm.NewRepository("github.com/owner/repo")
+	require.NoError(t, err)
+	st, ok, err := Load(context.Background(), r, Issues, t.TempDir(), t.TempDir(), []typedef.MultiStorage{fileStorage(t)})
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.True(t, st.Cursor.IsZero())
+}
+
+func TestLoadPrefersCacheOverStorage(t *testing.T) {
+	r, err := scm.NewRepository("github.com/owner/repo")
+	require.NoError(t, err)
+	store := fileStorage(t)
+	repoDir := t.TempDir()
+	cursor := time.Date(2026, 8, 17, 1, 0, 0, 0, time.UTC)
+	require.NoError(t, Save(r, Issues, repoDir, State{Cursor: cursor}, []typedef.MultiStorage{store}))
+	require.NoError(t, Save(r, Issues, "", State{Cursor: cursor.Add(time.Hour)}, []typedef.MultiStorage{store}))
+
+	st, ok, err := Load(context.Background(), r, Issues, repoDir, t.TempDir(), []typedef.MultiStorage{store})
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, cursor, st.Cursor)
+	assert.Equal(t, Version, st.Version)
+}
+
+func TestLoadRestoresArchiveFromStorage(t *testing.T) {
+	r, err := scm.NewRepository("github.com/owner/repo")
+	require.NoError(t, err)
+	store := fileStorage(t)
+
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(path.Join(src, "1.md"), []byte("one"), 0644))
+	buf, err := archive.Create(context.Background(), src, Discussions.Dir)
+	require.NoError(t, err)
+	stored := path.Join(store.Path, r.Host, r.Owner, r.Name)
+	require.NoError(t, os.MkdirAll(stored, 0755))
+	require.NoError(t, os.WriteFile(path.Join(stored, "discussions.tar.gz"), buf.Bytes(), 0644))
+	cursor := time.Date(2026, 8, 17, 1, 0, 0, 0, time.UTC)
+	require.NoError(t, Save(r, Discussions, "", State{Cursor: cursor}, []typedef.MultiStorage{store}))
+
+	workDir := t.TempDir()
+	st, ok, err := Load(context.Background(), r, Discussions, t.TempDir(), workDir, []typedef.MultiStorage{store})
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, cursor, st.Cursor)
+	data, err := os.ReadFile(path.Join(workDir, "1.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "one", string(data))
+}
+
+func TestLoadIgnoresOtherSchemaVersions(t *testing.T) {
+	r, err := scm.NewRepository("github.com/owner/repo")
+	require.NoError(t, err)
+	repoDir := t.TempDir()
+	require.NoError(t, os.WriteFile(path.Join(repoDir, "issues.state.json"), []byte(`{"version":99,"cursor":"2026-08-17T01:00:00Z"}`), 0644))
+
+	_, ok, err := Load(context.Background(), r, Issues, repoDir, t.TempDir(), nil)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,30 @@
+package typedef
+
+import "time"
+
+// DefaultActionsRunLimit is how many runs per workflow are archived when the
+// actions policy sets neither runLimit nor days.
+const DefaultActionsRunLimit = 10
+
+// ActionsPolicy chooses which workflow runs of a repository are archived.
+type ActionsPolicy struct {
+	RunLimit int `yaml:"runLimit"` // latest completed runs to archive per workflow (default: 10)
+	Days     int `yaml:"days"`     // archive the runs of the last N days per workflow instead of the latest runLimit
+}
+
+// GetRunLimit returns how many of a workflow's latest runs are archived.
+func (p ActionsPolicy) GetRunLimit() int {
+	if p.RunLimit == 0 {
+		return DefaultActionsRunLimit
+	}
+	return p.RunLimit
+}
+
+// Since returns the first day whose runs are archived as YYYY-MM-DD, or an
+// empty string when runs are chosen by count.
+func (p ActionsPolicy) Since(now time.Time) string {
+	if p.Days <= 0 {
+		return ""
+	}
+	return now.UTC().AddDate(0, 0, -p.Days).Format("2006-01-02")
+}
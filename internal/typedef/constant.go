@@ -5,3 +5,22 @@ const (
 	TypeUser = "user"
 	TypeOrg  = "org"
 )
+
+const (
+	GitBackendGoGit = "go-git"
+	GitBackendCLI   = "cli"
+)
+
+const (
+	IssueAPIREST    = "rest"
+	IssueAPIGraphQL = "graphql"
+)
+
+const (
+	ReleaseRetentionRetire = "retire"
+	ReleaseRetentionKeep   = "keep"
+	ReleaseRetentionPrune  = "prune"
+)
+
+// DefaultGitHubHost is the host of user/org entries that do not name one.
+const DefaultGitHubHost = "github.com"
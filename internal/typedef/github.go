@@ -0,0 +1,29 @@
+package typedef
+
+// GitHubApp identifies a GitHub App installation that gitrieve authenticates
+// as instead of a personal githubToken. The private key is given either inline
+// (PEM) or as a path to the PEM file downloaded from the App's settings.
+type GitHubApp struct {
+	AppID          int64  `yaml:"appID"`
+	InstallationID int64  `yaml:"installationID"`
+	PrivateKey     string `yaml:"privateKey"`
+	PrivateKeyPath string `yaml:"privateKeyPath"`
+}
+
+// Configured reports whether an App is set up at all.
+func (a GitHubApp) Configured() bool {
+	return a.AppID != 0
+}
+
+// GitHubHost describes a GitHub Enterprise Server instance, or overrides the
+// endpoints of github.com. Repositories whose URL host equals Host talk to
+// these endpoints with Token. Empty URLs take the GHES defaults derived from
+// Host: https://<host>/api/v3/, https://<host>/api/uploads/ and
+// https://<host>/api/graphql.
+type GitHubHost struct {
+	Host       string `yaml:"host"`
+	APIURL     string `yaml:"apiURL"`
+	UploadURL  string `yaml:"uploadURL"`
+	GraphQLURL string `yaml:"graphqlURL"`
+	Token      string `yaml:"token"`
+}
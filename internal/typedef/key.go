@@ -25,12 +25,12 @@ func NormalizeURL(raw string) string {
 }
 
 // EffectiveURL 返回条目的有效 URL：URL 非空直接用；type 为 user/org 且 URL 为
-// 空、orgName 非空时合成 "https://github.com/<orgName>"；否则返回 r.URL（可能
-// 为空，即非法）。
+// 空、orgName 非空时合成 "https://<host>/<orgName>"（host 默认 github.com，
+// GHES 条目设置 host）；否则返回 r.URL（可能为空，即非法）。
 func (r *Repository) EffectiveURL() string {
 	if (r.GetType() == TypeUser || r.GetType() == TypeOrg) &&
 		strings.TrimSpace(r.URL) == "" && r.OrgName != "" {
-		return "https://github.com/" + r.OrgName
+		return "https://" + r.GetHost() + "/" + r.OrgName
 	}
 	return r.URL
 }
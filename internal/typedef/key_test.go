@@ -35,6 +35,8 @@ func TestEffectiveURL(t *testing.T) {
 		{Repository{Type: TypeRepo, URL: "github.com/a/b"}, "github.com/a/b"},
 		{Repository{Type: TypeOrg, OrgName: "acme"}, "https://github.com/acme"},
 		{Repository{Type: TypeUser, OrgName: "alice"}, "https://github.com/alice"},
+		// GHES 条目按 host 合成
+		{Repository{Type: TypeOrg, OrgName: "acme", Host: "github.corp.example"}, "https://github.corp.example/acme"},
 		// 显式 URL 优先于合成
 		{Repository{Type: TypeOrg, URL: "gitlab.com/acme/org", OrgName: "acme"}, "gitlab.com/acme/org"},
 		// orgName 为空 → 无有效 URL
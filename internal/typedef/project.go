@@ -0,0 +1,7 @@
+package typedef
+
+// ProjectsPolicy chooses which Projects v2 boards of a repository are
+// archived besides those linked to it.
+type ProjectsPolicy struct {
+	IncludeOwner bool `yaml:"includeOwner"` // also archive every board of the owning organization or user (default: false)
+}
@@ -0,0 +1,78 @@
+package typedef
+
+import (
+	"path"
+	"time"
+)
+
+// ReleasePolicy narrows which releases and assets of a repository are
+// downloaded. The zero value defers to the global releaseNumLimit and
+// releaseSizeLimit and keeps every release and asset.
+type ReleasePolicy struct {
+	NumLimit        int      `yaml:"numLimit"`        // max releases to keep (default: releaseNumLimit; negative means no limit)
+	SizeLimit       int      `yaml:"sizeLimit"`       // max total release size in bytes (default: releaseSizeLimit; negative means no limit)
+	Include         []string `yaml:"include"`         // asset name globs to download (default: every asset)
+	Exclude         []string `yaml:"exclude"`         // asset name globs to skip, even when included
+	SkipPrereleases bool     `yaml:"skipPrereleases"` // leave out prereleases (default: false)
+	SkipDrafts      bool     `yaml:"skipDrafts"`      // leave out draft releases (default: false)
+	NewerThan       string   `yaml:"newerThan"`       // only releases published after this date, e.g. 2024-01-31 or an RFC 3339 time
+	Retention       string   `yaml:"retention"`       // retire, keep, prune: what happens to stored releases outside the kept window (default: retire)
+}
+
+// GetRetention returns what happens to stored releases that are no longer
+// kept, defaulting to moving them under release/_retired/. Deleting them
+// (prune) or leaving them in place forever (keep) must be chosen explicitly.
+func (p ReleasePolicy) GetRetention() string {
+	if p.Retention == "" {
+		return ReleaseRetentionRetire
+	}
+	return p.Retention
+}
+
+// GetNumLimit returns the repository's release count limit, or global when the
+// policy does not set one.
+func (p ReleasePolicy) GetNumLimit(global int) int {
+	if p.NumLimit == 0 {
+		return global
+	}
+	return p.NumLimit
+}
+
+// GetSizeLimit returns the repository's release size limit, or global when the
+// policy does not set one.
+func (p ReleasePolicy) GetSizeLimit(global int) int {
+	if p.SizeLimit == 0 {
+		return global
+	}
+	return p.SizeLimit
+}
+
+// Cutoff parses NewerThan, returning the zero time when it is unset.
+func (p ReleasePolicy) Cutoff() (time.Time, error) {
+	if p.NewerThan == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", p.NewerThan); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, p.NewerThan)
+}
+
+// WantsAsset reports whether an asset called name matches Include (when set)
+// and none of Exclude. Malformed globs match nothing; config validation
+// rejects them up front.
+func (p ReleasePolicy) WantsAsset(name string) bool {
+	if len(p.Include) > 0 && !matchAny(p.Include, name) {
+		return false
+	}
+	return !matchAny(p.Exclude, name)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -1,19 +1,47 @@
 package typedef
 
 type Repository struct {
-	Name               string   `yaml:"name"`
-	URL                string   `yaml:"url"`
-	Cron               string   `yaml:"cron"`
-	Storage            []string `yaml:"storage"`
-	UseCache           bool     `yaml:"useCache"`
-	Type               string   `yaml:"type"` // repo, user, org (default: repo)
-	OrgName            string   `yaml:"orgName"`
-	AllBranches        bool     `yaml:"allBranches"`        // pull all branches or not (default: false)
-	Depth              int      `yaml:"depth"`              // pull depth: 0, 1, ... (default: 0, means all commit logs)
-	DownloadReleases   bool     `yaml:"downloadReleases"`   // download releases or not (default: false)
-	DownloadIssues     bool     `yaml:"downloadIssues"`     // download issues or not (default: false)
-	DownloadWiki       bool     `yaml:"downloadWiki"`       // download wiki or not (default: false)
-	DownloadDiscussion bool     `yaml:"downloadDiscussion"` // download discussion or not (default: false)
+	Name                   string   `yaml:"name"`
+	URL                    string   `yaml:"url"`
+	Cron                   string   `yaml:"cron"`
+	Storage                []string `yaml:"storage"`
+	UseCache               bool     `yaml:"useCache"`
+	Type                   string   `yaml:"type"` // repo, user, org (default: repo)
+	OrgName                string   `yaml:"orgName"`
+	Host                   string   `yaml:"host"`                   // host of a user/org entry without url (default: github.com)
+	AllBranches            bool     `yaml:"allBranches"`            // pull all branches or not (default: false)
+	Depth                  int      `yaml:"depth"`                  // pull depth: 0, 1, ... (default: 0, means all commit logs)
+	DownloadReleases       bool     `yaml:"downloadReleases"`       // download releases or not (default: false)
+	DownloadSourceArchives bool     `yaml:"downloadSourceArchives"` // download the source zip and tarball of each release or not (default: false)
+	DownloadIssues         bool     `yaml:"downloadIssues"`         // download issues or not (default: false)
+	DownloadPullRequests   bool     `yaml:"downloadPullRequests"`   // download pull request reviews, commits and diffs or not (default: false)
+	DownloadWiki           bool     `yaml:"downloadWiki"`           // download wiki or not (default: false)
+	DownloadDiscussion     bool     `yaml:"downloadDiscussion"`     // download discussion or not (default: false)
+	DownloadActions        bool     `yaml:"downloadActions"`        // download workflow runs, logs and artifacts or not (default: false)
+	DownloadMetadata       bool     `yaml:"downloadMetadata"`       // snapshot repository settings, labels, milestones and branch protection or not (default: false)
+	DownloadProjects       bool     `yaml:"downloadProjects"`       // download the Projects v2 boards linked to the repository or not (default: false)
+	DownloadSecurity       bool     `yaml:"downloadSecurity"`       // download security advisories and code scanning and Dependabot alerts or not (default: false)
+	GitBackend             string   `yaml:"gitBackend"`             // go-git, cli (default: go-git)
+	ForkOf                 string   `yaml:"forkOf"`                 // URL of the fork network root to share objects with (cli backend only)
+	DetectForks            bool     `yaml:"detectForks"`            // look up the fork network root via the GitHub API (default: false)
+	RewriteAttachmentLinks bool     `yaml:"rewriteAttachmentLinks"` // point attachment links in issue/discussion markdown at the downloaded copies (default: false)
+	IssueAPI               string   `yaml:"issueAPI"`               // rest, graphql: the API issues are synced through (default: rest)
+
+	// Release narrows which releases and assets are downloaded (default: the
+	// global limits, every release and asset).
+	Release ReleasePolicy `yaml:"release"`
+
+	// Actions chooses which workflow runs are archived (default: the latest
+	// 10 per workflow).
+	Actions ActionsPolicy `yaml:"actions"`
+
+	// Projects widens which Projects v2 boards are archived (default: those
+	// linked to the repository).
+	Projects ProjectsPolicy `yaml:"projects"`
+
+	// Wiki adds a rendering of the wiki to its archive (default: the git
+	// repository only).
+	Wiki WikiPolicy `yaml:"wiki"`
 }
 
 func (r *Repository) GetType() string {
@@ -23,3 +51,29 @@ func (r *Repository) GetType() string {
 	}
 	return r.Type
 }
+
+// GetHost returns the GitHub host a user/org entry without a URL lives on.
+func (r *Repository) GetHost() string {
+	if r.Host == "" {
+		return DefaultGitHubHost
+	}
+	return r.Host
+}
+
+// GetIssueAPI returns the GitHub API the repository's issues are synced
+// through, defaulting to REST.
+func (r *Repository) GetIssueAPI() string {
+	if r.IssueAPI == "" {
+		return IssueAPIREST
+	}
+	return r.IssueAPI
+}
+
+// GetGitBackend returns the git implementation used to sync the repository's
+// code and wiki, defaulting to the built-in go-git backend.
+func (r *Repository) GetGitBackend() string {
+	if r.GitBackend == "" {
+		return GitBackendGoGit
+	}
+	return r.GitBackend
+}
@@ -0,0 +1,11 @@
+package typedef
+
+// Templates points at Go template files that replace the built-in markdown
+// layouts of issues, pull requests and discussions. Issue also lays out pull
+// requests in the issue archive; Pull lays out their code review in the pull
+// request archive. An empty path keeps the built-in layout.
+type Templates struct {
+	Issue      string `yaml:"issue"`
+	Pull       string `yaml:"pull"`
+	Discussion string `yaml:"discussion"`
+}
@@ -0,0 +1,6 @@
+package typedef
+
+// WikiPolicy chooses what is archived besides the wiki's git repository.
+type WikiPolicy struct {
+	HTML bool `yaml:"html"` // also store a browsable static HTML rendering as <name>_wiki_html.tar.gz (default: false)
+}
@@ -0,0 +1,420 @@
+package wiki
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/wnarutou/gitrieve/internal/archive"
+	"github.com/wnarutou/gitrieve/internal/scm"
+	"github.com/wnarutou/gitrieve/internal/storage"
+	"github.com/wnarutou/gitrieve/internal/typedef"
+	"github.com/wnarutou/gitrieve/internal/ui"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// markdownExts are the page formats rendered to HTML. GitHub accepts more
+// (asciidoc, textile, rdoc, ...); pages in those are shown as their source.
+var markdownExts = map[string]bool{".md": true, ".markdown": true, ".mdown": true, ".mkdn": true, ".mkd": true}
+
+var otherPageExts = map[string]bool{
+	".asciidoc": true, ".adoc": true, ".textile": true, ".rdoc": true, ".org": true,
+	".creole": true, ".mediawiki": true, ".wiki": true, ".rst": true, ".pod": true,
+}
+
+var imageExts = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".webp": true}
+
+//go:embed templates/page.tmpl
+var templatesFS embed.FS
+
+var pageTemplate = template.Must(template.ParseFS(templatesFS, "templates/page.tmpl"))
+
+// wikiLink matches GitHub's [[Page]], [[Text|Page]] and [[image.png|alt=Text]].
+var wikiLink = regexp.MustCompile(`\[\[([^\[\]]+)\]\]`)
+
+// page is one wiki page: a file with a page extension, named after its base
+// name wherever it lives in the repository, like GitHub does.
+type page struct {
+	Name string // file name without extension, e.g. Getting-Started
+	Path string // relative to the wiki root
+}
+
+// file returns the name p is rendered as. index.html is the front page, so a
+// page named Index, in any case, is written as _Index.html: no other page
+// starts with an underscore.
+func (p page) file() string {
+	if pageKey(p.Name) == "index" {
+		return "_" + p.Name + ".html"
+	}
+	return p.Name + ".html"
+}
+
+func (p page) title() string { return strings.ReplaceAll(p.Name, "-", " ") }
+
+// site renders one checked-out wiki.
+type site struct {
+	title   string
+	src     string
+	pages   map[string]page // by pageKey
+	order   []page
+	sidebar template.HTML
+	footer  template.HTML
+	md      goldmark.Markdown
+}
+
+// pageKey is how a link names a page: case and spaces versus hyphens do not
+// matter.
+func pageKey(name string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), " ", "-"))
+}
+
+// renderSite renders the wiki checked out at src as static HTML into dst:
+// one <Page>.html per page, index.html for Home (or a list of the pages), and
+// every other file copied as is, so images keep working. _Sidebar and _Footer
+// are shown on every page; without a _Sidebar the pages are listed instead.
+func renderSite(src, dst, title string) error {
+	s := &site{title: title, src: src, pages: make(map[string]page)}
+	s.md = goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithParserOptions(
+			parser.WithAutoHeadingID(),
+			parser.WithASTTransformers(util.Prioritized(s, 100)),
+		),
+		// GitHub keeps some HTML in pages, e.g. <details> or sized images:
+		// it is passed through here and then sanitized like GitHub does,
+		// see sanitize.
+		goldmark.WithRendererOptions(html.WithUnsafe()),
+	)
+
+	var assets, special []string
+	err := filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if rel != "." && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := strings.ToLower(path.Ext(rel))
+		name := strings.TrimSuffix(path.Base(rel), path.Ext(rel))
+		switch {
+		case strings.HasPrefix(d.Name(), "."):
+		case !markdownExts[ext] && !otherPageExts[ext]:
+			assets = append(assets, rel)
+		case strings.HasPrefix(name, "_"):
+			special = append(special, rel)
+		default:
+			if _, ok := s.pages[pageKey(name)]; ok {
+				// Two pages of the same name: GitHub shows one, so do we.
+				return nil
+			}
+			pg := page{Name: name, Path: rel}
+			s.pages[pageKey(name)] = pg
+			s.order = append(s.order, pg)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(s.order, func(i, j int) bool {
+		if home := pageKey(s.order[i].Name) == "home"; home != (pageKey(s.order[j].Name) == "home") {
+			return home
+		}
+		return strings.ToLower(s.order[i].Name) < strings.ToLower(s.order[j].Name)
+	})
+
+	for _, rel := range special {
+		content, err := s.convert(rel)
+		if err != nil {
+			return err
+		}
+		switch strings.TrimSuffix(path.Base(rel), path.Ext(rel)) {
+		case "_Sidebar":
+			s.sidebar = content
+		case "_Footer":
+			s.footer = content
+		}
+	}
+	if s.sidebar == "" {
+		s.sidebar = s.pageList()
+	}
+
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return err
+	}
+	for _, pg := range s.order {
+		content, err := s.convert(pg.Path)
+		if err != nil {
+			return err
+		}
+		if err := s.write(filepath.Join(dst, pg.file()), pg.title(), content); err != nil {
+			return err
+		}
+	}
+	if home, ok := s.pages["home"]; ok {
+		content, err := s.convert(home.Path)
+		if err != nil {
+			return err
+		}
+		err = s.write(filepath.Join(dst, "index.html"), home.title(), content)
+		if err != nil {
+			return err
+		}
+	} else if err := s.write(filepath.Join(dst, "index.html"), "Pages", s.pageList()); err != nil {
+		return err
+	}
+
+	// A file may not replace a rendered page, not even on a case-insensitive
+	// file system.
+	rendered := map[string]bool{"index.html": true}
+	for _, pg := range s.order {
+		rendered[strings.ToLower(pg.file())] = true
+	}
+	for _, rel := range assets {
+		if rendered[strings.ToLower(rel)] {
+			ui.Printf("Skipping wiki file %s: it would overwrite a rendered page", rel)
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(src, filepath.FromSlash(rel)))
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// convert renders the page file rel: markdown to HTML with its wiki links
+// resolved, any other format as its source.
+func (s *site) convert(rel string) (template.HTML, error) {
+	data, err := os.ReadFile(filepath.Join(s.src, filepath.FromSlash(rel)))
+	if err != nil {
+		return "", err
+	}
+	if !markdownExts[strings.ToLower(path.Ext(rel))] {
+		return template.HTML(fmt.Sprintf("<p class=\"note\">This page is written in %s and shown as its source.</p>\n<pre>%s</pre>",
+			template.HTMLEscapeString(strings.TrimPrefix(path.Ext(rel), ".")), template.HTMLEscapeString(string(data)))), nil
+	}
+	var buf bytes.Buffer
+	if err := s.md.Convert([]byte(wikiLinks(string(data))), &buf); err != nil {
+		return "", fmt.Errorf("render %s: %w", rel, err)
+	}
+	content, err := sanitize(buf.String())
+	if err != nil {
+		return "", fmt.Errorf("sanitize %s: %w", rel, err)
+	}
+	return template.HTML(content), nil
+}
+
+func (s *site) write(target, title string, content template.HTML) error {
+	var buf bytes.Buffer
+	err := pageTemplate.Execute(&buf, struct {
+		Wiki, Title              string
+		Content, Sidebar, Footer template.HTML
+	}{s.title, title, content, s.sidebar, s.footer})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(target, buf.Bytes(), 0o644)
+}
+
+// pageList links every page, Home first.
+func (s *site) pageList() template.HTML {
+	var b strings.Builder
+	b.WriteString("<ul>\n")
+	for _, pg := range s.order {
+		fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a></li>\n", template.HTMLEscapeString(url.PathEscape(pg.file())), template.HTMLEscapeString(pg.title()))
+	}
+	b.WriteString("</ul>")
+	return template.HTML(b.String())
+}
+
+// Transform points links at pages, e.g. Getting-Started#setup as GitHub
+// writes them, at the rendered files.
+func (s *site) Transform(doc *ast.Document, _ text.Reader, _ parser.Context) {
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if link, ok := n.(*ast.Link); ok && entering {
+			if dest, ok := s.resolve(string(link.Destination)); ok {
+				link.Destination = []byte(dest)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// resolve returns the rendered file a link destination names, if it names a
+// page.
+func (s *site) resolve(dest string) (string, bool) {
+	if dest == "" || strings.Contains(dest, ":") || strings.HasPrefix(dest, "#") {
+		return "", false
+	}
+	name, fragment, _ := strings.Cut(dest, "#")
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	pg, ok := s.pages[pageKey(strings.TrimPrefix(name, "./"))]
+	if !ok {
+		return "", false
+	}
+	dest = url.PathEscape(pg.file())
+	if fragment != "" {
+		dest += "#" + fragment
+	}
+	return dest, true
+}
+
+// wikiLinks rewrites GitHub's [[...]] links in markdown outside of code into
+// markdown links and images, which the renderer then resolves.
+func wikiLinks(src string) string {
+	lines := strings.Split(src, "\n")
+	fence := ""
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+		// Even parts are outside of code spans.
+		parts := strings.Split(line, "`")
+		for j := 0; j < len(parts); j += 2 {
+			parts[j] = wikiLink.ReplaceAllStringFunc(parts[j], wikiLinkToMarkdown)
+		}
+		lines[i] = strings.Join(parts, "`")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func wikiLinkToMarkdown(match string) string {
+	inner := strings.TrimSpace(match[2 : len(match)-2])
+	text, target, found := strings.Cut(inner, "|")
+	text, target = strings.TrimSpace(text), strings.TrimSpace(target)
+	if !found {
+		target = text
+	}
+	if alt, ok := strings.CutPrefix(target, "alt="); ok {
+		// [[image.png|alt=Text]]
+		return fmt.Sprintf("![%s](<%s>)", escapeLinkText(alt), strings.TrimPrefix(text, "/"))
+	}
+	if imageExts[strings.ToLower(path.Ext(target))] {
+		alt := text
+		if !found {
+			alt = ""
+		}
+		return fmt.Sprintf("![%s](<%s>)", escapeLinkText(alt), strings.TrimPrefix(target, "/"))
+	}
+	if !strings.Contains(target, ":") {
+		target = strings.ReplaceAll(target, " ", "-")
+	}
+	return fmt.Sprintf("[%s](<%s>)", escapeLinkText(text), target)
+}
+
+func escapeLinkText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`).Replace(s)
+}
+
+// exportHTML renders the wiki archive Sync just stored as
+// <name>_wiki_html.tar.gz next to it. An unchanged wiki is rendered only when
+// no rendering is stored yet, e.g. because the option was just turned on.
+func exportHTML(ctx context.Context, r *scm.Repository, repo typedef.Repository, updated bool, storages []typedef.MultiStorage) error {
+	if repo.GetGitBackend() == typedef.GitBackendCLI {
+		ui.Printf("Rendering %s's wiki needs gitBackend: go-git, skipping", repo.Name)
+		return nil
+	}
+	wikiName, htmlName := r.Name+"_wiki", r.Name+"_wiki_html"
+	var source []byte
+	for _, s := range storages {
+		backend, err := storage.GetStorage(s)
+		if err != nil {
+			return err
+		}
+		dir := path.Join(s.Path, r.Host, r.Owner, r.Name)
+		obj, err := backend.GetObject(path.Join(dir, wikiName+".tar.gz"))
+		if errors.Is(err, storage.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !updated {
+			_, err := backend.GetObject(path.Join(dir, htmlName+".tar.gz"))
+			if err == nil {
+				return nil
+			}
+			if !errors.Is(err, storage.ErrNotExist) {
+				return err
+			}
+		}
+		source = obj.Content
+		break
+	}
+	if source == nil {
+		// Nothing stored to render from, e.g. no storage is configured.
+		return nil
+	}
+
+	tmp, err := os.MkdirTemp("", "gitrieve-wiki-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	src, dst := filepath.Join(tmp, "src"), filepath.Join(tmp, "html")
+	if err := archive.Extract(ctx, source, wikiName, src); err != nil {
+		return err
+	}
+	if err := renderSite(src, dst, fmt.Sprintf("%s/%s wiki", r.Owner, r.Name)); err != nil {
+		return err
+	}
+	buf, err := archive.Create(ctx, dst, htmlName)
+	if err != nil {
+		return err
+	}
+	for _, s := range storages {
+		backend, err := storage.GetStorage(s)
+		if err != nil {
+			return err
+		}
+		p := path.Join(s.Path, r.Host, r.Owner, r.Name, htmlName+".tar.gz")
+		if err := backend.PutObject(p, buf.Bytes()); err != nil {
+			return err
+		}
+		ui.Printf("File %s stored", p)
+	}
+	return nil
+}
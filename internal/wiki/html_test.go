@@ -0,0 +1,149 @@
+package wiki
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wnarutou/gitrieve/internal/archive"
+	"github.com/wnarutou/gitrieve/internal/scm"
+	"github.com/wnarutou/gitrieve/internal/storage"
+	"github.com/wnarutou/gitrieve/internal/typedef"
+)
+
+func TestRenderSite(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	files := map[string]string{
+		"Home.md": "Start with [[Getting Started]] or [[the API|API Reference]].\n\n" +
+			"See [setup](Getting-Started#setup), `[[not a link]]` and [[images/logo.png|alt=Logo]].\n\n" +
+			"```\n[[also not a link]]\n```\n",
+		"guides/Getting-Started.md": "## Setup\n\nBack [[Home]], or [[Missing Page]].\n",
+		"API-Reference.asciidoc":    "= API\n<b>raw</b>\n",
+		"_Sidebar.md":               "* [[Home]]\n* [[API Reference]]\n",
+		"_Footer.md":                "Archived copy.\n",
+		"images/logo.png":           "png",
+		".git/config":               "[core]\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(src, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+
+	require.NoError(t, renderSite(src, dst, "owner/repo wiki"))
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dst, name))
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	home := read("Home.html")
+	require.Equal(t, home, read("index.html"))
+	for _, s := range []string{
+		"<title>Home · owner/repo wiki</title>",
+		`<a href="Getting-Started.html">Getting Started</a>`,
+		`<a href="API-Reference.html">the API</a>`,
+		`<a href="Getting-Started.html#setup">setup</a>`,
+		"<code>[[not a link]]</code>",
+		"[[also not a link]]",
+		`<img src="images/logo.png" alt="Logo">`,
+		"<li><a href=\"API-Reference.html\">API Reference</a></li>",
+		"<footer>\n<p>Archived copy.</p>",
+	} {
+		require.Contains(t, home, s)
+	}
+
+	guide := read("Getting-Started.html")
+	require.Contains(t, guide, `<h2 id="setup">Setup</h2>`)
+	require.Contains(t, guide, `<a href="Home.html">Home</a>`)
+	require.Contains(t, guide, `<a href="Missing-Page">Missing Page</a>`, "links to missing pages stay as GitHub has them")
+	require.Contains(t, read("API-Reference.html"), "<pre>= API\n&lt;b&gt;raw&lt;/b&gt;\n</pre>")
+	require.Equal(t, "png", read("images/logo.png"))
+	require.NoFileExists(t, filepath.Join(dst, "_Sidebar.html"))
+	require.NoDirExists(t, filepath.Join(dst, ".git"))
+}
+
+func TestRenderSiteListsPagesWithoutHomeOrSidebar(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "Notes.md"), []byte("# Notes\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "FAQ.md"), []byte("# FAQ\n"), 0o644))
+
+	require.NoError(t, renderSite(src, dst, "owner/repo wiki"))
+	index, err := os.ReadFile(filepath.Join(dst, "index.html"))
+	require.NoError(t, err)
+	require.Contains(t, string(index), "<li><a href=\"FAQ.html\">FAQ</a></li>\n<li><a href=\"Notes.html\">Notes</a></li>")
+}
+
+func TestRenderSiteSanitizesRawHTML(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	page := "<details open><summary>More</summary>\n\n<img src=\"a.png\" width=\"40\" onerror=\"alert(1)\">\n</details>\n\n" +
+		"<script>alert(1)</script>\n\n<a href=\"javascript:alert(1)\">x</a> <span style=\"color:red\">y</span>\n\n" +
+		"- [x] done\n"
+	require.NoError(t, os.WriteFile(filepath.Join(src, "Home.md"), []byte(page), 0o644))
+
+	require.NoError(t, renderSite(src, dst, "owner/repo wiki"))
+	data, err := os.ReadFile(filepath.Join(dst, "Home.html"))
+	require.NoError(t, err)
+	home := string(data)
+	for _, s := range []string{
+		`<details open=""><summary>More</summary>`,
+		`<img src="a.png" width="40">`,
+		"<a>x</a> <span>y</span>",
+		`<input checked="" disabled="" type="checkbox"> done`,
+	} {
+		require.Contains(t, home, s)
+	}
+	for _, s := range []string{"alert", "onerror", "style=", "<script"} {
+		require.NotContains(t, home, s)
+	}
+}
+
+func TestRenderSiteKeepsIndexForFrontPage(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "Home.md"), []byte("See [[Index]].\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "Index.md"), []byte("# All pages\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "index.html"), []byte("stray"), 0o644))
+
+	require.NoError(t, renderSite(src, dst, "owner/repo wiki"))
+	index, err := os.ReadFile(filepath.Join(dst, "index.html"))
+	require.NoError(t, err)
+	require.Contains(t, string(index), `<a href="_Index.html">Index</a>`)
+	page, err := os.ReadFile(filepath.Join(dst, "_Index.html"))
+	require.NoError(t, err)
+	require.Contains(t, string(page), "All pages")
+}
+
+func TestExportHTMLStoresRenderingNextToWiki(t *testing.T) {
+	wikiDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(wikiDir, "Home.md"), []byte("Hello [[Usage]]\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(wikiDir, "Usage.md"), []byte("Run it.\n"), 0o644))
+	buf, err := archive.Create(context.Background(), wikiDir, "repo_wiki")
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	storages := []typedef.MultiStorage{{Storage: typedef.Storage{Name: "local", Type: storage.FileStorage, Path: dir}}}
+	r := &scm.Repository{Host: "github.com", Owner: "owner", Name: "repo"}
+	base := filepath.Join(dir, "github.com", "owner", "repo")
+	require.NoError(t, os.MkdirAll(base, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(base, "repo_wiki.tar.gz"), buf.Bytes(), 0o644))
+
+	// Unchanged, but never rendered: the rendering is made now.
+	require.NoError(t, exportHTML(context.Background(), r, typedef.Repository{}, false, storages))
+	data, err := os.ReadFile(filepath.Join(base, "repo_wiki_html.tar.gz"))
+	require.NoError(t, err)
+	out := t.TempDir()
+	require.NoError(t, archive.Extract(context.Background(), data, "repo_wiki_html", out))
+	index, err := os.ReadFile(filepath.Join(out, "index.html"))
+	require.NoError(t, err)
+	require.Contains(t, string(index), `<a href="Usage.html">Usage</a>`)
+	require.FileExists(t, filepath.Join(out, "Usage.html"))
+
+	// Unchanged and rendered before: left alone.
+	require.NoError(t, os.WriteFile(filepath.Join(base, "repo_wiki_html.tar.gz"), []byte("old"), 0o644))
+	require.NoError(t, exportHTML(context.Background(), r, typedef.Repository{}, false, storages))
+	data, err = os.ReadFile(filepath.Join(base, "repo_wiki_html.tar.gz"))
+	require.NoError(t, err)
+	require.Equal(t, "old", string(data))
+}
@@ -0,0 +1,137 @@
+package wiki
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// allowedTags are the elements kept in a rendered page, close to the list
+// GitHub sanitizes wiki pages with. Any other tag is dropped and its text kept.
+var allowedTags = map[string]bool{
+	"a": true, "abbr": true, "b": true, "bdo": true, "blockquote": true, "br": true, "caption": true,
+	"cite": true, "code": true, "dd": true, "del": true, "details": true, "dfn": true, "div": true,
+	"dl": true, "dt": true, "em": true, "figcaption": true, "figure": true, "h1": true, "h2": true,
+	"h3": true, "h4": true, "h5": true, "h6": true, "hr": true, "i": true, "img": true, "input": true,
+	"ins": true, "kbd": true, "li": true, "mark": true, "ol": true, "p": true, "pre": true, "q": true,
+	"rp": true, "rt": true, "ruby": true, "s": true, "samp": true, "small": true, "span": true,
+	"strike": true, "strong": true, "sub": true, "summary": true, "sup": true, "table": true,
+	"tbody": true, "td": true, "tfoot": true, "th": true, "thead": true, "time": true, "tr": true,
+	"tt": true, "ul": true, "var": true, "wbr": true,
+}
+
+// droppedWithContent are the elements whose content goes with them: it is code
+// or markup that was never meant to be read as text.
+var droppedWithContent = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true, "noscript": true,
+	"template": true, "textarea": true, "select": true, "svg": true, "math": true,
+}
+
+// allowedAttrs are the attributes kept on any allowed element, and per element.
+var allowedAttrs = map[string]map[string]bool{
+	"":        {"id": true, "title": true, "align": true, "lang": true, "dir": true},
+	"a":       {"href": true, "name": true},
+	"code":    {"class": true}, // language-<lang> of fenced code
+	"img":     {"src": true, "alt": true, "width": true, "height": true},
+	"input":   {"type": true, "checked": true, "disabled": true}, // task list items
+	"ol":      {"start": true},
+	"td":      {"colspan": true, "rowspan": true},
+	"th":      {"colspan": true, "rowspan": true},
+	"details": {"open": true},
+	"time":    {"datetime": true},
+}
+
+// urlAttrs are the attributes holding a URL, which must be relative or use one
+// of safeSchemes.
+var urlAttrs = map[string]bool{"href": true, "src": true}
+
+var safeSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+// sanitize keeps the elements, attributes and URLs of an allow-list in the
+// HTML fragment src, so raw HTML in an archived page renders like on GitHub
+// but cannot run scripts or restyle the site when the rendering is opened.
+func sanitize(src string) (string, error) {
+	z := html.NewTokenizer(strings.NewReader(src))
+	var b strings.Builder
+	// dropping counts the open elements whose content is being dropped.
+	dropping := 0
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if errors.Is(z.Err(), io.EOF) {
+				return b.String(), nil
+			}
+			return "", z.Err()
+		}
+		tok := z.Token()
+		// Comments and doctypes are dropped.
+		switch tt {
+		case html.TextToken:
+			if dropping == 0 {
+				b.WriteString(html.EscapeString(tok.Data))
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if droppedWithContent[tok.Data] {
+				if tt == html.StartTagToken {
+					dropping++
+				}
+				continue
+			}
+			if dropping > 0 || !allowedTags[tok.Data] {
+				continue
+			}
+			if tok.Data == "input" && !isCheckbox(tok) {
+				continue
+			}
+			tok.Attr = allowedAttributes(tok)
+			b.WriteString(tok.String())
+		case html.EndTagToken:
+			if droppedWithContent[tok.Data] {
+				if dropping > 0 {
+					dropping--
+				}
+				continue
+			}
+			if dropping == 0 && allowedTags[tok.Data] {
+				b.WriteString(tok.String())
+			}
+		}
+	}
+}
+
+func allowedAttributes(tok html.Token) []html.Attribute {
+	var attrs []html.Attribute
+	for _, a := range tok.Attr {
+		if a.Namespace != "" || !allowedAttrs[""][a.Key] && !allowedAttrs[tok.Data][a.Key] {
+			continue
+		}
+		if urlAttrs[a.Key] && !safeURL(a.Val) {
+			continue
+		}
+		attrs = append(attrs, a)
+	}
+	return attrs
+}
+
+// isCheckbox reports whether an input element is a checkbox, the only kind
+// markdown produces.
+func isCheckbox(tok html.Token) bool {
+	for _, a := range tok.Attr {
+		if a.Key == "type" {
+			return strings.EqualFold(a.Val, "checkbox")
+		}
+	}
+	return false
+}
+
+// safeURL reports whether u is relative or uses a scheme of safeSchemes.
+func safeURL(u string) bool {
+	parsed, err := url.Parse(strings.TrimSpace(u))
+	if err != nil {
+		return false
+	}
+	return parsed.Scheme == "" || safeSchemes[strings.ToLower(parsed.Scheme)]
+}
@@ -2,15 +2,18 @@ package wiki
 
 import (
 	"context"
+	"errors"
 
-	gh "github.com/google/go-github/v56/github"
-	"github.com/wnarutou/gitrieve/internal/config"
 	"github.com/wnarutou/gitrieve/internal/repository"
 	"github.com/wnarutou/gitrieve/internal/scm"
+	"github.com/wnarutou/gitrieve/internal/scm/github"
 	"github.com/wnarutou/gitrieve/internal/typedef"
 	"github.com/wnarutou/gitrieve/internal/ui"
 )
 
+// Sync archives a repository's wiki, and with repo.Wiki.HTML a static HTML
+// rendering of it. It returns repository.ErrNoWiki, without cloning, when the
+// wiki is disabled or has no pages.
 func Sync(ctx context.Context, repo typedef.Repository, storages []typedef.MultiStorage) error {
 	if ctx.Err() != nil {
 		return ctx.Err()
@@ -26,25 +29,44 @@ func Sync(ctx context.Context, repo typedef.Repository, storages []typedef.Multi
 		return err
 	}
 
-	cfg := config.GetIns()
-	client := gh.NewClient(nil).WithAuthToken(cfg.GitHubToken)
+	client, err := github.NewRESTClient(r.Host)
+	if err != nil {
+		ui.Errorf("Error creating github client, %s", err)
+		return err
+	}
 
-	gitrepo, _, err := client.Repositories.Get(context.Background(), r.Owner, r.Name)
+	gitrepo, _, err := client.Repositories.Get(ctx, r.Owner, r.Name)
 	if err != nil {
 		ui.Errorf("Get repository %s fail", repo.URL)
 		return err
 	}
 
 	if !gitrepo.GetHasWiki() {
-		ui.Errorf("repository %s has no wiki", repo.URL)
+		ui.Printf("Repository %s has no wiki, skipping", repo.URL)
+		return repository.ErrNoWiki
 	}
 
 	ui.Printf("Running %s's wiki", repo.Name)
-	if err := repository.Sync(ctx, repo, true, storages); err != nil {
+	err = repository.Sync(ctx, repo, true, storages)
+	// Sync already logged the skip; an unchanged wiki is not a failure.
+	unchanged := errors.Is(err, repository.ErrUnchanged)
+	if err != nil && !unchanged {
+		if errors.Is(err, repository.ErrNoWiki) {
+			// Logged by Sync too; returned so callers can record the outcome.
+			return err
+		}
 		if ctx.Err() == nil {
 			ui.Errorf("Error running %s's wiki, %s", repo.Name, err)
 		}
 		return err
 	}
+	if repo.Wiki.HTML {
+		if err := exportHTML(ctx, r, repo, !unchanged, storages); err != nil {
+			if ctx.Err() == nil {
+				ui.Errorf("Error rendering %s's wiki, %s", repo.Name, err)
+			}
+			return err
+		}
+	}
 	return nil
 }
@@ -2,9 +2,13 @@ package wiki
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"github.com/wnarutou/gitrieve/internal/repository"
+	"github.com/wnarutou/gitrieve/internal/scm/github/githubtest"
 	"github.com/wnarutou/gitrieve/internal/typedef"
 )
 
@@ -15,3 +19,15 @@ func TestSyncCancelledContextReturnsImmediately(t *testing.T) {
 	err := Sync(ctx, typedef.Repository{URL: "github.com/test/repo"}, nil)
 	require.ErrorIs(t, err, context.Canceled)
 }
+
+func TestSyncReportsDisabledWiki(t *testing.T) {
+	host := githubtest.Serve(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/repos/owner/repo", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"full_name":"owner/repo","has_wiki":false}`)
+	}))
+
+	err := Sync(context.Background(), typedef.Repository{URL: host + "/owner/repo"}, nil)
+	require.ErrorIs(t, err, repository.ErrNoWiki)
+	require.NoDirExists(t, ".gitrieve", "a disabled wiki is not cloned")
+}
@@ -58,10 +58,17 @@ function optionsCell(r) {
     const parts = [];
     if (r.UseCache) parts.push('cache');
     if (r.AllBranches) parts.push('allBranches');
+    if (r.GitBackend === 'cli') parts.push('gitCli');
     if (r.DownloadReleases) parts.push('releases');
+    if (r.DownloadSourceArchives) parts.push('archives');
     if (r.DownloadIssues) parts.push('issues');
+    if (r.DownloadPullRequests) parts.push('pulls');
     if (r.DownloadWiki) parts.push('wiki');
     if (r.DownloadDiscussion) parts.push('discussion');
+    if (r.DownloadActions) parts.push('actions');
+    if (r.DownloadMetadata) parts.push('metadata');
+    if (r.DownloadProjects) parts.push('projects');
+    if (r.DownloadSecurity) parts.push('security');
     return parts.length ? esc(parts.join(' ')) : '-';
 }
 
@@ -147,7 +154,7 @@ function jobsTable(jobs, page, limit, total) {
     }
     const rows = jobs.map(j => `
         <tr>
-            <td>${statusBadge(j.status)}</td>
+            <td>${statusBadge(j.status)}${j.no_wiki ? ' <span class="muted" title="downloadWiki is set but the repository has no wiki">no wiki</span>' : ''}</td>
             <td><strong>${esc(j.name)}</strong></td>
             <td class="muted">${esc(j.url || '-')}</td>
             <td>${fmtTime(j.start_time)}</td>
@@ -321,12 +328,19 @@ function openRepoForm(repo) {
     $('#repo-org').value = repo ? (repo.OrgName || '') : '';
     $('#repo-cron').value = repo ? (repo.Cron || '') : '';
     $('#repo-depth').value = repo ? (repo.Depth || 0) : 0;
+    $('#repo-gitbackend').value = repo && repo.GitBackend ? repo.GitBackend : 'go-git';
     $('#repo-uses').checked = !!(repo && repo.UseCache);
     $('#repo-allbranches').checked = !!(repo && repo.AllBranches);
     $('#repo-releases').checked = !!(repo && repo.DownloadReleases);
+    $('#repo-archives').checked = !!(repo && repo.DownloadSourceArchives);
     $('#repo-issues').checked = !!(repo && repo.DownloadIssues);
+    $('#repo-pulls').checked = !!(repo && repo.DownloadPullRequests);
     $('#repo-wiki').checked = !!(repo && repo.DownloadWiki);
     $('#repo-discussion').checked = !!(repo && repo.DownloadDiscussion);
+    $('#repo-actions').checked = !!(repo && repo.DownloadActions);
+    $('#repo-metadata').checked = !!(repo && repo.DownloadMetadata);
+    $('#repo-projects').checked = !!(repo && repo.DownloadProjects);
+    $('#repo-security').checked = !!(repo && repo.DownloadSecurity);
 
     const selected = new Set(repo ? (repo.Storage || []) : []);
     const box = $('#repo-storage');
@@ -362,10 +376,17 @@ async function saveRepo(ev) {
         UseCache: $('#repo-uses').checked,
         AllBranches: $('#repo-allbranches').checked,
         Depth: parseInt($('#repo-depth').value, 10) || 0,
+        GitBackend: $('#repo-gitbackend').value,
         DownloadReleases: $('#repo-releases').checked,
+        DownloadSourceArchives: $('#repo-archives').checked,
         DownloadIssues: $('#repo-issues').checked,
+        DownloadPullRequests: $('#repo-pulls').checked,
         DownloadWiki: $('#repo-wiki').checked,
-        DownloadDiscussion: $('#repo-discussion').checked
+        DownloadDiscussion: $('#repo-discussion').checked,
+        DownloadActions: $('#repo-actions').checked,
+        DownloadMetadata: $('#repo-metadata').checked,
+        DownloadProjects: $('#repo-projects').checked,
+        DownloadSecurity: $('#repo-security').checked
     };
 
     try {
@@ -419,7 +440,7 @@ async function renderRepositories() {
             <td class="muted">${esc(r.Cron || '-')}</td>
             <td class="muted">${fmtTime(r.next_run_time)}</td>
             <td class="muted">${fmtTime(r.last_run_time)}</td>
-            <td class="muted">${r.total_runs} total \u00b7 ${r.success_runs} ok \u00b7 ${r.failed_runs} fail</td>
+            <td class="muted">${r.total_runs} total \u00b7 ${r.success_runs} ok \u00b7 ${r.failed_runs} fail \u00b7 ${r.skipped_runs || 0} skipped</td>
             <td class="muted">${esc((r.Storage || []).join(', ') || '-')}</td>
             <td class="muted">${optionsCell(r)}</td>
             <td class="actions">